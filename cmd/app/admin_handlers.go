@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/ratings"
+	"github.com/94DanielBrown/roasts-api/internal/roasts"
+	"github.com/94DanielBrown/roasts-api/pkg/apikey"
+	"github.com/94DanielBrown/roasts-api/pkg/firebase"
+	"github.com/labstack/echo/v4"
+)
+
+// @Summary recompute roast aggregates
+// @ID recompute-aggregates
+// @Tags admin
+// @Produce json
+// @Param roastID query string false "only recompute this roast"
+// @Param dryRun query bool false "report drift without saving the rebuilt aggregates"
+// @Success 200 {object} []ratings.RecomputeResult
+// @Failure 400 {object} message
+// @Failure 500 {object} message
+// @Router /admin/recompute [post]
+func (app *Config) recomputeAggregatesHandler(c echo.Context) error {
+	correlationId := c.Get("correlationID")
+	roastID := c.QueryParam("roastID")
+
+	dryRun := false
+	if param := c.QueryParam("dryRun"); param != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(param)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, message{Message: "dryRun must be true or false"})
+		}
+	}
+	app.Logger.Info("recompute request received", "roastID", roastID, "dryRun", dryRun, "correlationID", correlationId)
+
+	var results []ratings.RecomputeResult
+	if roastID != "" {
+		result, err := ratings.Recompute(app.RoastModels, app.ReviewModels, roastID, app.ratingSettings(), !dryRun)
+		if err != nil {
+			errMsg := "error recomputing roast aggregates"
+			app.Logger.Error(errMsg, "err", err, "roastID", roastID, "correlationID", correlationId)
+			return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+		}
+		results = append(results, result)
+	} else {
+		var err error
+		// Every roast is rebuilt against the global mean of the rebuilt aggregates rather than the cached one
+		results, err = ratings.RecomputeAll(app.RoastModels, app.ReviewModels, app.Ratings, !dryRun)
+		if err != nil {
+			errMsg := "error recomputing roast aggregates"
+			app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+			return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+		}
+	}
+	if !dryRun {
+		if err := app.refreshStats(); err != nil {
+			app.Logger.Error("error refreshing table stats", "err", err, "correlationID", correlationId)
+		}
+	}
+
+	app.Logger.Info("roast aggregates recomputed", "roasts", len(results), "correlationID", correlationId)
+	return c.JSON(http.StatusOK, results)
+}
+
+// mergeRequest merges the source roast into the target, DryRun previews the merge without making it
+type mergeRequest struct {
+	SourceID string `json:"sourceID"`
+	TargetID string `json:"targetID"`
+	DryRun   bool   `json:"dryRun"`
+}
+
+// @Summary merge a duplicate roast into another, moving its reviews and saves and leaving a redirect
+// @ID merge-roasts
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param data body mergeRequest true "source roast to merge into the target"
+// @Success 200 {object} roasts.MergeResult
+// @Failure 400 {object} message
+// @Failure 404 {object} message
+// @Failure 409 {object} message
+// @Failure 500 {object} message
+// @Router /admin/merge [post]
+func (app *Config) mergeRoastsHandler(c echo.Context) error {
+	correlationId := c.Get("correlationID")
+	var request mergeRequest
+	if err := c.Bind(&request); err != nil || request.SourceID == "" || request.TargetID == "" {
+		return c.JSON(http.StatusBadRequest, message{Message: "sourceID and targetID are required"})
+	}
+	app.Logger.Info("merge request received", "sourceID", request.SourceID, "targetID", request.TargetID, "dryRun", request.DryRun, "correlationID", correlationId)
+
+	result, err := roasts.Merge(app.RoastModels, app.ReviewModels, app.UserModels, app.TagModels, request.SourceID, request.TargetID, app.ratingSettings(), !request.DryRun)
+	if errors.Is(err, roasts.ErrSameRoast) {
+		return c.JSON(http.StatusBadRequest, message{Message: err.Error()})
+	}
+	if errors.Is(err, roasts.ErrRoastNotFound) {
+		return c.JSON(http.StatusNotFound, message{Message: err.Error()})
+	}
+	if errors.Is(err, roasts.ErrReviewsLeft) {
+		return c.JSON(http.StatusConflict, message{Message: err.Error() + ", run the merge again to move them"})
+	}
+	if err != nil {
+		errMsg := "error merging roasts"
+		app.Logger.Error(errMsg, "err", err, "sourceID", request.SourceID, "targetID", request.TargetID, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+
+	if result.Merged {
+		app.Search.RemoveRoast(request.SourceID)
+		app.reindexReviews(result.Target.RoastKey)
+	}
+	app.Logger.Info("roasts merged", "sourceID", request.SourceID, "targetID", request.TargetID, "reviews", len(result.Reviews), "merged", result.Merged, "correlationID", correlationId)
+	return c.JSON(http.StatusOK, result)
+}
+
+// reindexReviews indexes every review of a roast for search, failures are only logged as the index is
+// rebuilt when the API restarts
+func (app *Config) reindexReviews(roastKey string) {
+	roastReviews, err := app.ReviewModels.GetReviewsByRoast(roastKey)
+	if err != nil {
+		app.Logger.Error("error reindexing reviews", "err", err, "roastKey", roastKey)
+		return
+	}
+	for _, review := range roastReviews {
+		app.Search.PutReview(review)
+	}
+}
+
+// requireRole only lets through users with one of roles, it has to come after the firebase middleware
+func (app *Config) requireRole(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			userID, _ := c.Get("userID").(string)
+			user, err := app.UserModels.GetUserByPrefix("USER#" + userID)
+			if err != nil {
+				errMsg := "error getting user"
+				app.Logger.Error(errMsg, "err", err, "correlationID", c.Get("correlationID"))
+				return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+			}
+			if user != nil {
+				for _, role := range user.Roles {
+					if slices.Contains(roles, role) {
+						return next(c)
+					}
+				}
+			}
+			return c.JSON(http.StatusForbidden, message{Message: "not allowed"})
+		}
+	}
+}
+
+// requireAdmin lets through requests with a valid API key or, if they don't send one, from users with the admin role
+func (app *Config) requireAdmin() echo.MiddlewareFunc {
+	withKey := apikey.Validate(&app.APIKeyModels)
+	withJWT := firebase.FirebaseJWTMiddleware()
+	withRole := app.requireRole(database.RoleAdmin)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		keyed, signedIn := withKey(next), withJWT(withRole(next))
+		return func(c echo.Context) error {
+			if c.Request().Header.Get("X-API-Key") != "" {
+				return keyed(c)
+			}
+			return signedIn(c)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/ratings"
+	"github.com/94DanielBrown/roasts-api/internal/roasts"
+	"github.com/labstack/echo/v4"
+)
+
+// maxCompared is the most roasts that can be compared at once, each needs a query of its reviews
+const maxCompared = 5
+
+// @Summary compare roasts side by side
+// @ID compare-roasts
+// @Tags roasts
+// @Produce json
+// @Param ids query string true "comma separated IDs of 2 to 5 roasts"
+// @Success 200 {object} roasts.Comparison
+// @Failure 400 {object} message
+// @Failure 404 {object} message
+// @Failure 500 {object} message
+// @Router /roasts/compare [get]
+func (app *Config) compareRoastsHandler(c echo.Context) error {
+	correlationId := c.Get("correlationID")
+	var ids []string
+	for _, id := range strings.Split(c.QueryParam("ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) < 2 || len(ids) > maxCompared {
+		return c.JSON(http.StatusBadRequest, message{Message: fmt.Sprintf("ids must be 2 to %d different roasts", maxCompared)})
+	}
+
+	now := time.Now()
+	compared := make([]*database.Roast, 0, len(ids))
+	reviews := make([][]database.Review, 0, len(ids))
+	for i, id := range ids {
+		// A merged roast's ID gets the roast it was merged into
+		roast, err := app.RoastModels.GetRoastByID(id)
+		if err != nil {
+			errMsg := "error getting roast"
+			app.Logger.Error(errMsg, "err", err, "roastID", id, "correlationID", correlationId)
+			return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+		}
+		if roast == nil {
+			return c.JSON(http.StatusNotFound, message{Message: fmt.Sprintf("roast %s not found", id)})
+		}
+		if roast.RoastID != id {
+			if slices.Contains(ids, roast.RoastID) {
+				return c.JSON(http.StatusBadRequest, message{Message: fmt.Sprintf("%s has been merged into %s", id, roast.RoastID)})
+			}
+			ids[i] = roast.RoastID
+		}
+		roastReviews, err := app.ReviewModels.GetReviewsByRoast(roast.RoastKey)
+		if err != nil {
+			errMsg := "error getting reviews"
+			app.Logger.Error(errMsg, "err", err, "roastID", id, "correlationID", correlationId)
+			return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+		}
+		ratings.SetCurrentRatings(roast, app.ratingSettings(), now)
+		// Ranking and value scores are relative to every roast, as they are in the listing
+		app.setScores(roast)
+		roast.NextServing = app.Calendar.NextServing(roast, now)
+		compared = append(compared, roast)
+		reviews = append(reviews, roastReviews)
+	}
+
+	comparison := roasts.Compare(compared, reviews)
+	app.Logger.Info("roasts compared", "roasts", ids, "reviewers", len(comparison.Reviewers), "correlationID", correlationId)
+	return c.JSON(http.StatusOK, comparison)
+}
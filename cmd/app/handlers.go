@@ -1,15 +1,22 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/geo"
+	"github.com/94DanielBrown/roasts-api/internal/images"
 	"github.com/94DanielBrown/roasts-api/internal/ratings"
 	"github.com/94DanielBrown/roasts-api/internal/reviews"
+	"github.com/94DanielBrown/roasts-api/internal/roasts"
 	"github.com/94DanielBrown/roasts-api/internal/utils"
+	"github.com/94DanielBrown/roasts-api/internal/venues"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
 )
@@ -25,21 +32,27 @@ type message struct {
 	Message string `json:"message"`
 }
 
+// roastResponse adds values calculated when requested to a roast
+type roastResponse struct {
+	*database.Roast
+	RatingStats map[string]ratings.DistributionStats `json:"ratingStats,omitempty"`
+}
+
 // @Summary get a roast
 // @ID get-roast
 // @Tags roasts
 // @Produce json
-// @Success 200 {object} database.Roast
+// @Param stats query bool false "include the median and standard deviation of each criterion"
+// @Success 200 {object} roastResponse
 // @Failure 404 {object} message
 // @Failure 500 {object} message
 // @Router /roast/{roastID} [get]
 func (app *Config) getRoastHandler(c echo.Context) error {
 	correlationId := c.Get("correlationID")
 	roastID := c.Param("roastID")
-	roastPrefix := "ROAST#" + roastID
 
-	// roast is a pointer here to deal with nil values being returned
-	roast, err := app.RoastModels.GetRoastByPrefix(roastPrefix)
+	// roast is a pointer here to deal with nil values being returned, a merged roast's ID gets the roast it was merged into
+	roast, err := app.RoastModels.GetRoastByID(roastID)
 	if err != nil {
 		errMsg := "error getting roast"
 		app.Logger.Error(errMsg, "error", err, "correlationID", correlationId)
@@ -49,8 +62,17 @@ func (app *Config) getRoastHandler(c echo.Context) error {
 		return c.JSON(http.StatusNotFound, map[string]string{"message": "roast not found"})
 	}
 
+	now := time.Now()
+	ratings.SetCurrentRatings(roast, app.ratingSettings(), now)
+	app.setScores(roast)
+	roast.NextServing = app.Calendar.NextServing(roast, now)
+	response := roastResponse{Roast: roast}
+	if c.QueryParam("stats") == "true" {
+		response.RatingStats = ratings.Stats(roast.RatingDistribution)
+	}
+
 	app.Logger.Info("roast returned", "correlationID", correlationId)
-	return c.JSON(http.StatusOK, roast)
+	return c.JSON(http.StatusOK, response)
 }
 
 // @Summary create a roast
@@ -77,6 +99,22 @@ func (app *Config) createRoastHandler(c echo.Context) error {
 	newRoast.RoastID = RoastID
 	newRoast.RoastKey = "ROAST#" + RoastID
 	newRoast.SK = "PROFILE#" + time.Now().Format("02042006")
+	// Roasts whose postcode and town aren't known are created without coordinates so aren't found by proximity
+	app.Places.Locate(newRoast.Address)
+	if err := roasts.SetLocation(&newRoast); err != nil {
+		return c.JSON(http.StatusBadRequest, message{Message: err.Error()})
+	}
+	roastTags, ok, err := app.normaliseTags(c, newRoast.Tags)
+	if !ok {
+		return err
+	}
+	newRoast.Tags = roastTags
+	now := time.Now()
+	if newRoast.Venue != nil {
+		if err := venues.Normalise(newRoast.Venue, now); err != nil {
+			return c.JSON(http.StatusBadRequest, message{Message: err.Error()})
+		}
+	}
 
 	app.Logger.Info("Roast request received", "payload", newRoast, "correlationID", correlationId)
 
@@ -85,7 +123,13 @@ func (app *Config) createRoastHandler(c echo.Context) error {
 		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
 		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
 	}
+	if err := app.savePriceHistory(newRoast.RoastID, venues.PriceChanges(nil, newRoast.Venue), now); err != nil {
+		errMsg := "error saving price history"
+		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
 
+	app.Search.PutRoast(newRoast)
 	app.Logger.Info("Roast created", "correlationID", correlationId)
 	return c.JSON(http.StatusOK, newRoast)
 }
@@ -111,6 +155,7 @@ func (app *Config) deleteRoastHandler(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
 	}
 
+	app.Search.RemoveRoast(strings.ReplaceAll(roastName, " ", ""))
 	app.Logger.Info("Roast deleted", "correlationID", correlationId)
 	return c.JSON(http.StatusOK, fmt.Sprintf("%s deleted", roastName))
 }
@@ -119,13 +164,51 @@ func (app *Config) deleteRoastHandler(c echo.Context) error {
 // @ID  get-all-roasts
 // @Tags roasts
 // @Produce json
+// @Param sort query string false "ranking, rating, recent, current, value, reviews, name or distance"
+// @Param minValue query number false "only roasts with at least this value score"
+// @Param near query string false "only roasts near lat,lng, a postcode or a town, nearest first unless sorted otherwise"
+// @Param radius query number false "distance in km from near, defaults to 10"
+// @Param minPrice query int false "minimum price range"
+// @Param maxPrice query int false "maximum price range"
+// @Param minReviews query int false "only roasts with at least this many reviews"
+// @Param minOverall query number false "only roasts rated at least this overall, likewise minMeat, minPotatoes, minVeg and minGravy"
+// @Param location query string false "only roasts whose location contains this"
+// @Param town query string false "only roasts in this town"
+// @Param tags query string false "comma separated tags the roasts must all have"
+// @Param hasPhotos query bool false "only roasts with review photos"
+// @Param servedToday query bool false "only roasts served today in their venue's timezone"
+// @Param openNow query bool false "only roasts being served now"
+// @Param facets query bool false "respond with the roasts and the count of roasts for each filter value"
 // @Success 200 {object} []database.Roast
 // @Failure 400 {object} message
 // @Failure 500 {object} message
 // @Router /roasts [get]
 func (app *Config) getAllRoastsHandler(c echo.Context) error {
 	correlationId := c.Get("correlationID")
-	allRoasts, err := app.RoastModels.GetAllRoasts()
+	params, filter, err := listingFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, message{Message: err.Error()})
+	}
+
+	var allRoasts []database.Roast
+	var center geo.Point
+	var radius float64
+	near := c.QueryParam("near")
+	if near != "" {
+		center, radius, err = app.proximityParams(near, c.QueryParam("radius"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, message{Message: err.Error()})
+		}
+		allRoasts, err = roasts.Near(app.RoastModels, center, radius)
+		if errors.Is(err, geo.ErrTooManyCells) {
+			return c.JSON(http.StatusBadRequest, message{Message: err.Error()})
+		}
+	} else if params.Facets {
+		// Facets count the roasts that fail a filter, so every roast is needed
+		allRoasts, err = app.RoastModels.GetAllRoasts()
+	} else {
+		allRoasts, err = app.RoastModels.ScanRoasts(filter.Scan())
+	}
 	if err != nil {
 		errMsg := "Error getting all roasts from dynamodb"
 		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
@@ -135,10 +218,227 @@ func (app *Config) getAllRoastsHandler(c echo.Context) error {
 		allRoasts = []database.Roast{}
 	}
 
+	// Only some roasts may have been read, so ranking and value scores come from the cached figures of every roast
+	for i := range allRoasts {
+		app.setScores(&allRoasts[i])
+	}
+	now := time.Now()
+	filter.Now, filter.Calendar = now, app.Calendar
+	var facets roasts.Facets
+	if params.Facets {
+		allRoasts, facets = filter.ApplyWithFacets(allRoasts)
+	} else {
+		allRoasts = filter.Apply(allRoasts)
+	}
+
+	for i := range allRoasts {
+		ratings.SetCurrentRatings(&allRoasts[i], app.ratingSettings(), now)
+	}
+	app.Calendar.SetNextServing(allRoasts, now)
+
+	sortBy := c.QueryParam("sort")
+	if sortBy == roasts.SortRecent {
+		trends, err := app.RoastModels.GetAllTrends(ratings.RecentFromMonth(now))
+		if err != nil {
+			errMsg := "Error getting roast trends from dynamodb"
+			app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+			return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+		}
+		recent := ratings.RecentOverallByRoast(trends, now)
+		for i := range allRoasts {
+			allRoasts[i].RecentRating = recent[allRoasts[i].RoastID]
+		}
+	}
+
+	if sortBy != "" {
+		if err := roasts.Sort(allRoasts, sortBy); err != nil {
+			return c.JSON(http.StatusBadRequest, message{Message: err.Error()})
+		}
+	}
+
 	app.Logger.Info("all roasts returned", "correlationID", correlationId)
+	if params.Facets {
+		return c.JSON(http.StatusOK, roastListing{Roasts: allRoasts, Facets: facets})
+	}
 	return c.JSON(http.StatusOK, allRoasts)
 }
 
+// listingParams are the filters of the roast listing, the minimum rating of each criterion is a parameter
+// named after it, e.g. minGravy
+type listingParams struct {
+	MinValue    float64 `query:"minValue"`
+	MinPrice    int     `query:"minPrice"`
+	MaxPrice    int     `query:"maxPrice"`
+	MinReviews  int     `query:"minReviews"`
+	Location    string  `query:"location"`
+	Town        string  `query:"town"`
+	Tags        string  `query:"tags"`
+	HasPhotos   bool    `query:"hasPhotos"`
+	ServedToday bool    `query:"servedToday"`
+	OpenNow     bool    `query:"openNow"`
+	Facets      bool    `query:"facets"`
+}
+
+// roastListing is the roast listing with the facet counts of its filters
+type roastListing struct {
+	Roasts []database.Roast `json:"roasts"`
+	Facets roasts.Facets    `json:"facets"`
+}
+
+// listingFilter binds the listing's filter parameters
+func listingFilter(c echo.Context) (listingParams, roasts.Filter, error) {
+	var params listingParams
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &params); err != nil {
+		return params, roasts.Filter{}, errors.New("filters must be numbers, except location, town and tags")
+	}
+
+	filter := roasts.Filter{
+		Location:    params.Location,
+		Town:        params.Town,
+		MinPrice:    params.MinPrice,
+		MaxPrice:    params.MaxPrice,
+		MinReviews:  params.MinReviews,
+		HasPhotos:   params.HasPhotos,
+		ServedToday: params.ServedToday,
+		OpenNow:     params.OpenNow,
+		MinValue:    params.MinValue,
+		MinRatings:  map[string]float64{},
+	}
+	if params.Tags != "" {
+		filter.Tags = strings.Split(params.Tags, ",")
+	}
+	for _, criterion := range ratings.Criteria {
+		name := "min" + utils.ToPascalCase(criterion)
+		if value := c.QueryParam(name); value != "" {
+			rating, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return params, roasts.Filter{}, fmt.Errorf("%s must be a number", name)
+			}
+			filter.MinRatings[criterion] = rating
+		}
+	}
+	return params, filter, nil
+}
+
+// proximityParams parses the near and radius query parameters, near is either coordinates or a postcode or town
+func (app *Config) proximityParams(near, radiusParam string) (geo.Point, float64, error) {
+	center, err := geo.ParsePoint(near)
+	if errors.Is(err, geo.ErrInvalidPoint) && !strings.Contains(near, ",") {
+		place, resolveErr := app.Places.Resolve(near)
+		if resolveErr != nil {
+			return geo.Point{}, 0, resolveErr
+		}
+		center, err = place.Point(), nil
+	}
+	if err != nil {
+		return geo.Point{}, 0, err
+	}
+	radius := float64(roasts.DefaultRadiusKm)
+	if radiusParam != "" {
+		radius, err = strconv.ParseFloat(radiusParam, 64)
+		if err != nil {
+			return geo.Point{}, 0, errors.New("radius must be a number")
+		}
+	}
+	if radius <= 0 || radius > geo.MaxRadiusKm {
+		return geo.Point{}, 0, roasts.ErrInvalidRadius
+	}
+	return center, radius, nil
+}
+
+// rankedRoastsParams are the query parameters of the ranked roast listings
+type rankedRoastsParams struct {
+	Location string  `query:"location"`
+	MinPrice int     `query:"minPrice"`
+	MaxPrice int     `query:"maxPrice"`
+	MinValue float64 `query:"minValue"`
+	Limit    int     `query:"limit"`
+}
+
+// @Summary get the top ranked roasts
+// @ID get-top-roasts
+// @Tags roasts
+// @Produce json
+// @Param location query string false "only roasts whose location contains this"
+// @Param minPrice query int false "minimum price range"
+// @Param maxPrice query int false "maximum price range"
+// @Param minValue query number false "only roasts with at least this value score"
+// @Param limit query int false "number of roasts to return, defaults to 10"
+// @Success 200 {object} []database.Roast
+// @Failure 400 {object} message
+// @Failure 500 {object} message
+// @Router /roasts/top [get]
+func (app *Config) getTopRoastsHandler(c echo.Context) error {
+	return app.rankedRoasts(c, roasts.SortRanking)
+}
+
+// @Summary get the best value roasts
+// @ID get-value-roasts
+// @Tags roasts
+// @Produce json
+// @Param location query string false "only roasts whose location contains this"
+// @Param minPrice query int false "minimum price range"
+// @Param maxPrice query int false "maximum price range"
+// @Param minValue query number false "only roasts with at least this value score"
+// @Param limit query int false "number of roasts to return, defaults to 10"
+// @Success 200 {object} []database.Roast
+// @Failure 400 {object} message
+// @Failure 500 {object} message
+// @Router /roasts/value [get]
+func (app *Config) getValueRoastsHandler(c echo.Context) error {
+	return app.rankedRoasts(c, roasts.SortValue)
+}
+
+// rankedRoasts responds with the reviewed roasts matching the query, best first by sortBy
+func (app *Config) rankedRoasts(c echo.Context, sortBy string) error {
+	correlationId := c.Get("correlationID")
+	var params rankedRoastsParams
+	if err := c.Bind(&params); err != nil {
+		errMsg := "error binding request"
+		app.Logger.Error(errMsg, "error", err, "correlationID", correlationId)
+		return c.JSON(http.StatusBadRequest, message{Message: errMsg})
+	}
+	if params.Limit <= 0 {
+		params.Limit = 10
+	}
+
+	allRoasts, err := app.RoastModels.GetAllRoasts()
+	if err != nil {
+		errMsg := "Error getting all roasts from dynamodb"
+		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+
+	// Scores are set before filtering as roasts are filtered by value, from the same cached figures as everywhere else
+	for i := range allRoasts {
+		app.setScores(&allRoasts[i])
+	}
+	filter := roasts.Filter{
+		Location: params.Location,
+		MinPrice: params.MinPrice,
+		MaxPrice: params.MaxPrice,
+		MinValue: params.MinValue,
+		Reviewed: true,
+	}
+	rankedRoasts := filter.Apply(allRoasts)
+	now := time.Now()
+	for i := range rankedRoasts {
+		ratings.SetCurrentRatings(&rankedRoasts[i], app.ratingSettings(), now)
+	}
+	if err := roasts.Sort(rankedRoasts, sortBy); err != nil {
+		errMsg := "error sorting roasts"
+		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+	if len(rankedRoasts) > params.Limit {
+		rankedRoasts = rankedRoasts[:params.Limit]
+	}
+	app.Calendar.SetNextServing(rankedRoasts, now)
+
+	app.Logger.Info("ranked roasts returned", "sort", sortBy, "correlationID", correlationId)
+	return c.JSON(http.StatusOK, rankedRoasts)
+}
+
 // @Summary save roast
 // @ID save-roast
 // @Tags roasts
@@ -234,6 +534,8 @@ func (app *Config) createReviewHandler(c echo.Context) error {
 	}
 
 	newReview.RoastKey = "ROAST#" + newReview.RoastID
+	// The date decides the review's trend month and decay so is never taken from the client
+	newReview.DateAdded = int(time.Now().UnixMilli())
 	app.Logger.Info("review request received: ", "payload", newReview, "correlationID", correlationId)
 
 	fmt.Println("context UserID", userID)
@@ -242,22 +544,129 @@ func (app *Config) createReviewHandler(c echo.Context) error {
 	if userID != newReview.UserID {
 		return fmt.Errorf("uid in jwt doesn't match request data")
 	}
+	reviewTags, ok, err := app.normaliseTags(c, newReview.Tags)
+	if !ok {
+		return err
+	}
+	newReview.Tags = reviewTags
+
+	// A review key that already exists means the review is being edited, so its old ratings need replacing
+	oldReview, err := app.ReviewModels.GetReviewByKey(newReview.RoastKey, newReview.ReviewKey)
+	if err != nil && !errors.Is(err, database.ErrReviewNotFound) {
+		errMsg := "error getting review"
+		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": errMsg})
+	}
+	if oldReview != nil && oldReview.UserID != newReview.UserID {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "review belongs to another user"})
+	}
+
+	// Reviews are weighed and dated once when they're created, edits keep the original weight and date.
+	// Photos are only changed through the review photo endpoints.
+	var deviation float64
+	var hasDeviation bool
+	newReview.Photos = nil
+	if oldReview != nil {
+		newReview.Weight = oldReview.Weight
+		newReview.DateAdded = oldReview.DateAdded
+		newReview.Photos = oldReview.Photos
+	} else if app.Ratings.Weighting {
+		deviation, hasDeviation, err = ratings.WeighReview(app.RoastModels, app.UserModels, &newReview)
+		if err != nil {
+			errMsg := "error weighing review"
+			app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": errMsg})
+		}
+	}
+
 	if err := app.ReviewModels.CreateReview(newReview); err != nil {
 		errMsg := "error creating review"
 		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": errMsg})
 	}
+	app.Search.PutReview(newReview)
 
-	app.Logger.Info("review created", "correlationID", correlationId)
-	err := ratings.UpdateAverages(app.RoastModels, newReview, "plusCount")
+	if oldReview != nil {
+		app.Logger.Info("review edited", "correlationID", correlationId)
+		err = ratings.ReplaceReview(app.RoastModels, *oldReview, newReview, app.ratingSettings())
+	} else {
+		app.Logger.Info("review created", "correlationID", correlationId)
+		err = ratings.UpdateAverages(app.RoastModels, newReview, "plusCount", app.ratingSettings())
+	}
 	if err != nil {
 		errMsg := "error updating averages"
 		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": errMsg})
 	}
+
+	if hasDeviation {
+		// The review is already counted so a failure here only affects how future reviews are weighed
+		if err := app.UserModels.AddReviewDeviation(newReview.UserID, deviation); err != nil {
+			app.Logger.Error("error recording review deviation", "err", err, "correlationID", correlationId)
+		}
+	}
 	return c.JSON(http.StatusOK, newReview)
 }
 
+// roastTrends is a roast's monthly average for each criterion and its average over the last 90 days
+type roastTrends struct {
+	RoastID    string               `json:"roastID"`
+	Series     []ratings.TrendPoint `json:"series"`
+	Last90Days map[string]float64   `json:"last90Days,omitempty"`
+}
+
+// @Summary get a roast's rating trends
+// @ID get-roast-trends
+// @Tags roasts
+// @Produce json
+// @Param months query int false "number of months to return, defaults to 12"
+// @Success 200 {object} roastTrends
+// @Failure 400 {object} message
+// @Failure 404 {object} message
+// @Failure 500 {object} message
+// @Router /roast/{roastID}/trends [get]
+func (app *Config) getRoastTrendsHandler(c echo.Context) error {
+	correlationId := c.Get("correlationID")
+
+	months := 12
+	if param := c.QueryParam("months"); param != "" {
+		var err error
+		months, err = strconv.Atoi(param)
+		if err != nil || months < 1 || months > 120 {
+			return c.JSON(http.StatusBadRequest, message{Message: "months must be between 1 and 120"})
+		}
+	}
+
+	roast, err := app.RoastModels.GetRoastByID(c.Param("roastID"))
+	if err != nil {
+		errMsg := "error getting roast"
+		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+	if roast == nil {
+		return c.JSON(http.StatusNotFound, message{Message: "roast not found"})
+	}
+	roastID, roastKey := roast.RoastID, roast.RoastKey
+
+	now := time.Now()
+	from := now.AddDate(0, -(months - 1), 0)
+	// Fetch from whichever is earlier so the 90 day average is available however few months are requested
+	fromMonth := min(ratings.Month(from), ratings.RecentFromMonth(now))
+	trends, err := app.RoastModels.GetTrends(roastKey, fromMonth)
+	if err != nil {
+		errMsg := "error getting roast trends"
+		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+
+	app.Logger.Info("roast trends returned", "roastID", roastID, "correlationID", correlationId)
+	return c.JSON(http.StatusOK, roastTrends{
+		RoastID:    roastID,
+		Series:     ratings.TrendSeries(trends, from, now),
+		Last90Days: ratings.RecentAverages(trends, now),
+	})
+}
+
 // @Summary get reviews for a roast
 // @ID  get-roast-reviews
 // @Tags reviews
@@ -268,7 +677,12 @@ func (app *Config) createReviewHandler(c echo.Context) error {
 // @Router /reviews/{roastID} [get]
 func (app *Config) getReviewsHandler(c echo.Context) error {
 	correlationId := c.Get("correlationID")
-	roastID := c.Param("roastID")
+	roastID, err := app.RoastModels.ResolveRoastID(c.Param("roastID"))
+	if err != nil {
+		errMsg := "error getting roast"
+		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": errMsg})
+	}
 	roastKey := "ROAST#" + roastID
 
 	roastReviews, err := app.ReviewModels.GetReviewsByRoast(roastKey)
@@ -321,13 +735,15 @@ func (app *Config) removeReviewHandler(c echo.Context) error {
 		app.Logger.Error(errMsg, "error", err, "correlationID", correlationId)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": errMsg})
 	}
+	app.Search.RemoveReview(requestData.RoastID, requestData.ReviewKey)
 
-	err = ratings.UpdateAverages(app.RoastModels, *oldReview, "minusCount")
+	err = ratings.UpdateAverages(app.RoastModels, *oldReview, "minusCount", app.ratingSettings())
 	if err != nil {
 		errMsg := "error updating averages"
 		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": errMsg})
 	}
+	app.deletePhotos(oldReview.Photos, correlationId)
 	app.Logger.Info("review removed", "correlationID", correlationId)
 	return c.JSON(http.StatusOK, requestData.ReviewKey)
 }
@@ -416,25 +832,18 @@ func (app *Config) updateUserSettingsHandler(c echo.Context) error {
 	return c.JSON(http.StatusOK, "okay")
 }
 
+// @Summary get a presigned POST to upload an image with
+// @ID new-image
+// @Tags images
+// @Produce json
+// @Param contentType query string true "image/jpeg, image/png or image/webp"
+// @Param size query int true "size of the image in bytes"
+// @Success 200 {object} uploadResponse
+// @Failure 400 {object} message
+// @Failure 429 {object} message
+// @Failure 500 {object} message
+// @Router /newImage [get]
 func (app *Config) uploadImage(c echo.Context) error {
-	correlationId := c.Get("correlationID")
-	bucketName := app.ImageBucket
-	objectKey := fmt.Sprintf("upload/%d", time.Now().Unix())
-	expiry := 30 * time.Minute
-	imageType := "image/jpeg"
-
-	presignedURL, err := app.S3.GeneratePresignedURL(bucketName, objectKey, imageType, expiry)
-	if err != nil {
-		errMsg := "error creating presigned URL"
-		app.Logger.Error("failed to generate presigned URL", "error", err, "correlationID", correlationId)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": errMsg})
-	}
-
-	response := map[string]string{
-		"presignedURL": presignedURL,
-		"objectKey":    objectKey,
-	}
-	fmt.Println("presigned URL", presignedURL)
-
-	return c.JSON(http.StatusOK, response)
+	userID, _ := c.Get("userID").(string)
+	return app.presignUpload(c, images.NewUploadKey(userID))
 }
@@ -6,14 +6,19 @@ import (
 	"log/slog"
 	"os"
 
-	"github.com/94DanielBrown/awsapp"
-	s3 "github.com/94DanielBrown/awsapp/pkg/s3"
 	_ "github.com/94DanielBrown/roasts-api/cmd/app/docs"
 	"github.com/94DanielBrown/roasts-api/config"
 	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/images"
+	"github.com/94DanielBrown/roasts-api/internal/places"
+	"github.com/94DanielBrown/roasts-api/internal/ratings"
+	"github.com/94DanielBrown/roasts-api/internal/search"
 	"github.com/94DanielBrown/roasts-api/internal/utils"
+	"github.com/94DanielBrown/roasts-api/internal/venues"
 	"github.com/94DanielBrown/roasts-api/pkg/apikey"
+	"github.com/94DanielBrown/roasts-api/pkg/dynamo"
 	"github.com/94DanielBrown/roasts-api/pkg/firebase"
+	"github.com/94DanielBrown/roasts-api/pkg/storage"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -26,9 +31,16 @@ type Config struct {
 	RoastModels  database.RoastModels
 	ReviewModels database.ReviewModels
 	UserModels   database.UserModels
+	APIKeyModels database.APIKeyModels
+	TagModels    database.TagModels
+	Ratings      ratings.Settings
+	Stats        *tableStats
 	Logger       *slog.Logger
-	S3           *s3.Client
-	ImageBucket  string
+	Storage      storage.Store
+	Uploads      images.Limits
+	Places       *places.Gazetteer
+	Calendar     *venues.Calendar
+	Search       *search.Index
 }
 
 func (app *Config) routes() *echo.Echo {
@@ -40,10 +52,17 @@ func (app *Config) routes() *echo.Echo {
 	e.Use(utils.CorrelationID)
 
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
-	e.POST("/roast", app.createRoastHandler, apikey.Validate())
-	e.POST("/deleteRoast", app.deleteRoastHandler, apikey.Validate())
+	e.POST("/roast", app.createRoastHandler, apikey.Validate(&app.APIKeyModels))
+	e.POST("/deleteRoast", app.deleteRoastHandler, apikey.Validate(&app.APIKeyModels))
 	e.GET("/roasts", app.getAllRoastsHandler)
+	e.GET("/roasts/top", app.getTopRoastsHandler)
+	e.GET("/roasts/value", app.getValueRoastsHandler)
+	e.GET("/roasts/compare", app.compareRoastsHandler)
+	e.GET("/search", app.searchHandler)
 	e.GET("/roast/:roastID", app.getRoastHandler, firebase.FirebaseJWTMiddleware())
+	e.GET("/roast/:roastID/trends", app.getRoastTrendsHandler)
+	e.GET("/roast/:roastID/prices", app.getRoastPricesHandler)
+	e.POST("/roast/:roastID/venue", app.updateVenueHandler, apikey.Validate(&app.APIKeyModels))
 	e.POST("/saveRoast", app.saveRoastHandler, firebase.FirebaseJWTMiddleware())
 	e.POST("/removeRoast", app.removeRoastHandler, firebase.FirebaseJWTMiddleware())
 	//Add validator
@@ -56,7 +75,30 @@ func (app *Config) routes() *echo.Echo {
 	// use request body lots of things
 	e.GET("/userReviews/:userID", app.getUserReviewsHandler)
 	e.POST("/userSettings/:userID", app.updateUserSettingsHandler)
-	e.GET("/newImage", app.uploadImage)
+	e.GET("/newImage", app.uploadImage, firebase.FirebaseJWTMiddleware())
+	e.POST("/verifyImage", app.verifyImageHandler, firebase.FirebaseJWTMiddleware())
+	e.POST("/reviewPhotos/upload", app.uploadReviewPhotoHandler, firebase.FirebaseJWTMiddleware())
+	e.POST("/reviewPhotos/confirm", app.confirmReviewPhotoHandler, firebase.FirebaseJWTMiddleware())
+	e.POST("/reviewPhotos/reorder", app.reorderReviewPhotosHandler, firebase.FirebaseJWTMiddleware())
+	e.POST("/reviewPhotos/caption", app.captionReviewPhotoHandler, firebase.FirebaseJWTMiddleware())
+	e.POST("/reviewPhotos/remove", app.removeReviewPhotoHandler, firebase.FirebaseJWTMiddleware())
+	e.POST("/profilePhoto/upload", app.uploadProfilePhotoHandler, firebase.FirebaseJWTMiddleware())
+	e.POST("/profilePhoto/confirm", app.confirmProfilePhotoHandler, firebase.FirebaseJWTMiddleware())
+	e.POST("/profilePhoto/remove", app.removeProfilePhotoHandler, firebase.FirebaseJWTMiddleware())
+	// Local image storage is served by the API itself in place of a bucket
+	if _, ok := app.Storage.(*storage.Local); ok {
+		e.POST(storage.LocalUploadPath, app.localUploadHandler)
+		e.GET(storage.LocalObjectsPath+"*", app.localObjectHandler)
+	}
+	e.GET("/tags", app.getTagsHandler)
+	e.POST("/tagSuggestions", app.suggestTagHandler, firebase.FirebaseJWTMiddleware())
+	e.GET("/tagSuggestions", app.getTagSuggestionsHandler, firebase.FirebaseJWTMiddleware(), app.requireRole(database.RoleModerator, database.RoleAdmin))
+	e.POST("/tagSuggestions/moderate", app.moderateTagSuggestionHandler, firebase.FirebaseJWTMiddleware(), app.requireRole(database.RoleModerator, database.RoleAdmin))
+	e.POST("/admin/recompute", app.recomputeAggregatesHandler, app.requireAdmin())
+	e.POST("/admin/merge", app.mergeRoastsHandler, app.requireAdmin())
+	e.POST("/admin/tags", app.putTagCategoryHandler, app.requireAdmin())
+	e.POST("/admin/tags/remove", app.removeTagCategoryHandler, app.requireAdmin())
+	e.POST("/admin/roastTags", app.setRoastTagsHandler, app.requireAdmin())
 	return e
 }
 
@@ -71,28 +113,77 @@ func main() {
 
 	ctx := context.Background()
 
-	client, table, err := awsapp.InitDynamo(ctx, env.TableName)
+	client, err := dynamo.Connect()
 	if err != nil {
 		logger.Error("error setting up dynamo for app", "error", err)
 		os.Exit(1)
-	} else {
-		logger.Info(table)
 	}
 
-	s3Client, err := s3.Connect()
+	if err := dynamo.Validate(ctx, client, env.TableName); err != nil {
+		logger.Error("table schema check failed", "table", env.TableName, "error", err)
+		os.Exit(1)
+	}
+
+	store, err := storage.New(storage.Config{
+		Bucket:  env.ImageBucket,
+		Dir:     env.StorageDir,
+		Secret:  env.StorageSecret,
+		BaseURL: env.PublicURL,
+	})
+	if err != nil {
+		logger.Error("error setting up image storage for app", "error", err)
+		os.Exit(1)
+	}
+
+	gazetteer, err := places.Load(env.PlacesFile)
+	if err != nil {
+		logger.Error("error loading places", "error", err)
+		os.Exit(1)
+	}
+
+	calendar, err := venues.LoadCalendar(env.HolidaysFile)
 	if err != nil {
-		logger.Error("error setting up s3 for app", "error", err)
+		logger.Error("error loading holidays", "error", err)
+		os.Exit(1)
+	}
+
+	roastModels := database.NewRoastModels(client)
+	// A prior mean of 0 uses the global mean, which is kept up to date with the table stats
+	ratingSettings := ratings.Settings{
+		Prior:     ratings.Prior{Mean: env.RankingPriorMean, Weight: env.RankingPriorWeight},
+		HalfLife:  ratings.HalfLifeDays(env.RatingHalfLifeDays),
+		Weighting: env.RatingWeighting,
+	}
+	logger.Info("ranking prior", "mean", ratingSettings.Prior.Mean, "weight", ratingSettings.Prior.Weight, "halfLife", ratingSettings.HalfLife, "weighting", ratingSettings.Weighting)
+
+	reviewModels := database.NewReviewModels(client)
+	index := search.New()
+	if err := index.Load(roastModels, reviewModels); err != nil {
+		logger.Error("error building search index", "error", err)
 		os.Exit(1)
 	}
 
 	app := Config{
-		RoastModels:  database.NewRoastModels(client),
-		ReviewModels: database.NewReviewModels(client),
+		RoastModels:  roastModels,
+		ReviewModels: reviewModels,
 		UserModels:   database.NewUserModels(client),
+		APIKeyModels: database.NewAPIKeyModels(client),
+		TagModels:    database.NewTagModels(client),
+		Ratings:      ratingSettings,
+		Stats:        &tableStats{},
 		Logger:       logger,
-		S3:           s3Client,
-		ImageBucket:  env.ImageBucket,
+		Storage:      store,
+		Uploads:      images.Limits{MaxBytes: env.UploadMaxBytes, DailyUploads: env.UploadDailyLimit},
+		Places:       gazetteer,
+		Calendar:     calendar,
+		Search:       index,
+	}
+
+	if err := app.refreshStats(); err != nil {
+		logger.Error("error calculating table stats", "error", err)
+		os.Exit(1)
 	}
+	go app.refreshStatsEvery(statsRefreshInterval)
 
 	e := app.routes()
 	e.Logger.Fatal(e.Start(fmt.Sprintf(":%d", env.WebPort)))
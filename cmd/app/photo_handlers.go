@@ -0,0 +1,422 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/images"
+	"github.com/94DanielBrown/roasts-api/internal/reviews"
+	"github.com/94DanielBrown/roasts-api/pkg/s3post"
+	"github.com/94DanielBrown/roasts-api/pkg/storage"
+	"github.com/labstack/echo/v4"
+)
+
+// uploadExpiry is how long a presigned upload is valid for
+const uploadExpiry = 30 * time.Minute
+
+// errNotUploaded is returned when verifying an object that hasn't been uploaded
+var errNotUploaded = errors.New("image hasn't been uploaded")
+
+// processedImage maps each variant of an uploaded image to its URL
+type processedImage struct {
+	ObjectKey string            `json:"objectKey"`
+	Images    map[string]string `json:"images"`
+}
+
+// uploadResponse is a presigned POST for uploading an image to objectKey
+type uploadResponse struct {
+	*s3post.Post
+	ObjectKey string `json:"objectKey"`
+}
+
+// reviewPhotoRequest identifies a photo on one of the user's reviews
+type reviewPhotoRequest struct {
+	RoastID   string `json:"roastID"`
+	ReviewKey string `json:"reviewKey"`
+	Key       string `json:"key"`
+	Caption   string `json:"caption"`
+	// Order lists the keys of every photo on the review in their new order
+	Order []string `json:"order"`
+}
+
+// @Summary get a presigned POST to upload a review photo with
+// @ID upload-review-photo
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Param data body images.Upload true "content type and size of the photo"
+// @Success 200 {object} uploadResponse
+// @Failure 400 {object} message
+// @Failure 429 {object} message
+// @Failure 500 {object} message
+// @Router /reviewPhotos/upload [post]
+func (app *Config) uploadReviewPhotoHandler(c echo.Context) error {
+	userID, _ := c.Get("userID").(string)
+	return app.presignUpload(c, reviews.NewPhotoKey(userID))
+}
+
+// @Summary check an uploaded image is the type it was declared as and resize it
+// @ID verify-image
+// @Tags images
+// @Accept json
+// @Produce json
+// @Success 200 {object} processedImage
+// @Failure 400 {object} message
+// @Failure 403 {object} message
+// @Failure 500 {object} message
+// @Router /verifyImage [post]
+func (app *Config) verifyImageHandler(c echo.Context) error {
+	correlationId := c.Get("correlationID")
+	userID, _ := c.Get("userID").(string)
+	var request struct {
+		ObjectKey string `json:"objectKey"`
+	}
+	if err := c.Bind(&request); err != nil {
+		errMsg := "error binding request"
+		app.Logger.Error(errMsg, "error", err, "correlationID", correlationId)
+		return c.JSON(http.StatusBadRequest, message{Message: errMsg})
+	}
+	if userID == "" || !images.OwnsKey(images.UploadKeyPrefix(userID), request.ObjectKey) {
+		return c.JSON(http.StatusForbidden, message{Message: "image doesn't belong to user"})
+	}
+
+	if err := app.verifyUpload(request.ObjectKey); err != nil {
+		return app.uploadError(c, request.ObjectKey, err)
+	}
+	variants, err := app.processUpload(request.ObjectKey)
+	if err != nil {
+		return app.uploadError(c, request.ObjectKey, err)
+	}
+
+	app.Logger.Info("image processed", "key", request.ObjectKey, "correlationID", correlationId)
+	return c.JSON(http.StatusOK, processedImage{ObjectKey: request.ObjectKey, Images: variants})
+}
+
+// presignUpload validates the requested upload against the limits and the user's daily quota
+// and responds with a presigned POST for objectKey
+func (app *Config) presignUpload(c echo.Context, objectKey string) error {
+	correlationId := c.Get("correlationID")
+	userID, _ := c.Get("userID").(string)
+
+	var upload images.Upload
+	if err := c.Bind(&upload); err != nil {
+		errMsg := "error binding request"
+		app.Logger.Error(errMsg, "error", err, "correlationID", correlationId)
+		return c.JSON(http.StatusBadRequest, message{Message: errMsg})
+	}
+	if err := upload.Validate(app.Uploads); err != nil {
+		return c.JSON(http.StatusBadRequest, message{Message: err.Error()})
+	}
+
+	err := app.UserModels.UseUploadQuota(userID, time.Now(), app.Uploads.DailyUploads)
+	if errors.Is(err, database.ErrQuotaExceeded) {
+		return c.JSON(http.StatusTooManyRequests, message{Message: err.Error()})
+	}
+	if err != nil {
+		errMsg := "error checking upload quota"
+		app.Logger.Error(errMsg, "error", err, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+
+	post, err := app.Storage.PresignUpload(context.Background(), s3post.Policy{
+		Key:         objectKey,
+		ContentType: upload.ContentType,
+		MinSize:     1,
+		MaxSize:     upload.Size,
+		Expiry:      uploadExpiry,
+	})
+	if err != nil {
+		errMsg := "error creating presigned POST"
+		app.Logger.Error(errMsg, "error", err, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+
+	app.Logger.Info("image upload presigned", "key", objectKey, "contentType", upload.ContentType, "size", upload.Size, "correlationID", correlationId)
+	return c.JSON(http.StatusOK, uploadResponse{Post: post, ObjectKey: objectKey})
+}
+
+// verifyUpload checks an uploaded object's magic bytes match its content type, deleting it if they don't
+func (app *Config) verifyUpload(key string) error {
+	out, err := app.Storage.Get(context.Background(), key, images.SniffLength)
+	if errors.Is(err, storage.ErrNotFound) {
+		return errNotUploaded
+	}
+	if err != nil {
+		return fmt.Errorf("error getting uploaded image: %w", err)
+	}
+	defer out.Close()
+
+	header, err := io.ReadAll(out)
+	if err != nil {
+		return fmt.Errorf("error reading uploaded image: %w", err)
+	}
+	if err := images.Verify(header, out.ContentType); err != nil {
+		return errors.Join(err, app.deleteObject(key))
+	}
+	return nil
+}
+
+// processUpload resizes a verified upload into each variant, stored under keys derived from key.
+// The original is deleted as it may contain metadata such as the GPS location it was taken at.
+func (app *Config) processUpload(key string) (map[string]string, error) {
+	out, err := app.Storage.Get(context.Background(), key, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error getting uploaded image: %w", err)
+	}
+	defer out.Close()
+
+	// Uploads are limited by the POST policy, this only guards against objects written some other way
+	data, err := io.ReadAll(io.LimitReader(out, app.Uploads.MaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading uploaded image: %w", err)
+	}
+	if int64(len(data)) > app.Uploads.MaxBytes {
+		return nil, errors.Join(images.ErrTooLarge, app.deleteObject(key))
+	}
+
+	processed, err := images.Process(data)
+	if errors.Is(err, images.ErrUndecodable) {
+		return nil, errors.Join(err, app.deleteObject(key))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := images.VariantContentType(data)
+	variants := map[string]string{}
+	for name, variant := range processed {
+		variantKey := images.VariantKey(key, name)
+		err := app.Storage.Put(context.Background(), variantKey, variant, contentType, "public, max-age=31536000, immutable")
+		if err != nil {
+			return nil, fmt.Errorf("error storing %s variant: %w", name, err)
+		}
+		variants[name] = app.Storage.URL(variantKey)
+	}
+
+	if err := app.deleteObject(key); err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+// deleteObject deletes an object from image storage
+func (app *Config) deleteObject(key string) error {
+	return app.Storage.Delete(context.Background(), key)
+}
+
+// uploadError responds to a failed verifyUpload or processUpload
+func (app *Config) uploadError(c echo.Context, key string, err error) error {
+	if errors.Is(err, errNotUploaded) || errors.Is(err, images.ErrContentMismatch) ||
+		errors.Is(err, images.ErrUndecodable) || errors.Is(err, images.ErrTooLarge) {
+		return c.JSON(http.StatusBadRequest, message{Message: err.Error()})
+	}
+	errMsg := "error verifying uploaded image"
+	app.Logger.Error(errMsg, "err", err, "key", key, "correlationID", c.Get("correlationID"))
+	return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+}
+
+// @Summary attach an uploaded photo to a review
+// @ID confirm-review-photo
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Success 200 {object} database.Review
+// @Failure 400 {object} message
+// @Failure 403 {object} message
+// @Failure 404 {object} message
+// @Failure 500 {object} message
+// @Router /reviewPhotos/confirm [post]
+func (app *Config) confirmReviewPhotoHandler(c echo.Context) error {
+	request, review, err := app.bindReviewPhotoRequest(c)
+	if err != nil || review == nil {
+		return err
+	}
+
+	userID, _ := c.Get("userID").(string)
+	if !reviews.OwnsPhotoKey(userID, request.Key) {
+		return c.JSON(http.StatusForbidden, message{Message: reviews.ErrPhotoKeyInvalid.Error()})
+	}
+
+	// Checked before processing, which deletes the upload, so a photo that can't be added can be retried
+	if err := reviews.CanAddPhoto(review, request.Key, request.Caption); err != nil {
+		return c.JSON(http.StatusBadRequest, message{Message: err.Error()})
+	}
+	if err := app.verifyUpload(request.Key); err != nil {
+		return app.uploadError(c, request.Key, err)
+	}
+	variants, err := app.processUpload(request.Key)
+	if err != nil {
+		return app.uploadError(c, request.Key, err)
+	}
+
+	photo := database.Photo{Key: request.Key, URL: variants[images.VariantFull], Images: variants, Caption: request.Caption}
+	if err := reviews.AddPhoto(review, photo); err != nil {
+		return c.JSON(http.StatusBadRequest, message{Message: err.Error()})
+	}
+	_, err = app.saveReviewPhotos(c, review, 1)
+	return err
+}
+
+// @Summary reorder a review's photos
+// @ID reorder-review-photos
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Success 200 {object} database.Review
+// @Failure 400 {object} message
+// @Failure 403 {object} message
+// @Failure 404 {object} message
+// @Failure 500 {object} message
+// @Router /reviewPhotos/reorder [post]
+func (app *Config) reorderReviewPhotosHandler(c echo.Context) error {
+	request, review, err := app.bindReviewPhotoRequest(c)
+	if err != nil || review == nil {
+		return err
+	}
+
+	if err := reviews.ReorderPhotos(review, request.Order); err != nil {
+		return c.JSON(http.StatusBadRequest, message{Message: err.Error()})
+	}
+	_, err = app.saveReviewPhotos(c, review, 0)
+	return err
+}
+
+// @Summary set the caption of a review photo
+// @ID caption-review-photo
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Success 200 {object} database.Review
+// @Failure 400 {object} message
+// @Failure 403 {object} message
+// @Failure 404 {object} message
+// @Failure 500 {object} message
+// @Router /reviewPhotos/caption [post]
+func (app *Config) captionReviewPhotoHandler(c echo.Context) error {
+	request, review, err := app.bindReviewPhotoRequest(c)
+	if err != nil || review == nil {
+		return err
+	}
+
+	err = reviews.SetPhotoCaption(review, request.Key, request.Caption)
+	if errors.Is(err, reviews.ErrPhotoNotFound) {
+		return c.JSON(http.StatusNotFound, message{Message: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, message{Message: err.Error()})
+	}
+	_, err = app.saveReviewPhotos(c, review, 0)
+	return err
+}
+
+// @Summary remove a photo from a review
+// @ID remove-review-photo
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Success 200 {object} database.Review
+// @Failure 400 {object} message
+// @Failure 403 {object} message
+// @Failure 404 {object} message
+// @Failure 500 {object} message
+// @Router /reviewPhotos/remove [post]
+func (app *Config) removeReviewPhotoHandler(c echo.Context) error {
+	request, review, err := app.bindReviewPhotoRequest(c)
+	if err != nil || review == nil {
+		return err
+	}
+
+	removed, err := reviews.RemovePhoto(review, request.Key)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, message{Message: err.Error()})
+	}
+	// The removed photo's images are only deleted once the review no longer refers to them
+	if saved, err := app.saveReviewPhotos(c, review, -1); !saved {
+		return err
+	}
+
+	app.deletePhotos([]database.Photo{removed}, c.Get("correlationID"))
+	return nil
+}
+
+// deletePhotos deletes every variant of photos that have been detached from a review.
+// Failures are only logged as they leave unreferenced objects behind rather than broken reviews.
+func (app *Config) deletePhotos(photos []database.Photo, correlationId any) {
+	for _, photo := range photos {
+		app.deleteVariants(photo.Key, correlationId)
+	}
+}
+
+// deleteVariants deletes every variant processed from the upload at key, only logging failures
+func (app *Config) deleteVariants(key string, correlationId any) {
+	for _, variant := range images.Variants {
+		if err := app.deleteObject(images.VariantKey(key, variant.Name)); err != nil {
+			app.Logger.Error("error deleting image variant", "err", err, "key", key, "correlationID", correlationId)
+		}
+	}
+}
+
+// bindReviewPhotoRequest binds the request and loads the review it refers to, which must belong to the user.
+// If the review is nil a response has already been written and the returned error should be returned as is.
+func (app *Config) bindReviewPhotoRequest(c echo.Context) (reviewPhotoRequest, *database.Review, error) {
+	correlationId := c.Get("correlationID")
+	userID, _ := c.Get("userID").(string)
+
+	var request reviewPhotoRequest
+	if err := c.Bind(&request); err != nil {
+		errMsg := "error binding request"
+		app.Logger.Error(errMsg, "error", err, "correlationID", correlationId)
+		return request, nil, c.JSON(http.StatusBadRequest, message{Message: errMsg})
+	}
+	if request.RoastID == "" || request.ReviewKey == "" {
+		return request, nil, c.JSON(http.StatusBadRequest, message{Message: "roastID and reviewKey are required"})
+	}
+
+	review, err := app.ReviewModels.GetReviewByKey("ROAST#"+request.RoastID, request.ReviewKey)
+	if errors.Is(err, database.ErrReviewNotFound) {
+		return request, nil, c.JSON(http.StatusNotFound, message{Message: "review not found"})
+	}
+	if err != nil {
+		errMsg := "error getting review"
+		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+		return request, nil, c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+	if review.UserID != userID {
+		return request, nil, c.JSON(http.StatusForbidden, message{Message: "review belongs to another user"})
+	}
+	return request, review, nil
+}
+
+// saveReviewPhotos saves the review's photos, adds added to the roast's photo count and responds with the updated review.
+// saved is false if the photos couldn't be saved, in which case an error response has been written.
+func (app *Config) saveReviewPhotos(c echo.Context, review *database.Review, added int) (saved bool, err error) {
+	correlationId := c.Get("correlationID")
+	if err := app.ReviewModels.UpdateReviewPhotos(review.RoastKey, review.ReviewKey, review.Photos); err != nil {
+		errMsg := "error saving review photos"
+		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+		return false, c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+	if added != 0 {
+		app.addPhotoCount(review.RoastKey, added, correlationId)
+	}
+
+	app.Logger.Info("review photos updated", "reviewKey", review.ReviewKey, "photos", len(review.Photos), "correlationID", correlationId)
+	return true, c.JSON(http.StatusOK, review)
+}
+
+// addPhotoCount adds delta to a roast's photo count. The count only feeds the has photos filter,
+// so failures are logged and left for aggregates recompute to correct.
+func (app *Config) addPhotoCount(roastKey string, delta int, correlationId any) {
+	roast, err := app.RoastModels.GetRoastByPrefix(roastKey)
+	if err == nil && roast != nil {
+		err = app.RoastModels.AddPhotoCount(roast, delta)
+	}
+	if err != nil {
+		app.Logger.Error("error updating roast photo count", "err", err, "roastKey", roastKey, "correlationID", correlationId)
+	}
+}
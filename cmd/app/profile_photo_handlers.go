@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/images"
+	"github.com/labstack/echo/v4"
+)
+
+// @Summary get a presigned POST to upload a profile photo with
+// @ID upload-profile-photo
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param data body images.Upload true "content type and size of the photo"
+// @Success 200 {object} uploadResponse
+// @Failure 400 {object} message
+// @Failure 429 {object} message
+// @Failure 500 {object} message
+// @Router /profilePhoto/upload [post]
+func (app *Config) uploadProfilePhotoHandler(c echo.Context) error {
+	userID, _ := c.Get("userID").(string)
+	return app.presignUpload(c, images.NewProfileKey(userID))
+}
+
+// @Summary set an uploaded photo as the user's profile photo, replacing any existing one
+// @ID confirm-profile-photo
+// @Tags users
+// @Accept json
+// @Produce json
+// @Success 200 {object} database.User
+// @Failure 400 {object} message
+// @Failure 403 {object} message
+// @Failure 404 {object} message
+// @Failure 500 {object} message
+// @Router /profilePhoto/confirm [post]
+func (app *Config) confirmProfilePhotoHandler(c echo.Context) error {
+	correlationId := c.Get("correlationID")
+	userID, _ := c.Get("userID").(string)
+	var request struct {
+		ObjectKey string `json:"objectKey"`
+	}
+	if err := c.Bind(&request); err != nil {
+		errMsg := "error binding request"
+		app.Logger.Error(errMsg, "error", err, "correlationID", correlationId)
+		return c.JSON(http.StatusBadRequest, message{Message: errMsg})
+	}
+	if userID == "" || !images.OwnsKey(images.ProfileKeyPrefix(userID), request.ObjectKey) {
+		return c.JSON(http.StatusForbidden, message{Message: "image doesn't belong to user"})
+	}
+
+	user, err := app.profilePhotoUser(c, userID)
+	if err != nil || user == nil {
+		return err
+	}
+
+	if err := app.verifyUpload(request.ObjectKey); err != nil {
+		return app.uploadError(c, request.ObjectKey, err)
+	}
+	variants, err := app.processUpload(request.ObjectKey)
+	if err != nil {
+		return app.uploadError(c, request.ObjectKey, err)
+	}
+	return app.setProfilePhoto(c, userID, user, request.ObjectKey, variants)
+}
+
+// @Summary remove the user's profile photo
+// @ID remove-profile-photo
+// @Tags users
+// @Produce json
+// @Success 200 {object} database.User
+// @Failure 404 {object} message
+// @Failure 500 {object} message
+// @Router /profilePhoto/remove [post]
+func (app *Config) removeProfilePhotoHandler(c echo.Context) error {
+	userID, _ := c.Get("userID").(string)
+	user, err := app.profilePhotoUser(c, userID)
+	if err != nil || user == nil {
+		return err
+	}
+	return app.setProfilePhoto(c, userID, user, "", nil)
+}
+
+// profilePhotoUser loads the user whose profile photo is being changed.
+// If the user is nil a response has already been written and the returned error should be returned as is.
+func (app *Config) profilePhotoUser(c echo.Context, userID string) (*database.User, error) {
+	user, err := app.UserModels.GetUserByPrefix("USER#" + userID)
+	if err != nil {
+		errMsg := "error retrieving user"
+		app.Logger.Error(errMsg, "err", err, "userID", userID, "correlationID", c.Get("correlationID"))
+		return nil, c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+	if user == nil {
+		return nil, c.JSON(http.StatusNotFound, message{Message: "user not found"})
+	}
+	return user, nil
+}
+
+// setProfilePhoto saves the user's new profile photo, or removes it if key is empty, then deletes the
+// variants of the photo it replaced and shows the new one on all of the user's reviews
+func (app *Config) setProfilePhoto(c echo.Context, userID string, user *database.User, key string, variants map[string]string) error {
+	correlationId := c.Get("correlationID")
+	if err := app.UserModels.SetProfilePhoto(userID, key, variants); err != nil {
+		errMsg := "error saving profile photo"
+		app.Logger.Error(errMsg, "err", err, "userID", userID, "correlationID", correlationId)
+		// The new variants aren't referenced by anything now so would otherwise be left behind
+		if key != "" {
+			app.deleteVariants(key, correlationId)
+		}
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+
+	oldKey, oldURL := user.ProfilePhotoKey, user.ProfilePhotoUrl
+	user.ProfilePhotoKey, user.Images, user.ProfilePhotoUrl = key, variants, ""
+	if oldKey != "" && oldKey != key {
+		app.deleteVariants(oldKey, correlationId)
+	}
+	// Photos from before variants were stored only have a URL, deleted if it's one of the store's objects
+	if urlKey := images.URLKey(oldURL, app.Storage.PathPrefix()); urlKey != "" && app.Storage.URL(urlKey) == oldURL {
+		if err := app.deleteObject(urlKey); err != nil {
+			app.Logger.Error("error deleting old profile photo", "err", err, "key", urlKey, "correlationID", correlationId)
+		}
+	}
+
+	// The user's photo has already changed, so failing to update a review only leaves it showing the old one
+	if err := app.updateReviewerImages(userID, variants); err != nil {
+		app.Logger.Error("error updating profile photo on reviews", "err", err, "userID", userID, "correlationID", correlationId)
+	}
+
+	app.Logger.Info("profile photo updated", "userID", userID, "key", key, "correlationID", correlationId)
+	return c.JSON(http.StatusOK, user)
+}
+
+// updateReviewerImages sets the reviewer's profile photo on every review the user has made
+func (app *Config) updateReviewerImages(userID string, variants map[string]string) error {
+	userReviews, err := app.UserModels.GetUserReviews(userID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, review := range userReviews {
+		err := app.ReviewModels.UpdateReviewerImages(review.RoastKey, review.ReviewKey, variants)
+		// The review may have been removed since it was listed
+		if err != nil && !errors.Is(err, database.ErrReviewNotFound) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
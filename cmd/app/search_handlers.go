@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/ratings"
+	"github.com/94DanielBrown/roasts-api/internal/search"
+	"github.com/labstack/echo/v4"
+)
+
+// maxSearchResults caps the limit of a search, each result is a read of the roast
+const maxSearchResults = 50
+
+// searchResult is a roast matching a search with snippets of its matching reviews
+type searchResult struct {
+	Roast    *database.Roast  `json:"roast"`
+	Score    float64          `json:"score"`
+	Snippets []search.Snippet `json:"snippets,omitempty"`
+}
+
+// @Summary search roast names, locations and review comments
+// @ID search
+// @Tags roasts
+// @Produce json
+// @Param q query string true "search terms, the last word also matches words it's the start of"
+// @Param limit query int false "number of roasts to return, defaults to 10 and at most 50"
+// @Success 200 {object} []searchResult
+// @Failure 400 {object} message
+// @Failure 500 {object} message
+// @Router /search [get]
+func (app *Config) searchHandler(c echo.Context) error {
+	correlationId := c.Get("correlationID")
+	var params struct {
+		Query string `query:"q"`
+		Limit int    `query:"limit"`
+	}
+	if err := c.Bind(&params); err != nil {
+		errMsg := "error binding request"
+		app.Logger.Error(errMsg, "error", err, "correlationID", correlationId)
+		return c.JSON(http.StatusBadRequest, message{Message: errMsg})
+	}
+	if strings.TrimSpace(params.Query) == "" {
+		return c.JSON(http.StatusBadRequest, message{Message: "q is required"})
+	}
+	if params.Limit <= 0 {
+		params.Limit = 10
+	}
+	params.Limit = min(params.Limit, maxSearchResults)
+
+	now := time.Now()
+	results := []searchResult{}
+	for _, result := range app.Search.Search(params.Query, params.Limit) {
+		roast, err := app.RoastModels.GetRoastByPrefix("ROAST#" + result.RoastID)
+		if err != nil {
+			errMsg := "error getting roast"
+			app.Logger.Error(errMsg, "error", err, "roastID", result.RoastID, "correlationID", correlationId)
+			return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+		}
+		// Roasts deleted with roastctl stay in the index until the API restarts
+		if roast == nil {
+			continue
+		}
+		ratings.SetCurrentRatings(roast, app.ratingSettings(), now)
+		app.setScores(roast)
+		roast.NextServing = app.Calendar.NextServing(roast, now)
+		results = append(results, searchResult{Roast: roast, Score: result.Score, Snippets: result.Snippets})
+	}
+
+	app.Logger.Info("search results returned", "results", len(results), "correlationID", correlationId)
+	return c.JSON(http.StatusOK, results)
+}
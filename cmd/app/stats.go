@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/ratings"
+)
+
+// statsRefreshInterval is how often figures taken across every roast are recalculated
+const statsRefreshInterval = 15 * time.Minute
+
+// tableStats caches figures taken across every roast, which would otherwise need a scan of the table each time
+type tableStats struct {
+	mu sync.RWMutex
+	// globalMean is the mean overall rating of every review, the ranking prior's mean when one isn't configured
+	globalMean float64
+	// bestValue is the best rating for the price of every roast, which value scores are relative to
+	bestValue float64
+}
+
+// refreshStats recalculates the figures taken across every roast
+func (app *Config) refreshStats() error {
+	allRoasts, err := app.RoastModels.GetAllRoasts()
+	if err != nil {
+		return fmt.Errorf("error getting all roasts: %w", err)
+	}
+	globalMean := ratings.GlobalMean(allRoasts)
+	settings := app.Ratings
+	if settings.Prior.Mean == 0 {
+		settings.Prior.Mean = globalMean
+	}
+	// Value is scored from the ranking score, which isn't stored
+	for i := range allRoasts {
+		ratings.SetRankingScore(&allRoasts[i], settings)
+	}
+	bestValue := ratings.BestValue(allRoasts)
+
+	app.Stats.mu.Lock()
+	app.Stats.globalMean, app.Stats.bestValue = globalMean, bestValue
+	app.Stats.mu.Unlock()
+	app.Logger.Info("table stats refreshed", "roasts", len(allRoasts), "globalMean", globalMean, "bestValue", bestValue)
+	return nil
+}
+
+// refreshStatsEvery refreshes the stats until the process exits, keeping the last ones if a refresh fails
+func (app *Config) refreshStatsEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := app.refreshStats(); err != nil {
+			app.Logger.Error("error refreshing table stats", "err", err)
+		}
+	}
+}
+
+// ratingSettings are the configured rating settings with the prior's mean filled in from the latest
+// global mean if it isn't configured
+func (app *Config) ratingSettings() ratings.Settings {
+	settings := app.Ratings
+	if settings.Prior.Mean == 0 {
+		app.Stats.mu.RLock()
+		settings.Prior.Mean = app.Stats.globalMean
+		app.Stats.mu.RUnlock()
+	}
+	return settings
+}
+
+// setScores sets a roast's ranking score against the latest prior and its value score against the best
+// value of every roast, neither is stored as they depend on every other roast
+func (app *Config) setScores(roast *database.Roast) {
+	ratings.SetRankingScore(roast, app.ratingSettings())
+	app.Stats.mu.RLock()
+	bestValue := app.Stats.bestValue
+	app.Stats.mu.RUnlock()
+	roast.ValueScore = ratings.ValueScore(roast, bestValue)
+}
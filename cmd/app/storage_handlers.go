@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/94DanielBrown/roasts-api/pkg/storage"
+	"github.com/labstack/echo/v4"
+)
+
+// @Summary upload an image to local storage with the fields of a presigned upload, in place of posting to S3
+// @ID local-storage-upload
+// @Tags images
+// @Accept mpfd
+// @Success 204
+// @Failure 400 {object} message
+// @Failure 403 {object} message
+// @Failure 500 {object} message
+// @Router /localStorage/upload [post]
+func (app *Config) localUploadHandler(c echo.Context) error {
+	correlationId := c.Get("correlationID")
+	local, _ := app.Storage.(*storage.Local)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, message{Message: "file is required"})
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		errMsg := "error reading upload"
+		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+	defer file.Close()
+
+	fields := map[string]string{}
+	for _, name := range []string{"key", "Content-Type", "policy", "signature"} {
+		fields[name] = c.FormValue(name)
+	}
+
+	err = local.Receive(context.Background(), fields, file)
+	switch {
+	case errors.Is(err, storage.ErrInvalidToken) || errors.Is(err, storage.ErrTokenExpired):
+		return c.JSON(http.StatusForbidden, message{Message: err.Error()})
+	case errors.Is(err, storage.ErrPolicyViolation):
+		return c.JSON(http.StatusBadRequest, message{Message: err.Error()})
+	case err != nil:
+		errMsg := "error storing upload"
+		app.Logger.Error(errMsg, "err", err, "key", fields["key"], "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+
+	app.Logger.Info("local upload stored", "key", fields["key"], "correlationID", correlationId)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary download an image from local storage
+// @ID local-storage-object
+// @Tags images
+// @Success 200
+// @Failure 404 {object} message
+// @Failure 500 {object} message
+// @Router /localStorage/objects/{key} [get]
+func (app *Config) localObjectHandler(c echo.Context) error {
+	key := c.Param("*")
+	object, err := app.Storage.Get(context.Background(), key, 0)
+	if errors.Is(err, storage.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, message{Message: "object not found"})
+	}
+	if err != nil {
+		errMsg := "error getting object"
+		app.Logger.Error(errMsg, "err", err, "key", key, "correlationID", c.Get("correlationID"))
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+	defer object.Close()
+
+	if object.CacheControl != "" {
+		c.Response().Header().Set("Cache-Control", object.CacheControl)
+	}
+	return c.Stream(http.StatusOK, object.ContentType, object)
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"sort"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/tags"
+	"github.com/labstack/echo/v4"
+)
+
+// tagSuggestionRequest is a tag suggested for a roast, or the suggestion being moderated
+type tagSuggestionRequest struct {
+	RoastID string `json:"roastID"`
+	Tag     string `json:"tag"`
+	// Approve is only used when moderating, false rejects the suggestion
+	Approve bool `json:"approve"`
+}
+
+// pendingTag is every user's suggestion of the same tag for a roast
+type pendingTag struct {
+	RoastID string `json:"roastID"`
+	Tag     string `json:"tag"`
+	// Suggestions is how many users have suggested the tag
+	Suggestions int `json:"suggestions"`
+	// FirstSuggested is when the tag was first suggested in epoch milliseconds
+	FirstSuggested int64 `json:"firstSuggested"`
+}
+
+// @Summary get the tag vocabulary
+// @ID get-tags
+// @Tags tags
+// @Produce json
+// @Success 200 {object} []database.TagCategory
+// @Failure 500 {object} message
+// @Router /tags [get]
+func (app *Config) getTagsHandler(c echo.Context) error {
+	vocabulary, err := app.vocabulary(c)
+	if err != nil || vocabulary == nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, vocabulary.Categories)
+}
+
+// @Summary create or replace a tag category
+// @ID put-tag-category
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param data body database.TagCategory true "category and all of its tags"
+// @Success 200 {object} database.TagCategory
+// @Failure 400 {object} message
+// @Failure 500 {object} message
+// @Router /admin/tags [post]
+func (app *Config) putTagCategoryHandler(c echo.Context) error {
+	correlationId := c.Get("correlationID")
+	var category database.TagCategory
+	if err := c.Bind(&category); err != nil {
+		errMsg := "error binding request"
+		app.Logger.Error(errMsg, "error", err, "correlationID", correlationId)
+		return c.JSON(http.StatusBadRequest, message{Message: errMsg})
+	}
+
+	vocabulary, err := app.vocabulary(c)
+	if err != nil || vocabulary == nil {
+		return err
+	}
+	if err := vocabulary.CheckCategory(category); err != nil {
+		return c.JSON(http.StatusBadRequest, message{Message: err.Error()})
+	}
+	if category.Tags == nil {
+		category.Tags = []database.Tag{}
+	}
+
+	if err := app.TagModels.PutTagCategory(category); err != nil {
+		errMsg := "error saving tag category"
+		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+
+	app.Logger.Info("tag category saved", "categoryID", category.ID, "tags", len(category.Tags), "correlationID", correlationId)
+	return c.JSON(http.StatusOK, category)
+}
+
+// @Summary remove a tag category, roasts and reviews keep the tags they already have
+// @ID remove-tag-category
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Success 200 {object} message
+// @Failure 400 {object} message
+// @Failure 500 {object} message
+// @Router /admin/tags/remove [post]
+func (app *Config) removeTagCategoryHandler(c echo.Context) error {
+	correlationId := c.Get("correlationID")
+	var request struct {
+		ID string `json:"id"`
+	}
+	if err := c.Bind(&request); err != nil || request.ID == "" {
+		return c.JSON(http.StatusBadRequest, message{Message: "id is required"})
+	}
+
+	if err := app.TagModels.DeleteTagCategory(request.ID); err != nil {
+		errMsg := "error removing tag category"
+		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+
+	app.Logger.Info("tag category removed", "categoryID", request.ID, "correlationID", correlationId)
+	return c.JSON(http.StatusOK, message{Message: "tag category removed"})
+}
+
+// @Summary replace the tags of a roast
+// @ID set-roast-tags
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Success 200 {object} database.Roast
+// @Failure 400 {object} message
+// @Failure 404 {object} message
+// @Failure 500 {object} message
+// @Router /admin/roastTags [post]
+func (app *Config) setRoastTagsHandler(c echo.Context) error {
+	correlationId := c.Get("correlationID")
+	var request struct {
+		RoastID string   `json:"roastID"`
+		Tags    []string `json:"tags"`
+	}
+	if err := c.Bind(&request); err != nil {
+		errMsg := "error binding request"
+		app.Logger.Error(errMsg, "error", err, "correlationID", correlationId)
+		return c.JSON(http.StatusBadRequest, message{Message: errMsg})
+	}
+
+	roast, roastTags, err := app.taggedRoast(c, request.RoastID, request.Tags)
+	if err != nil || roast == nil {
+		return err
+	}
+	if err := app.RoastModels.SetRoastTags(roast, roastTags); err != nil {
+		errMsg := "error saving roast tags"
+		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+
+	app.Logger.Info("roast tags set", "roastID", roast.RoastID, "tags", roastTags, "correlationID", correlationId)
+	return c.JSON(http.StatusOK, roast)
+}
+
+// @Summary suggest a tag for a roast, it's added once a moderator approves it
+// @ID suggest-tag
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Success 200 {object} database.TagSuggestion
+// @Failure 400 {object} message
+// @Failure 404 {object} message
+// @Failure 409 {object} message
+// @Failure 500 {object} message
+// @Router /tagSuggestions [post]
+func (app *Config) suggestTagHandler(c echo.Context) error {
+	correlationId := c.Get("correlationID")
+	userID, _ := c.Get("userID").(string)
+	var request tagSuggestionRequest
+	if err := c.Bind(&request); err != nil {
+		errMsg := "error binding request"
+		app.Logger.Error(errMsg, "error", err, "correlationID", correlationId)
+		return c.JSON(http.StatusBadRequest, message{Message: errMsg})
+	}
+
+	roast, suggested, err := app.taggedRoast(c, request.RoastID, []string{request.Tag})
+	if err != nil || roast == nil {
+		return err
+	}
+	if slices.Contains(roast.Tags, suggested[0]) {
+		return c.JSON(http.StatusBadRequest, message{Message: "roast already has this tag"})
+	}
+
+	suggestion := database.TagSuggestion{RoastID: roast.RoastID, Tag: suggested[0], UserID: userID}
+	err = app.TagModels.CreateTagSuggestion(suggestion)
+	if errors.Is(err, database.ErrAlreadySuggested) {
+		return c.JSON(http.StatusConflict, message{Message: err.Error()})
+	}
+	if err != nil {
+		errMsg := "error saving tag suggestion"
+		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+
+	app.Logger.Info("tag suggested", "roastID", roast.RoastID, "tag", suggestion.Tag, "correlationID", correlationId)
+	return c.JSON(http.StatusOK, suggestion)
+}
+
+// @Summary list the tag suggestions waiting for moderation, most suggested first
+// @ID get-tag-suggestions
+// @Tags tags
+// @Produce json
+// @Success 200 {object} []pendingTag
+// @Failure 403 {object} message
+// @Failure 500 {object} message
+// @Router /tagSuggestions [get]
+func (app *Config) getTagSuggestionsHandler(c echo.Context) error {
+	correlationId := c.Get("correlationID")
+	suggestions, err := app.TagModels.GetTagSuggestions()
+	if err != nil {
+		errMsg := "error getting tag suggestions"
+		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+
+	pending := []pendingTag{}
+	index := map[[2]string]int{}
+	for _, suggestion := range suggestions {
+		key := [2]string{suggestion.RoastID, suggestion.Tag}
+		i, ok := index[key]
+		if !ok {
+			i = len(pending)
+			index[key] = i
+			pending = append(pending, pendingTag{RoastID: suggestion.RoastID, Tag: suggestion.Tag, FirstSuggested: suggestion.CreatedAt})
+		}
+		pending[i].Suggestions++
+		pending[i].FirstSuggested = min(pending[i].FirstSuggested, suggestion.CreatedAt)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].Suggestions != pending[j].Suggestions {
+			return pending[i].Suggestions > pending[j].Suggestions
+		}
+		return pending[i].FirstSuggested < pending[j].FirstSuggested
+	})
+
+	app.Logger.Info("tag suggestions returned", "pending", len(pending), "correlationID", correlationId)
+	return c.JSON(http.StatusOK, pending)
+}
+
+// @Summary approve or reject every suggestion of a tag for a roast
+// @ID moderate-tag-suggestion
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Success 200 {object} message
+// @Failure 400 {object} message
+// @Failure 403 {object} message
+// @Failure 404 {object} message
+// @Failure 500 {object} message
+// @Router /tagSuggestions/moderate [post]
+func (app *Config) moderateTagSuggestionHandler(c echo.Context) error {
+	correlationId := c.Get("correlationID")
+	var request tagSuggestionRequest
+	if err := c.Bind(&request); err != nil {
+		errMsg := "error binding request"
+		app.Logger.Error(errMsg, "error", err, "correlationID", correlationId)
+		return c.JSON(http.StatusBadRequest, message{Message: errMsg})
+	}
+
+	// Suggestions were stored normalised, rejecting one doesn't need its tag to still be in the vocabulary
+	request.Tag = tags.NormaliseTag(request.Tag)
+	var roast *database.Roast
+	if request.Approve {
+		var err error
+		// Checked before the suggestions are removed so they're kept if the tag can't be approved
+		roast, _, err = app.taggedRoast(c, request.RoastID, []string{request.Tag})
+		if err != nil || roast == nil {
+			return err
+		}
+	}
+
+	// Suggestions are removed before tagging so a roast isn't tagged from a suggestion that's already been moderated
+	removed, err := app.TagModels.DeleteTagSuggestions(request.RoastID, request.Tag)
+	if err != nil {
+		errMsg := "error removing tag suggestions"
+		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+	if removed == 0 {
+		return c.JSON(http.StatusNotFound, message{Message: "no suggestions of this tag for the roast"})
+	}
+	if !request.Approve {
+		app.Logger.Info("tag suggestion rejected", "roastID", request.RoastID, "tag", request.Tag, "correlationID", correlationId)
+		return c.JSON(http.StatusOK, message{Message: "tag suggestion rejected"})
+	}
+
+	if !slices.Contains(roast.Tags, request.Tag) {
+		if err := app.RoastModels.SetRoastTags(roast, append(roast.Tags, request.Tag)); err != nil {
+			errMsg := "error saving roast tags"
+			app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+			return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+		}
+	}
+
+	app.Logger.Info("tag suggestion approved", "roastID", roast.RoastID, "tag", request.Tag, "correlationID", correlationId)
+	return c.JSON(http.StatusOK, message{Message: "tag suggestion approved"})
+}
+
+// vocabulary loads the tag vocabulary. A nil vocabulary means the error response has been written.
+func (app *Config) vocabulary(c echo.Context) (*tags.Vocabulary, error) {
+	vocabulary, err := tags.Load(app.TagModels)
+	if err != nil {
+		errMsg := "error getting tags"
+		app.Logger.Error(errMsg, "err", err, "correlationID", c.Get("correlationID"))
+		return nil, c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+	return vocabulary, nil
+}
+
+// normaliseTags checks tags against the vocabulary. If ok is false the error response has been written.
+func (app *Config) normaliseTags(c echo.Context, requested []string) (normalised []string, ok bool, err error) {
+	if len(requested) == 0 {
+		return nil, true, nil
+	}
+	vocabulary, err := app.vocabulary(c)
+	if err != nil || vocabulary == nil {
+		return nil, false, err
+	}
+	normalised, err = vocabulary.Normalise(requested)
+	if err != nil {
+		return nil, false, c.JSON(http.StatusBadRequest, message{Message: err.Error()})
+	}
+	return normalised, true, nil
+}
+
+// taggedRoast looks up a roast and checks tags against the vocabulary. A nil roast means the error
+// response has been written.
+func (app *Config) taggedRoast(c echo.Context, roastID string, requested []string) (*database.Roast, []string, error) {
+	normalised, ok, err := app.normaliseTags(c, requested)
+	if !ok {
+		return nil, nil, err
+	}
+
+	roast, err := app.RoastModels.GetRoastByPrefix("ROAST#" + roastID)
+	if err != nil {
+		errMsg := "error getting roast"
+		app.Logger.Error(errMsg, "err", err, "correlationID", c.Get("correlationID"))
+		return nil, nil, c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+	if roast == nil {
+		return nil, nil, c.JSON(http.StatusNotFound, message{Message: "roast not found"})
+	}
+	return roast, normalised, nil
+}
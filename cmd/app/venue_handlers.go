@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/venues"
+	"github.com/labstack/echo/v4"
+)
+
+// @Summary replace the venue details of a roast, changed prices are added to its price history
+// @ID update-venue
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param roastID path string true "roast ID"
+// @Param data body database.Venue true "serving times, booking, menu prices and contact details"
+// @Success 200 {object} database.Roast
+// @Failure 400 {object} message
+// @Failure 404 {object} message
+// @Failure 500 {object} message
+// @Router /roast/{roastID}/venue [post]
+func (app *Config) updateVenueHandler(c echo.Context) error {
+	correlationId := c.Get("correlationID")
+	var venue database.Venue
+	if err := c.Bind(&venue); err != nil {
+		errMsg := "error binding request"
+		app.Logger.Error(errMsg, "error", err, "correlationID", correlationId)
+		return c.JSON(http.StatusBadRequest, message{Message: errMsg})
+	}
+
+	now := time.Now()
+	if err := venues.Normalise(&venue, now); err != nil {
+		return c.JSON(http.StatusBadRequest, message{Message: err.Error()})
+	}
+
+	roast, err := app.RoastModels.GetRoastByPrefix("ROAST#" + c.Param("roastID"))
+	if err != nil {
+		errMsg := "error getting roast"
+		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+	if roast == nil {
+		return c.JSON(http.StatusNotFound, message{Message: "roast not found"})
+	}
+
+	// Price changes are recorded before the venue is saved, otherwise a failed write would leave nothing
+	// changed to record when the same details are saved again
+	changes := venues.PriceChanges(roast.Venue, &venue)
+	if err := app.savePriceHistory(roast.RoastID, changes, now); err != nil {
+		errMsg := "error saving price history"
+		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+
+	roast.Venue = &venue
+	if err := app.RoastModels.UpdateVenue(roast); err != nil {
+		errMsg := "error saving venue"
+		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+
+	app.Logger.Info("venue updated", "roastID", roast.RoastID, "priceChanges", len(changes), "correlationID", correlationId)
+	return c.JSON(http.StatusOK, roast)
+}
+
+// @Summary get how the menu prices of a roast have changed over time
+// @ID get-roast-prices
+// @Tags roasts
+// @Produce json
+// @Param roastID path string true "roast ID"
+// @Success 200 {object} []venues.ItemPrices
+// @Failure 500 {object} message
+// @Router /roast/{roastID}/prices [get]
+func (app *Config) getRoastPricesHandler(c echo.Context) error {
+	correlationId := c.Get("correlationID")
+	roastID, err := app.RoastModels.ResolveRoastID(c.Param("roastID"))
+	if err != nil {
+		errMsg := "error getting roast"
+		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+	records, err := app.RoastModels.GetPriceHistory(roastID)
+	if err != nil {
+		errMsg := "error getting price history"
+		app.Logger.Error(errMsg, "err", err, "correlationID", correlationId)
+		return c.JSON(http.StatusInternalServerError, message{Message: errMsg})
+	}
+	return c.JSON(http.StatusOK, venues.Timeline(records))
+}
+
+// savePriceHistory adds prices that have changed to a roast's price history
+func (app *Config) savePriceHistory(roastID string, prices []database.MenuPrice, recordedAt time.Time) error {
+	for _, price := range prices {
+		record := database.PriceRecord{RoastID: roastID, MenuPrice: price, RecordedAt: recordedAt.UnixMilli()}
+		if err := app.RoastModels.PutPriceRecord(record); err != nil {
+			return fmt.Errorf("error saving price of %s: %w", price.Item, err)
+		}
+	}
+	return nil
+}
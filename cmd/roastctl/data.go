@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/migrations"
+	"github.com/94DanielBrown/roasts-api/pkg/dynamo"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// purgeOrphanedReviews removes reviews left behind by deleted roasts
+func purgeOrphanedReviews(app *cli, args []string) error {
+	fs := flag.NewFlagSet("reviews purge-orphans", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "list orphaned reviews without removing them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	roasts, err := app.RoastModels.GetAllRoasts()
+	if err != nil {
+		return fmt.Errorf("error getting roasts: %w", err)
+	}
+	roastKeys := map[string]bool{}
+	for _, roast := range roasts {
+		roastKeys[roast.RoastKey] = true
+	}
+
+	reviews, err := app.ReviewModels.GetAllReviews()
+	if err != nil {
+		return fmt.Errorf("error getting reviews: %w", err)
+	}
+
+	orphans := []database.Review{}
+	for _, review := range reviews {
+		if roastKeys[review.RoastKey] {
+			continue
+		}
+		if !*dryRun {
+			if err := app.ReviewModels.RemoveReview(review.RoastKey, review.ReviewKey); err != nil {
+				return fmt.Errorf("error removing review %s: %w", review.ReviewKey, err)
+			}
+		}
+		orphans = append(orphans, review)
+	}
+
+	return app.out.print(orphans, []string{"ROAST", "REVIEW", "USER", "OVERALL"}, func() [][]string {
+		var rows [][]string
+		for _, review := range orphans {
+			rows = append(rows, []string{review.RoastKey, review.ReviewKey, review.UserID, strconv.Itoa(review.OverallRating)})
+		}
+		return rows
+	})
+}
+
+// exportData writes every item in the table as a line of JSON
+func exportData(app *cli, args []string) error {
+	fs := flag.NewFlagSet("data export", flag.ContinueOnError)
+	file := fs.String("file", "", "file to write to, defaults to stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	buf := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buf)
+	count := 0
+	err := app.ItemModels.ScanAll(func(item map[string]types.AttributeValue) error {
+		var decoded map[string]any
+		if err := attributevalue.UnmarshalMap(item, &decoded); err != nil {
+			return fmt.Errorf("error unmarshalling item: %w", err)
+		}
+		count++
+		return encoder.Encode(decoded)
+	})
+	if err != nil {
+		return err
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+
+	if *file != "" {
+		return app.out.message("exported %d items to %s", count, *file)
+	}
+	return nil
+}
+
+// importData writes every line of JSON produced by export back into the table
+func importData(app *cli, args []string) error {
+	fs := flag.NewFlagSet("data import", flag.ContinueOnError)
+	file := fs.String("file", "", "file to read from, defaults to stdin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	decoder := json.NewDecoder(r)
+	count := 0
+	for {
+		var decoded map[string]any
+		err := decoder.Decode(&decoded)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error decoding item %d: %w", count+1, err)
+		}
+		if _, ok := decoded[dynamo.PartitionKey].(string); !ok {
+			return fmt.Errorf("item %d has no %s", count+1, dynamo.PartitionKey)
+		}
+
+		item, err := attributevalue.MarshalMap(decoded)
+		if err != nil {
+			return fmt.Errorf("error marshalling item %d: %w", count+1, err)
+		}
+		if err := app.ItemModels.PutItem(item); err != nil {
+			return fmt.Errorf("error writing item %d: %w", count+1, err)
+		}
+		count++
+	}
+
+	return app.out.message("imported %d items", count)
+}
+
+func ensureTable(app *cli, args []string) error {
+	result, err := dynamo.EnsureTable(app.ctx, app.client, app.env.TableName)
+	if err != nil {
+		return err
+	}
+	return app.out.message("%s", result)
+}
+
+func migrate(app *cli, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "report what would change without writing")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	runner, err := migrations.NewRunner(app.client, app.env.TableName, app.logger)
+	if err != nil {
+		return err
+	}
+	runner.DryRun = *dryRun
+
+	if fs.Arg(0) == "status" {
+		statuses, err := runner.Status(app.ctx)
+		if err != nil {
+			return err
+		}
+		return app.out.print(statuses, []string{"VERSION", "DESCRIPTION", "APPLIED", "IN PROGRESS"}, func() [][]string {
+			var rows [][]string
+			for _, s := range statuses {
+				rows = append(rows, []string{strconv.Itoa(s.Version), s.Description, strconv.FormatBool(s.Applied), strconv.FormatBool(s.InProgress)})
+			}
+			return rows
+		})
+	}
+
+	results, err := runner.Run(app.ctx)
+	if err != nil {
+		return err
+	}
+	return app.out.print(results, []string{"VERSION", "DESCRIPTION", "SCANNED", "CHANGED", "RESUMED"}, func() [][]string {
+		var rows [][]string
+		for _, r := range results {
+			rows = append(rows, []string{strconv.Itoa(r.Version), r.Description, strconv.Itoa(r.Scanned), strconv.Itoa(r.Changed), strconv.FormatBool(r.Resumed)})
+		}
+		return rows
+	})
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/images"
+	"github.com/94DanielBrown/roasts-api/pkg/storage"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// collectImages deletes objects in the image bucket that nothing in the table references and that are
+// older than the grace period, which leaves time for uploads to be confirmed before they're collected
+func collectImages(app *cli, args []string) error {
+	fs := flag.NewFlagSet("images gc", flag.ContinueOnError)
+	grace := fs.Duration("grace", 24*time.Hour, "how old an unreferenced object must be before it's deleted")
+	dryRun := fs.Bool("dry-run", false, "report orphaned objects without deleting them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	store, err := storage.New(storage.Config{
+		Bucket:  app.env.ImageBucket,
+		Dir:     app.env.StorageDir,
+		BaseURL: app.env.PublicURL,
+	})
+	if err != nil {
+		return fmt.Errorf("error opening image storage: %w", err)
+	}
+
+	// References are collected before listing so anything uploaded and referenced during the run is
+	// still within the grace period
+	references := images.NewReferences(store.PathPrefix())
+	err = app.ItemModels.ScanAll(func(item map[string]types.AttributeValue) error {
+		references.Add(item)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	report := images.GCReport{DryRun: *dryRun}
+	now := time.Now()
+	err = store.List(app.ctx, "", func(object storage.Object) error {
+		if !report.Check(object, references, now, *grace) || *dryRun {
+			return nil
+		}
+		if err := store.Delete(app.ctx, object.Key); err != nil {
+			return err
+		}
+		report.Deleted++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	app.logger.Info("image gc finished", "scanned", report.Scanned, "referenced", report.Referenced, "recent", report.Recent, "orphaned", len(report.Orphaned), "deleted", report.Deleted)
+
+	return app.out.print(report, []string{"KEY", "SIZE", "LAST MODIFIED"}, func() [][]string {
+		var rows [][]string
+		for _, object := range report.Orphaned {
+			rows = append(rows, []string{object.Key, strconv.FormatInt(object.Size, 10), object.LastModified.Format(time.RFC3339)})
+		}
+		return rows
+	})
+}
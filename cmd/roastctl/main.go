@@ -0,0 +1,173 @@
+// roastctl is an admin CLI for operating the Roasts API's table.
+// It uses the same env variables as the API, set DYNAMO_ENDPOINT to run it against DynamoDB Local
+// and S3_ENDPOINT or IMAGE_STORAGE_DIR to use a local S3 stand-in or directory for images.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/94DanielBrown/roasts-api/config"
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/places"
+	"github.com/94DanielBrown/roasts-api/pkg/dynamo"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+const usage = `Usage: roastctl [-o table|json] <command> [arguments]
+
+Commands:
+  roasts list
+  roasts get <roastID>
+  roasts create -name <name> [-price n] [-location l] [-image url] [-address a] [-town t] [-postcode p] [-coords lat,lng]
+  roasts update <roastID> [-name n] [-price n] [-location l] [-image url] [-address a] [-town t] [-postcode p] [-coords lat,lng]
+  roasts delete <roastID>
+  roasts locate [-dry-run]
+  roasts merge <sourceID> <targetID> [-dry-run]
+  aggregates recompute [-roast roastID] [-dry-run]
+  reviews purge-orphans [-dry-run]
+  images gc [-grace 24h] [-dry-run]
+  data export [-file path]
+  data import [-file path]
+  apikeys list
+  apikeys create -name <name>
+  apikeys revoke <keyID>
+  users get <userID>
+  users roles <userID> [role ...]
+  table ensure
+  migrate [-dry-run] [status]
+`
+
+// cli holds the models shared by every command
+type cli struct {
+	ctx          context.Context
+	client       *dynamodb.Client
+	env          config.Env
+	logger       *slog.Logger
+	out          *output
+	RoastModels  database.RoastModels
+	ReviewModels database.ReviewModels
+	UserModels   database.UserModels
+	ItemModels   database.ItemModels
+	APIKeyModels database.APIKeyModels
+	TagModels    database.TagModels
+	Places       *places.Gazetteer
+}
+
+type command func(app *cli, args []string) error
+
+var commands = map[string]map[string]command{
+	"roasts": {
+		"list":   listRoasts,
+		"get":    getRoast,
+		"create": createRoast,
+		"update": updateRoast,
+		"delete": deleteRoast,
+		"locate": locateRoasts,
+		"merge":  mergeRoasts,
+	},
+	"aggregates": {
+		"recompute": recomputeAggregates,
+	},
+	"reviews": {
+		"purge-orphans": purgeOrphanedReviews,
+	},
+	"images": {
+		"gc": collectImages,
+	},
+	"data": {
+		"export": exportData,
+		"import": importData,
+	},
+	"apikeys": {
+		"list":   listAPIKeys,
+		"create": createAPIKey,
+		"revoke": revokeAPIKey,
+	},
+	"users": {
+		"get":   getUser,
+		"roles": setUserRoles,
+	},
+	"table": {
+		"ensure": ensureTable,
+	},
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("roastctl", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	format := fs.String("o", "table", "output format, table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("unknown output format %q", *format)
+	}
+
+	args = fs.Args()
+	if len(args) == 0 {
+		fs.Usage()
+		return fmt.Errorf("no command given")
+	}
+
+	env, err := config.LoadEnvVariables()
+	if err != nil {
+		return fmt.Errorf("unable to load env variables: %w", err)
+	}
+
+	client, err := dynamo.Connect()
+	if err != nil {
+		return fmt.Errorf("error connecting to dynamo: %w", err)
+	}
+
+	gazetteer, err := places.Load(env.PlacesFile)
+	if err != nil {
+		return fmt.Errorf("error loading places: %w", err)
+	}
+
+	app := &cli{
+		ctx:          context.Background(),
+		client:       client,
+		env:          env,
+		logger:       slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo})),
+		out:          &output{w: os.Stdout, json: *format == "json"},
+		RoastModels:  database.NewRoastModels(client),
+		ReviewModels: database.NewReviewModels(client),
+		UserModels:   database.NewUserModels(client),
+		ItemModels:   database.NewItemModels(client),
+		APIKeyModels: database.NewAPIKeyModels(client),
+		TagModels:    database.NewTagModels(client),
+		Places:       gazetteer,
+	}
+
+	// migrate has no subcommands of its own so is passed straight through
+	if args[0] == "migrate" {
+		return migrate(app, args[1:])
+	}
+
+	group, ok := commands[args[0]]
+	if !ok {
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	if len(args) < 2 {
+		fs.Usage()
+		return fmt.Errorf("%s needs a subcommand", args[0])
+	}
+	cmd, ok := group[args[1]]
+	if !ok {
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", args[0]+" "+args[1])
+	}
+	return cmd(app, args[2:])
+}
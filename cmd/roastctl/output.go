@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// output writes command results as either an aligned table or JSON
+type output struct {
+	w    io.Writer
+	json bool
+}
+
+// print writes v as JSON, or as a table using headers and the rows built by toRows
+func (o *output) print(v any, headers []string, toRows func() [][]string) error {
+	if o.json {
+		encoder := json.NewEncoder(o.w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(o.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	for _, row := range toRows() {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// message writes a single line result, wrapped in an object when outputting JSON
+func (o *output) message(format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	if o.json {
+		return json.NewEncoder(o.w).Encode(map[string]string{"message": msg})
+	}
+	_, err := fmt.Fprintln(o.w, msg)
+	return err
+}
+
+func formatRating(rating float64) string {
+	if rating == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", rating)
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/geo"
+	"github.com/94DanielBrown/roasts-api/internal/images"
+	"github.com/94DanielBrown/roasts-api/internal/ratings"
+	"github.com/94DanielBrown/roasts-api/internal/roasts"
+	"github.com/94DanielBrown/roasts-api/internal/utils"
+)
+
+var roastHeaders = []string{"ID", "NAME", "LOCATION", "PRICE", "REVIEWS", "OVERALL"}
+
+func roastRow(roast database.Roast) []string {
+	return []string{
+		roast.RoastID,
+		roast.Name,
+		roast.Location,
+		strconv.Itoa(roast.PriceRange),
+		strconv.Itoa(roast.ReviewCount),
+		formatRating(roast.OverallRating),
+	}
+}
+
+// imageVariants sets an image given by URL as the full variant, as only uploads through the API are resized
+func imageVariants(url string) map[string]string {
+	if url == "" {
+		return nil
+	}
+	return map[string]string{images.VariantFull: url}
+}
+
+func listRoasts(app *cli, args []string) error {
+	roasts, err := app.RoastModels.GetAllRoasts()
+	if err != nil {
+		return fmt.Errorf("error getting roasts: %w", err)
+	}
+	if roasts == nil {
+		roasts = []database.Roast{}
+	}
+
+	return app.out.print(roasts, roastHeaders, func() [][]string {
+		var rows [][]string
+		for _, roast := range roasts {
+			rows = append(rows, roastRow(roast))
+		}
+		return rows
+	})
+}
+
+func getRoast(app *cli, args []string) error {
+	roast, err := lookupRoast(app, args)
+	if err != nil {
+		return err
+	}
+	return app.out.print(roast, roastHeaders, func() [][]string {
+		return [][]string{roastRow(*roast)}
+	})
+}
+
+// roastFlags registers the flags shared by create and update
+func roastFlags(fs *flag.FlagSet) (name, location, image *string, price *int) {
+	name = fs.String("name", "", "name of the roast")
+	location = fs.String("location", "", "location of the roast")
+	image = fs.String("image", "", "image URL")
+	price = fs.Int("price", 0, "price range")
+	return name, location, image, price
+}
+
+// addressFlags registers the flags that set a roast's structured address,
+// they're applied with setAddress
+func addressFlags(fs *flag.FlagSet) {
+	fs.String("address", "", "street address of the roast")
+	fs.String("town", "", "town the roast is in")
+	fs.String("postcode", "", "postcode of the roast")
+	fs.String("coords", "", "coordinates of the roast as lat,lng")
+}
+
+// setAddressFlag sets the part of a roast's address given by an address flag
+func setAddressFlag(roast *database.Roast, f *flag.Flag) error {
+	if roast.Address == nil {
+		roast.Address = &database.Address{}
+	}
+	value := f.Value.String()
+	switch f.Name {
+	case "address":
+		roast.Address.Line = value
+	case "town":
+		roast.Address.Town = value
+	case "postcode":
+		roast.Address.Postcode = value
+	case "coords":
+		roast.Address.Lat, roast.Address.Lng = 0, 0
+		if value != "" {
+			point, err := geo.ParsePoint(value)
+			if err != nil {
+				return err
+			}
+			roast.Address.Lat, roast.Address.Lng = point.Lat, point.Lng
+		}
+	}
+	return nil
+}
+
+func createRoast(app *cli, args []string) error {
+	fs := flag.NewFlagSet("roasts create", flag.ContinueOnError)
+	name, location, image, price := roastFlags(fs)
+	addressFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" {
+		return fmt.Errorf("-name is required")
+	}
+
+	roastID := utils.ToPascalCase(*name)
+	existing, err := app.RoastModels.GetRoastByPrefix("ROAST#" + roastID)
+	if err != nil {
+		return fmt.Errorf("error checking for existing roast: %w", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("roast %s already exists", roastID)
+	}
+
+	roast := database.Roast{
+		RoastID:    roastID,
+		RoastKey:   "ROAST#" + roastID,
+		SK:         "PROFILE#" + time.Now().Format("02042006"),
+		Name:       *name,
+		Location:   *location,
+		Images:     imageVariants(*image),
+		PriceRange: *price,
+	}
+	if err := setAddress(app, fs, &roast); err != nil {
+		return err
+	}
+	if err := app.RoastModels.CreateRoast(roast); err != nil {
+		return fmt.Errorf("error creating roast: %w", err)
+	}
+	return app.out.print(roast, roastHeaders, func() [][]string {
+		return [][]string{roastRow(roast)}
+	})
+}
+
+func updateRoast(app *cli, args []string) error {
+	roast, err := lookupRoast(app, args)
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("roasts update", flag.ContinueOnError)
+	name, location, image, price := roastFlags(fs)
+	addressFlags(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	// Only the flags that were passed are changed
+	if err := setAddress(app, fs, roast); err != nil {
+		return err
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			roast.Name = *name
+		case "location":
+			roast.Location = *location
+		case "image":
+			roast.Images = imageVariants(*image)
+		case "price":
+			roast.PriceRange = *price
+		}
+	})
+
+	if err := app.RoastModels.UpdateRoastProfile(roast); err != nil {
+		return fmt.Errorf("error updating roast: %w", err)
+	}
+	return app.out.print(roast, roastHeaders, func() [][]string {
+		return [][]string{roastRow(*roast)}
+	})
+}
+
+// setAddress applies the address flags that were passed, locating the address from its postcode or town
+// if coordinates weren't given, and keys the roast in the geo index
+func setAddress(app *cli, fs *flag.FlagSet, roast *database.Roast) error {
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "address", "town", "postcode", "coords":
+			if err == nil {
+				err = setAddressFlag(roast, f)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	app.Places.Locate(roast.Address)
+	return roasts.SetLocation(roast)
+}
+
+// locateRoasts adds coordinates to roasts whose address doesn't have them, from its postcode or town.
+// Run it after migrating free-text locations into addresses so those roasts can be found by proximity.
+func locateRoasts(app *cli, args []string) error {
+	fs := flag.NewFlagSet("roasts locate", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "report the coordinates found without saving them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	allRoasts, err := app.RoastModels.GetAllRoasts()
+	if err != nil {
+		return fmt.Errorf("error getting roasts: %w", err)
+	}
+
+	located := []database.Roast{}
+	var unresolved int
+	for i := range allRoasts {
+		roast := &allRoasts[i]
+		if roast.Address == nil || roast.Address.Located() {
+			continue
+		}
+		if !app.Places.Locate(roast.Address) {
+			unresolved++
+			app.logger.Warn("couldn't locate roast", "roastID", roast.RoastID, "town", roast.Address.Town, "postcode", roast.Address.Postcode)
+			continue
+		}
+		if err := roasts.SetLocation(roast); err != nil {
+			return fmt.Errorf("error locating %s: %w", roast.RoastID, err)
+		}
+		if !*dryRun {
+			if err := app.RoastModels.UpdateRoastProfile(roast); err != nil {
+				return fmt.Errorf("error updating %s: %w", roast.RoastID, err)
+			}
+		}
+		located = append(located, *roast)
+	}
+	app.logger.Info("roasts located", "located", len(located), "unresolved", unresolved, "dryRun", *dryRun)
+
+	return app.out.print(located, []string{"ID", "TOWN", "POSTCODE", "LAT", "LNG"}, func() [][]string {
+		var rows [][]string
+		for _, roast := range located {
+			rows = append(rows, []string{
+				roast.RoastID,
+				roast.Address.Town,
+				roast.Address.Postcode,
+				strconv.FormatFloat(roast.Address.Lat, 'f', 4, 64),
+				strconv.FormatFloat(roast.Address.Lng, 'f', 4, 64),
+			})
+		}
+		return rows
+	})
+}
+
+func deleteRoast(app *cli, args []string) error {
+	roast, err := lookupRoast(app, args)
+	if err != nil {
+		return err
+	}
+	if err := app.RoastModels.DeleteRoast(roast.RoastID); err != nil {
+		return fmt.Errorf("error deleting roast: %w", err)
+	}
+	return app.out.message("%s deleted, run reviews purge-orphans to remove its reviews", roast.RoastID)
+}
+
+// recomputeAggregates rebuilds roast aggregates from their reviews, reporting any that have drifted
+func recomputeAggregates(app *cli, args []string) error {
+	fs := flag.NewFlagSet("aggregates recompute", flag.ContinueOnError)
+	roastID := fs.String("roast", "", "only recompute this roast")
+	dryRun := fs.Bool("dry-run", false, "report drift without saving the rebuilt aggregates")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	settings := ratingSettings(app)
+	var results []ratings.RecomputeResult
+	if *roastID != "" {
+		settings, err := ratings.ResolveSettings(settings, app.RoastModels)
+		if err != nil {
+			return fmt.Errorf("error resolving rating settings: %w", err)
+		}
+		result, err := ratings.Recompute(app.RoastModels, app.ReviewModels, *roastID, settings, !*dryRun)
+		if err != nil {
+			return fmt.Errorf("error recomputing %s: %w", *roastID, err)
+		}
+		results = append(results, result)
+	} else {
+		var err error
+		results, err = ratings.RecomputeAll(app.RoastModels, app.ReviewModels, settings, !*dryRun)
+		if err != nil {
+			return fmt.Errorf("error recomputing roasts: %w", err)
+		}
+	}
+
+	return app.out.print(results, []string{"ROAST", "REVIEWS", "FIELD", "STORED", "ACTUAL", "FIXED"}, func() [][]string {
+		var rows [][]string
+		for _, result := range results {
+			if len(result.Discrepancies) == 0 {
+				rows = append(rows, []string{result.RoastID, strconv.Itoa(result.ReviewCount), "-", "-", "-", "false"})
+				continue
+			}
+			for _, d := range result.Discrepancies {
+				stored := strconv.FormatFloat(d.Stored, 'f', 4, 64)
+				if d.Note != "" {
+					stored = d.Note
+				}
+				rows = append(rows, []string{result.RoastID, strconv.Itoa(result.ReviewCount), d.Field, stored, strconv.FormatFloat(d.Actual, 'f', 4, 64), strconv.FormatBool(result.Fixed)})
+			}
+		}
+		return rows
+	})
+}
+
+// mergeRoasts merges a duplicate roast into another, moving its reviews and saves and leaving a redirect
+func mergeRoasts(app *cli, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("a source and target roast ID are required")
+	}
+	fs := flag.NewFlagSet("roasts merge", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "show what would be merged without merging")
+	if err := fs.Parse(args[2:]); err != nil {
+		return err
+	}
+
+	settings, err := ratings.ResolveSettings(ratingSettings(app), app.RoastModels)
+	if err != nil {
+		return fmt.Errorf("error resolving rating settings: %w", err)
+	}
+	result, err := roasts.Merge(app.RoastModels, app.ReviewModels, app.UserModels, app.TagModels, args[0], args[1], settings, !*dryRun)
+	if err != nil {
+		return fmt.Errorf("error merging %s into %s: %w", args[0], args[1], err)
+	}
+
+	return app.out.print(result, []string{"SOURCE", "TARGET", "REVIEWS", "USERS", "TAGS", "PRICES", "SUGGESTIONS", "TARGET REVIEWS", "TARGET OVERALL", "MERGED"}, func() [][]string {
+		return [][]string{{
+			result.SourceID,
+			result.TargetID,
+			strconv.Itoa(len(result.Reviews)),
+			strconv.Itoa(len(result.Users)),
+			strings.Join(result.Tags, ","),
+			strconv.Itoa(len(result.Prices)),
+			strconv.Itoa(len(result.TagSuggestions)),
+			strconv.Itoa(result.Target.ReviewCount),
+			formatRating(result.Target.OverallRating),
+			strconv.FormatBool(result.Merged),
+		}}
+	})
+}
+
+// ratingSettings are the rating settings in the env, the prior's mean may still need resolving
+func ratingSettings(app *cli) ratings.Settings {
+	return ratings.Settings{
+		Prior:     ratings.Prior{Mean: app.env.RankingPriorMean, Weight: app.env.RankingPriorWeight},
+		HalfLife:  ratings.HalfLifeDays(app.env.RatingHalfLifeDays),
+		Weighting: app.env.RatingWeighting,
+	}
+}
+
+// lookupRoast gets the roast whose ID is the first argument
+func lookupRoast(app *cli, args []string) (*database.Roast, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("a roast ID is required")
+	}
+	roast, err := app.RoastModels.GetRoastByPrefix("ROAST#" + args[0])
+	if err != nil {
+		return nil, fmt.Errorf("error getting roast: %w", err)
+	}
+	if roast == nil {
+		return nil, fmt.Errorf("roast %s not found", args[0])
+	}
+	return roast, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/pkg/apikey"
+	"github.com/google/uuid"
+)
+
+var apiKeyHeaders = []string{"ID", "NAME", "CREATED"}
+
+func apiKeyRow(key database.APIKey) []string {
+	return []string{key.ID, key.Name, time.Unix(key.CreatedAt, 0).Format(time.RFC3339)}
+}
+
+func listAPIKeys(app *cli, args []string) error {
+	keys, err := app.APIKeyModels.GetAllAPIKeys()
+	if err != nil {
+		return fmt.Errorf("error getting api keys: %w", err)
+	}
+	if keys == nil {
+		keys = []database.APIKey{}
+	}
+	return app.out.print(keys, apiKeyHeaders, func() [][]string {
+		var rows [][]string
+		for _, key := range keys {
+			rows = append(rows, apiKeyRow(key))
+		}
+		return rows
+	})
+}
+
+// createAPIKey generates a key and stores its hash, the key is only ever shown here
+func createAPIKey(app *cli, args []string) error {
+	fs := flag.NewFlagSet("apikeys create", flag.ContinueOnError)
+	name := fs.String("name", "", "what the key is used for")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" {
+		return fmt.Errorf("-name is required")
+	}
+
+	key, err := apikey.Generate()
+	if err != nil {
+		return fmt.Errorf("error generating api key: %w", err)
+	}
+	stored := database.APIKey{
+		KeyHash:   apikey.Hash(key),
+		ID:        uuid.NewString(),
+		Name:      *name,
+		CreatedAt: time.Now().Unix(),
+	}
+	if err := app.APIKeyModels.CreateAPIKey(stored); err != nil {
+		return fmt.Errorf("error storing api key: %w", err)
+	}
+
+	created := struct {
+		database.APIKey
+		Key string `json:"key"`
+	}{stored, key}
+	return app.out.print(created, append(apiKeyHeaders, "KEY"), func() [][]string {
+		return [][]string{append(apiKeyRow(stored), key)}
+	})
+}
+
+func revokeAPIKey(app *cli, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("a key ID is required")
+	}
+	if err := app.APIKeyModels.RevokeAPIKey(args[0]); err != nil {
+		return err
+	}
+	return app.out.message("api key %s revoked", args[0])
+}
+
+var userHeaders = []string{"ID", "DISPLAY NAME", "ROLES", "SAVED ROASTS"}
+
+func userRow(user database.User) []string {
+	return []string{
+		strings.TrimPrefix(user.UserKey, "USER#"),
+		user.DisplayName,
+		strings.Join(user.Roles, ","),
+		fmt.Sprint(len(user.SavedRoasts)),
+	}
+}
+
+func getUser(app *cli, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("a user ID is required")
+	}
+	user, err := app.UserModels.GetUserByPrefix("USER#" + args[0])
+	if err != nil {
+		return fmt.Errorf("error getting user: %w", err)
+	}
+	if user == nil {
+		return fmt.Errorf("user %s not found", args[0])
+	}
+	return app.out.print(user, userHeaders, func() [][]string {
+		return [][]string{userRow(*user)}
+	})
+}
+
+// setUserRoles replaces a user's roles with the ones given, passing none removes them all
+func setUserRoles(app *cli, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("a user ID is required")
+	}
+	if err := app.UserModels.SetRoles(args[0], args[1:]); err != nil {
+		return err
+	}
+	return getUser(app, args[:1])
+}
@@ -12,6 +12,27 @@ type Env struct {
 	TableName   string
 	ImageBucket string
 	WebPort     int
+	// RankingPriorMean is the rating new roasts are pulled towards, 0 uses the global mean, refreshed every 15 minutes
+	RankingPriorMean float64
+	// RankingPriorWeight is how many reviews the ranking prior counts as
+	RankingPriorWeight float64
+	// RatingHalfLifeDays is how many days it takes a review's weight in the current rating to halve
+	RatingHalfLifeDays float64
+	// RatingWeighting keeps credibility weighted ratings alongside the raw ones
+	RatingWeighting bool
+	// UploadMaxBytes is the largest image that can be uploaded
+	UploadMaxBytes int64
+	// UploadDailyLimit is how many image uploads each user can request a day
+	UploadDailyLimit int
+	// StorageDir keeps images on local disk instead of in ImageBucket, served by the API at PublicURL
+	StorageDir    string
+	StorageSecret string
+	PublicURL     string
+	// PlacesFile replaces the bundled postcode and town dataset used to locate roasts
+	PlacesFile string
+	// HolidaysFile replaces the bundled bank holidays, and can add seasonal closures, when working out
+	// when roasts are served
+	HolidaysFile string
 }
 
 func LoadEnvVariables() (Env, error) {
@@ -25,10 +46,56 @@ func LoadEnvVariables() (Env, error) {
 		webPort = 8000
 	}
 
+	rankingPriorMean, err := strconv.ParseFloat(os.Getenv("RANKING_PRIOR_MEAN"), 64)
+	if err != nil {
+		rankingPriorMean = 0
+	}
+
+	rankingPriorWeight, err := strconv.ParseFloat(os.Getenv("RANKING_PRIOR_WEIGHT"), 64)
+	if err != nil {
+		rankingPriorWeight = 5
+	}
+
+	ratingHalfLifeDays, err := strconv.ParseFloat(os.Getenv("RATING_HALF_LIFE_DAYS"), 64)
+	if err != nil || ratingHalfLifeDays <= 0 {
+		ratingHalfLifeDays = 180
+	}
+
+	ratingWeighting, err := strconv.ParseBool(os.Getenv("RATING_WEIGHTING"))
+	if err != nil {
+		ratingWeighting = false
+	}
+
+	uploadMaxBytes, err := strconv.ParseInt(os.Getenv("UPLOAD_MAX_BYTES"), 10, 64)
+	if err != nil || uploadMaxBytes <= 0 {
+		uploadMaxBytes = 10 << 20
+	}
+
+	uploadDailyLimit, err := strconv.Atoi(os.Getenv("UPLOAD_DAILY_LIMIT"))
+	if err != nil || uploadDailyLimit <= 0 {
+		uploadDailyLimit = 50
+	}
+
+	publicURL := os.Getenv("PUBLIC_URL")
+	if publicURL == "" {
+		publicURL = fmt.Sprintf("http://localhost:%d", webPort)
+	}
+
 	return Env{
-		TableName:   os.Getenv("TABLE_NAME"),
-		ImageBucket: os.Getenv("IMAGE_BUCKET"),
-		WebPort:     webPort,
+		TableName:          os.Getenv("TABLE_NAME"),
+		ImageBucket:        os.Getenv("IMAGE_BUCKET"),
+		WebPort:            webPort,
+		RankingPriorMean:   rankingPriorMean,
+		RankingPriorWeight: rankingPriorWeight,
+		RatingHalfLifeDays: ratingHalfLifeDays,
+		RatingWeighting:    ratingWeighting,
+		UploadMaxBytes:     uploadMaxBytes,
+		UploadDailyLimit:   uploadDailyLimit,
+		StorageDir:         os.Getenv("IMAGE_STORAGE_DIR"),
+		StorageSecret:      os.Getenv("IMAGE_STORAGE_SECRET"),
+		PublicURL:          publicURL,
+		PlacesFile:         os.Getenv("PLACES_FILE"),
+		HolidaysFile:       os.Getenv("HOLIDAYS_FILE"),
 	}, nil
 }
 
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const EntityAPIKey = "APIKey"
+
+type APIKeyModels struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+// APIKey is stored by the hash of the key, the key itself is only shown once when created
+type APIKey struct {
+	KeyHash    string `dynamodbav:"PK" json:"-"`
+	SK         string `dynamodbav:"SK" json:"-"`
+	EntityType string `dynamodbav:"EntityType" json:"-"`
+	ID         string `dynamodbav:"KeyID" json:"id"`
+	Name       string `dynamodbav:"Name" json:"name"`
+	CreatedAt  int64  `dynamodbav:"CreatedAt" json:"createdAt"`
+}
+
+func NewAPIKeyModels(dynamo *dynamodb.Client) APIKeyModels {
+	tn := os.Getenv("TABLE_NAME")
+	return APIKeyModels{client: dynamo, tableName: tn}
+}
+
+// CreateAPIKey stores a new API key, KeyHash should be set to the hash of the key
+func (am *APIKeyModels) CreateAPIKey(key APIKey) error {
+	key.KeyHash = "APIKEY#" + key.KeyHash
+	key.SK = "APIKEY"
+	key.EntityType = EntityAPIKey
+	av, err := attributevalue.MarshalMap(key)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		Item:                av,
+		TableName:           aws.String(am.tableName),
+		ConditionExpression: aws.String("attribute_not_exists(PK)"),
+	}
+	_, err = am.client.PutItem(context.Background(), input)
+	return err
+}
+
+// ValidAPIKey reports whether an API key with the given hash exists
+func (am *APIKeyModels) ValidAPIKey(keyHash string) (bool, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(am.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "APIKEY#" + keyHash},
+			"SK": &types.AttributeValueMemberS{Value: "APIKEY"},
+		},
+	}
+
+	result, err := am.client.GetItem(context.Background(), input)
+	if err != nil {
+		return false, err
+	}
+	return result.Item != nil, nil
+}
+
+// GetAllAPIKeys scans for every API key
+func (am *APIKeyModels) GetAllAPIKeys() ([]APIKey, error) {
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(am.tableName),
+		FilterExpression: aws.String("begins_with(PK, :pkval)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pkval": &types.AttributeValueMemberS{Value: "APIKEY#"},
+		},
+	}
+
+	var keys []APIKey
+	paginator := dynamodb.NewScanPaginator(am.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		var items []APIKey
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, err
+		}
+		keys = append(keys, items...)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey deletes the API key with the given ID
+func (am *APIKeyModels) RevokeAPIKey(id string) error {
+	keys, err := am.GetAllAPIKeys()
+	if err != nil {
+		return fmt.Errorf("error retrieving api keys: %w", err)
+	}
+
+	for _, key := range keys {
+		if key.ID != id {
+			continue
+		}
+		input := &dynamodb.DeleteItemInput{
+			TableName: aws.String(am.tableName),
+			Key: map[string]types.AttributeValue{
+				"PK": &types.AttributeValueMemberS{Value: key.KeyHash},
+				"SK": &types.AttributeValueMemberS{Value: key.SK},
+			},
+		}
+		_, err := am.client.DeleteItem(context.Background(), input)
+		return err
+	}
+	return fmt.Errorf("api key not found with id: %s", id)
+}
@@ -2,16 +2,24 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/94DanielBrown/roasts-api/internal/geo"
+	"github.com/94DanielBrown/roasts-api/pkg/dynamo"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
+// ErrReviewNotFound is returned when looking up a review that doesn't exist
+var ErrReviewNotFound = errors.New("no review found")
+
 type RoastModels struct {
 	client    *dynamodb.Client
 	tableName string
@@ -27,18 +35,49 @@ type UserModels struct {
 	tableName string
 }
 
+// EntityType values stored on each item so they can be identified without parsing keys
+const (
+	EntityRoast  = "Roast"
+	EntityReview = "Review"
+	EntityUser   = "User"
+)
+
 type Roast struct {
 	RoastKey string `dynamodbav:"PK" json:"-"`
 	// Using date created as SK
-	SK          string `dynamodbav:"SK" json:"-"`
-	RoastID     string `dynamodbav:"RoastID" json:"id"`
-	Name        string `dynamodbav:"Name" json:"name"`
-	ImageURL    string `dynamodbav:"ImageURL" json:"imageURL"`
-	PriceRange  int    `dynamodbav:"PriceRange" json:"priceRange"`
-	Location    string `dynamodbav:"Location" json:"location"`
-	ReviewCount int    `dynamodbav:"ReviewCount" json:"reviewCount"`
+	SK         string `dynamodbav:"SK" json:"-"`
+	EntityType string `dynamodbav:"EntityType" json:"-"`
+	RoastID    string `dynamodbav:"RoastID" json:"id"`
+	Name       string `dynamodbav:"Name" json:"name"`
+	// ImageURL is the single image roasts had before Images, kept until every roast has been migrated
+	ImageURL string `dynamodbav:"ImageURL,omitempty" json:"-"`
+	// Images maps each image variant, e.g. thumb, to its URL
+	Images     map[string]string `dynamodbav:"Images,omitempty" json:"images,omitempty"`
+	PriceRange int               `dynamodbav:"PriceRange" json:"priceRange"`
+	// Location is a free-text description of where the roast is, Address is its structured location
+	Location string   `dynamodbav:"Location" json:"location"`
+	Address  *Address `dynamodbav:"Address,omitempty" json:"address,omitempty"`
+	// Venue is when the roast is served, booking, menu prices and contact details
+	Venue *Venue `dynamodbav:"Venue,omitempty" json:"venue,omitempty"`
+	// NextServing is when the roast is being served now or will be next, worked out when it's returned
+	NextServing *ServingTime `dynamodbav:"-" json:"nextServing,omitempty"`
+	// GeoCell and Geohash key the geo index, they're only set when the address has coordinates
+	GeoCell string `dynamodbav:"GeoCell,omitempty" json:"-"`
+	Geohash string `dynamodbav:"Geohash,omitempty" json:"-"`
+	// Distance is how far the roast is from the point a proximity search was made from
+	Distance float64 `dynamodbav:"-" json:"distanceKm,omitempty"`
+	// Tags describe what the roast offers, e.g. vegetarian, and can be filtered on. They're set by admins
+	// or from suggestions a moderator has approved, TagCounts are how many reviewers gave each tag.
+	Tags        []string       `dynamodbav:"Tags,omitempty" json:"tags,omitempty"`
+	TagCounts   map[string]int `dynamodbav:"TagCounts,omitempty" json:"tagCounts,omitempty"`
+	ReviewCount int            `dynamodbav:"ReviewCount" json:"reviewCount"`
+	// PhotoCount is how many photos are attached to the roast's reviews, kept with the other aggregates
+	PhotoCount int `dynamodbav:"PhotoCount" json:"photoCount"`
 	// Average rating of 0 is omitted, frontend should take no result as an indication to display that there's no reviews yet
-	OverallRating           float64 `dynamodbav:"OverallRating" json:"overallRating,omitempty"`
+	OverallRating float64 `dynamodbav:"OverallRating" json:"overallRating,omitempty"`
+	// RankingScore is the overall rating pulled towards a prior so roasts with few reviews don't outrank well reviewed ones.
+	// It isn't stored as the prior's mean changes, so it's set when roasts are read.
+	RankingScore            float64 `dynamodbav:"-" json:"rankingScore,omitempty"`
 	MeatRating              float64 `dynamodbav:"MeatRating" json:"meatRating,omitempty"`
 	PotatoesRating          float64 `dynamodbav:"PotatoesRating" json:"potatoesRating,omitempty"`
 	VegRating               float64 `dynamodbav:"VegRating" json:"vegRating,omitempty"`
@@ -52,12 +91,56 @@ type Roast struct {
 	MeatPotatoesVegRating   float64 `dynamodbav:"MeatPotatoesVegRating" json:"meatPotatoesVegRating,omitempty"`
 	MeatPotatoesGravyRating float64 `dynamodbav:"MeatPotatoesGravyRating" json:"meatPotatoesGravyRating,omitempty"`
 	MeatVegGravyRating      float64 `dynamodbav:"MeatVegGravyRating" json:"meatVegGravyRating,omitempty"`
+	// RatingDistribution counts how many times each score from 1 to 10 was given, keyed by criterion
+	RatingDistribution map[string][]int `dynamodbav:"RatingDistribution" json:"ratingDistribution,omitempty"`
+	// RecentRating is the overall rating over the last 90 days, calculated from trends when the listing is sorted by it
+	RecentRating float64 `dynamodbav:"-" json:"recentRating,omitempty"`
+	// ValueScore relates the roast's rating to its price range, calculated across every roast for listings
+	ValueScore float64 `dynamodbav:"-" json:"valueScore,omitempty"`
+	// Decay holds running sums of ratings weighted by how recently they were given
+	Decay DecayedRatings `dynamodbav:"Decay" json:"-"`
+	// CurrentRating and CurrentRatings are the recency-weighted ratings, calculated from Decay when requested
+	CurrentRating  float64            `dynamodbav:"-" json:"currentRating,omitempty"`
+	CurrentRatings map[string]float64 `dynamodbav:"-" json:"currentRatings,omitempty"`
+	// Weighted holds ratings where each review counts by its reviewer's credibility, kept alongside
+	// the raw ratings while the weighting model is evaluated. It's nil if weighting has never been enabled.
+	Weighted *WeightedRatings `dynamodbav:"Weighted,omitempty" json:"weighted,omitempty"`
+}
+
+// Address is where a roast is served. Lat and Lng are both 0 when the address hasn't been located.
+type Address struct {
+	Line     string  `dynamodbav:"Line,omitempty" json:"line,omitempty"`
+	Town     string  `dynamodbav:"Town,omitempty" json:"town,omitempty"`
+	Postcode string  `dynamodbav:"Postcode,omitempty" json:"postcode,omitempty"`
+	Lat      float64 `dynamodbav:"Lat,omitempty" json:"lat,omitempty"`
+	Lng      float64 `dynamodbav:"Lng,omitempty" json:"lng,omitempty"`
+}
+
+// Located reports whether the address has coordinates
+func (a *Address) Located() bool {
+	return a != nil && (a.Lat != 0 || a.Lng != 0)
+}
+
+// WeightedRatings are running sums of each criterion's scores multiplied by the review's weight
+type WeightedRatings struct {
+	Sums   map[string]float64 `dynamodbav:"Sums" json:"-"`
+	Weight float64            `dynamodbav:"Weight" json:"totalWeight"`
+	// Ratings is each criterion's weighted average
+	Ratings map[string]float64 `dynamodbav:"Ratings" json:"ratings,omitempty"`
+}
+
+// DecayedRatings are running sums of each criterion's scores and of the review weights, decayed to UpdatedAt
+type DecayedRatings struct {
+	Sums      map[string]float64 `dynamodbav:"Sums"`
+	Weight    float64            `dynamodbav:"Weight"`
+	UpdatedAt int64              `dynamodbav:"UpdatedAt"`
 }
 
 type Review struct {
 	RoastKey string `dynamodbav:"PK" json:"-"`
 	// Using unique RoastID as SK generated from epoch time
 	ReviewKey      string `dynamodbav:"SK" json:"reviewKey"`
+	EntityType     string `dynamodbav:"EntityType" json:"-"`
 	RoastID        string `dynamodbav:"RoastID" json:"roastID"`
 	OverallRating  int    `dynamodbav:"OverallRating" json:"overallRating"`
 	MeatRating     int    `dynamodbav:"MeatRating" json:"meatRating"`
@@ -65,25 +148,65 @@ type Review struct {
 	VegRating      int    `dynamodbav:"VegRating" json:"vegRating"`
 	GravyRating    int    `dynamodbav:"GravyRating" json:"gravyRating"`
 	Comment        string `dynamodbav:"Comment,omitempty" json:"comment,omitempty"`
-	RoastName      string `dynamodbav:"RoastName" json:"roastName"`
-	// When you update your image it needs to update it on all of the users reviews?
-	// Like wise if they want to change their displayname ......
-	ImageURL    string `dynamodbav:"ImageURL" json:"imageURL"`
-	UserID      string `dynamodbav:"UserID" json:"userID"`
-	DisplayName string `dynamodbav:"Name" json:"displayName,omitempty"`
-	FirstName   string `dynamodbav:"FirstName" json:"firstName,omitempty"`
-	LastName    string `dynamodbav:"LastName" json:"lastName,omitempty"`
-	DateAdded   int    `dynamodbav:"DateAdded" json:"dateAdded"`
+	// Tags are what the reviewer says about the roast, from the tag vocabulary
+	Tags      []string `dynamodbav:"Tags,omitempty" json:"tags,omitempty"`
+	RoastName string   `dynamodbav:"RoastName" json:"roastName"`
+	ImageURL  string   `dynamodbav:"ImageURL,omitempty" json:"-"`
+	// Images are the variants of the reviewer's profile photo, updated on all of their reviews when it changes
+	Images      map[string]string `dynamodbav:"Images,omitempty" json:"images,omitempty"`
+	UserID      string            `dynamodbav:"UserID" json:"userID"`
+	DisplayName string            `dynamodbav:"Name" json:"displayName,omitempty"`
+	FirstName   string            `dynamodbav:"FirstName" json:"firstName,omitempty"`
+	LastName    string            `dynamodbav:"LastName" json:"lastName,omitempty"`
+	DateAdded   int               `dynamodbav:"DateAdded" json:"dateAdded"`
+	// Weight is how much the review counts towards weighted ratings, set when it's created or by migration 4
+	// for earlier reviews. 0 counts as 1.
+	Weight float64 `dynamodbav:"Weight,omitempty" json:"-"`
+	// Photos are attached through the review photo endpoints once they've been uploaded, in display order
+	Photos []Photo `dynamodbav:"Photos,omitempty" json:"photos,omitempty"`
+}
+
+// Photo is an uploaded image attached to a review
+type Photo struct {
+	// Key is the object key in the image bucket
+	Key string `dynamodbav:"Key" json:"key"`
+	URL string `dynamodbav:"URL" json:"url"`
+	// Images maps each variant of the photo to its URL, URL is the full variant
+	Images  map[string]string `dynamodbav:"Images,omitempty" json:"images,omitempty"`
+	Caption string            `dynamodbav:"Caption,omitempty" json:"caption,omitempty"`
+	Order   int               `dynamodbav:"Order" json:"order"`
 }
 
 type User struct {
-	UserKey         string   `dynamodbav:"PK" json:"userKey"`
-	SK              string   `dynamodbav:"SK" json:"-"`
-	ProfilePhotoUrl string   `dynamodbav:"ProfilePhotoUrl" json:"profilePhotoUrl,omitempty"`
-	SavedRoasts     []string `dynamodbav:"SavedRoasts" json:"savedRoasts,omitempty"`
-	FirstName       string   `dynamodbav:"FirstName" json:"firstName,omitempty"`
-	LastName        string   `dynamodbav:"LastName" json:"lastName,omitempty"`
-	DisplayName     string   `dynamodbav:"DisplayName" json:"displayName,omitempty"`
+	UserKey         string `dynamodbav:"PK" json:"userKey"`
+	SK              string `dynamodbav:"SK" json:"-"`
+	EntityType      string `dynamodbav:"EntityType" json:"-"`
+	ProfilePhotoUrl string `dynamodbav:"ProfilePhotoUrl,omitempty" json:"-"`
+	// Images are the variants of the user's profile photo, processed from the upload at ProfilePhotoKey
+	Images          map[string]string `dynamodbav:"Images,omitempty" json:"images,omitempty"`
+	ProfilePhotoKey string            `dynamodbav:"ProfilePhotoKey,omitempty" json:"-"`
+	SavedRoasts     []string          `dynamodbav:"SavedRoasts" json:"savedRoasts,omitempty"`
+	FirstName       string            `dynamodbav:"FirstName" json:"firstName,omitempty"`
+	LastName        string            `dynamodbav:"LastName" json:"lastName,omitempty"`
+	DisplayName     string            `dynamodbav:"DisplayName" json:"displayName,omitempty"`
+	Roles           []string          `dynamodbav:"Roles,omitempty" json:"roles,omitempty"`
+	// CreatedAt is when the account was created in epoch milliseconds, 0 for accounts that predate it
+	CreatedAt int64 `dynamodbav:"CreatedAt,omitempty" json:"createdAt,omitempty"`
+	// DeviationSum and DeviationCount track how far the user's overall scores are from each roast's average
+	DeviationSum   float64 `dynamodbav:"DeviationSum,omitempty" json:"-"`
+	DeviationCount int     `dynamodbav:"DeviationCount,omitempty" json:"-"`
+}
+
+// Roles that can be granted to users through roastctl. Admins can use the admin endpoints without an API
+// key and moderate tag suggestions, moderators can only moderate.
+const (
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+)
+
+// ValidRole reports whether role is one that can be granted
+func ValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleModerator
 }
 
 func NewRoastModels(dynamo *dynamodb.Client) RoastModels {
@@ -102,6 +225,7 @@ func NewUserModels(dynamo *dynamodb.Client) UserModels {
 }
 
 func (rm *RoastModels) CreateRoast(roast Roast) error {
+	roast.EntityType = EntityRoast
 	av, err := attributevalue.MarshalMap(roast)
 	if err != nil {
 		return err
@@ -189,7 +313,10 @@ func (rm *RoastModels) DeleteRoast(roastName string) error {
 func (rm *RoastModels) UpdateRoast(roast *Roast) error {
 	updateExpr := "set OverallRating = :or, MeatRating = :mr, PotatoesRating = :pr, VegRating = :vr, GravyRating = :gr, ReviewCount = :rc, " +
 		"MeatPotatoesRating = :mpr, MeatVegRating = :mvr, MeatGravyRating = :mgr, PotatoesVegRating = :pvr, " +
-		"PotatoesGravyRating = :pgr, VegGravyRating = :vgr, MeatPotatoesVegRating = :mprv, MeatPotatoesGravyRating = :mpgr, MeatVegGravyRating = :mvg"
+		"PotatoesGravyRating = :pgr, VegGravyRating = :vgr, MeatPotatoesVegRating = :mprv, MeatPotatoesGravyRating = :mpgr, MeatVegGravyRating = :mvg, " +
+		"RatingDistribution = :rd, Decay = :dc, Weighted = :wt, PhotoCount = :pc, TagCounts = :tc " +
+		// Ranking scores used to be stored, so any left from then are removed
+		"remove RankingScore"
 
 	exprAttrValues, err := attributevalue.MarshalMap(map[string]interface{}{
 		":or":   roast.OverallRating,
@@ -207,6 +334,11 @@ func (rm *RoastModels) UpdateRoast(roast *Roast) error {
 		":mpgr": roast.MeatPotatoesGravyRating,
 		":mvg":  roast.MeatVegGravyRating,
 		":rc":   roast.ReviewCount,
+		":rd":   roast.RatingDistribution,
+		":dc":   roast.Decay,
+		":wt":   roast.Weighted,
+		":pc":   roast.PhotoCount,
+		":tc":   roast.TagCounts,
 	})
 	// TODO - Wrap errors up stack
 	if err != nil {
@@ -232,6 +364,87 @@ func (rm *RoastModels) UpdateRoast(roast *Roast) error {
 	return nil
 }
 
+// UpdateRoastProfile updates the descriptive fields of a roast, leaving its ratings untouched
+func (rm *RoastModels) UpdateRoastProfile(roast *Roast) error {
+	values := map[string]interface{}{
+		":n": roast.Name,
+		":i": roast.Images,
+		":p": roast.PriceRange,
+		":l": roast.Location,
+		":a": roast.Address,
+		":t": roast.Tags,
+	}
+	// Index keys can't be empty strings, so roasts without coordinates are removed from the geo index instead
+	updateExpression := "set #n = :n, Images = :i, PriceRange = :p, Location = :l, Address = :a, Tags = :t"
+	if roast.Geohash != "" {
+		values[":gc"], values[":gh"] = roast.GeoCell, roast.Geohash
+		updateExpression += ", GeoCell = :gc, Geohash = :gh"
+	} else {
+		updateExpression += " remove GeoCell, Geohash"
+	}
+	exprAttrValues, err := attributevalue.MarshalMap(values)
+	if err != nil {
+		return fmt.Errorf("error marshalling attribute values for update: %w", err)
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: roast.RoastKey},
+			"SK": &types.AttributeValueMemberS{Value: roast.SK},
+		},
+		TableName: aws.String(rm.tableName),
+		// Name is a reserved word in dynamodb
+		UpdateExpression:          aws.String(updateExpression),
+		ExpressionAttributeNames:  map[string]string{"#n": "Name"},
+		ExpressionAttributeValues: exprAttrValues,
+	}
+
+	_, err = rm.client.UpdateItem(context.Background(), input)
+	return err
+}
+
+// SetRoastTags replaces the tags of a roast
+func (rm *RoastModels) SetRoastTags(roast *Roast, tags []string) error {
+	av, err := attributevalue.Marshal(tags)
+	if err != nil {
+		return fmt.Errorf("error marshalling tags: %w", err)
+	}
+
+	_, err = rm.client.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+		TableName: aws.String(rm.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: roast.RoastKey},
+			"SK": &types.AttributeValueMemberS{Value: roast.SK},
+		},
+		UpdateExpression:          aws.String("set Tags = :t"),
+		ConditionExpression:       aws.String("attribute_exists(PK)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":t": av},
+	})
+	if err != nil {
+		return fmt.Errorf("error updating roast tags: %w", err)
+	}
+	roast.Tags = tags
+	return nil
+}
+
+// AddPhotoCount adds delta to the number of photos attached to the roast's reviews
+func (rm *RoastModels) AddPhotoCount(roast *Roast, delta int) error {
+	_, err := rm.client.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+		TableName: aws.String(rm.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: roast.RoastKey},
+			"SK": &types.AttributeValueMemberS{Value: roast.SK},
+		},
+		UpdateExpression:          aws.String("add PhotoCount :d"),
+		ConditionExpression:       aws.String("attribute_exists(PK)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":d": &types.AttributeValueMemberN{Value: strconv.Itoa(delta)}},
+	})
+	if err != nil {
+		return fmt.Errorf("error updating photo count: %w", err)
+	}
+	return nil
+}
+
 // GetRoastByPrefix retrieves a roast by its prefix
 func (rm *RoastModels) GetRoastByPrefix(roastPrefix string) (*Roast, error) {
 	input := &dynamodb.QueryInput{
@@ -264,35 +477,106 @@ func (rm *RoastModels) GetRoastByPrefix(roastPrefix string) (*Roast, error) {
 
 // GetAllRoasts performs a scan of dynamodb to get all roasts
 func (rm *RoastModels) GetAllRoasts() ([]Roast, error) {
+	return rm.ScanRoasts(RoastScan{})
+}
+
+// RoastScan narrows down the roasts returned by a scan, zero values don't narrow it
+type RoastScan struct {
+	MinPrice   int
+	MaxPrice   int
+	MinReviews int
+	HasPhotos  bool
+}
+
+// ScanRoasts performs a scan of dynamodb to get the roasts matching scan. The scan still reads every item,
+// the filter saves returning and unmarshalling the roasts that don't match.
+func (rm *RoastModels) ScanRoasts(scan RoastScan) ([]Roast, error) {
+	filter := []string{"begins_with(PK, :pkval)", "begins_with(SK, :skval)"}
+	values := map[string]types.AttributeValue{
+		":pkval": &types.AttributeValueMemberS{Value: "ROAST#"},
+		":skval": &types.AttributeValueMemberS{Value: "PROFILE"},
+	}
+	number := func(n int) types.AttributeValue {
+		return &types.AttributeValueMemberN{Value: strconv.Itoa(n)}
+	}
+	if scan.MinPrice != 0 {
+		filter = append(filter, "PriceRange >= :minPrice")
+		values[":minPrice"] = number(scan.MinPrice)
+	}
+	// Roasts without a price range aren't excluded by a maximum, as they aren't when filtering in memory
+	if scan.MaxPrice != 0 {
+		filter = append(filter, "(attribute_not_exists(PriceRange) or PriceRange <= :maxPrice)")
+		values[":maxPrice"] = number(scan.MaxPrice)
+	}
+	if scan.MinReviews > 0 {
+		filter = append(filter, "ReviewCount >= :minReviews")
+		values[":minReviews"] = number(scan.MinReviews)
+	}
+	if scan.HasPhotos {
+		filter = append(filter, "PhotoCount > :noPhotos")
+		values[":noPhotos"] = number(0)
+	}
+
 	input := &dynamodb.ScanInput{
-		TableName:        aws.String(rm.tableName),
-		FilterExpression: aws.String("begins_with(PK, :pkval)"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":pkval": &types.AttributeValueMemberS{Value: "ROAST#"},
-		},
+		TableName:                 aws.String(rm.tableName),
+		FilterExpression:          aws.String(strings.Join(filter, " and ")),
+		ExpressionAttributeValues: values,
 	}
 
-	result, err := rm.client.Scan(context.Background(), input)
-	if err != nil {
-		return nil, err
+	var roasts []Roast
+	paginator := dynamodb.NewScanPaginator(rm.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, err
+		}
+
+		var items []Roast
+		err = attributevalue.UnmarshalListOfMaps(page.Items, &items)
+		if err != nil {
+			return nil, err
+		}
+		roasts = append(roasts, items...)
 	}
+	return roasts, nil
+}
 
-	var items []Roast
-	err = attributevalue.UnmarshalListOfMaps(result.Items, &items)
-	if err != nil {
-		return nil, err
+// GetRoastsInCell retrieves the roasts in a geohash cell through the geo index. Cells finer than the
+// index's partitions are read from the partition containing them by their geohash prefix.
+func (rm *RoastModels) GetRoastsInCell(cell string) ([]Roast, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(rm.tableName),
+		IndexName:              aws.String(dynamo.GeoIndex),
+		KeyConditionExpression: aws.String("GeoCell = :cell"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":cell": &types.AttributeValueMemberS{Value: cell},
+		},
+	}
+	if len(cell) > geo.CellPrecision {
+		input.KeyConditionExpression = aws.String("GeoCell = :cell and begins_with(Geohash, :prefix)")
+		input.ExpressionAttributeValues[":cell"] = &types.AttributeValueMemberS{Value: cell[:geo.CellPrecision]}
+		input.ExpressionAttributeValues[":prefix"] = &types.AttributeValueMemberS{Value: cell}
 	}
 
 	var roasts []Roast
-	for _, item := range items {
-		if strings.HasPrefix(item.SK, "PROFILE") {
-			roasts = append(roasts, item)
+	paginator := dynamodb.NewQueryPaginator(rm.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("error querying geo cell %s: %w", cell, err)
+		}
+
+		var items []Roast
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, err
 		}
+		roasts = append(roasts, items...)
 	}
 	return roasts, nil
 }
 
 func (rm *ReviewModels) CreateReview(review Review) error {
+	review.EntityType = EntityReview
 	av, err := attributevalue.MarshalMap(review)
 	if err != nil {
 		return err
@@ -317,14 +601,47 @@ func (rm *ReviewModels) GetReviewsByRoast(roastKey string) ([]Review, error) {
 		},
 	}
 
-	result, err := rm.client.Query(context.Background(), input)
-	if err != nil {
-		return nil, err
+	var reviews []Review
+	paginator := dynamodb.NewQueryPaginator(rm.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		var items []Review
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, items...)
+	}
+	return reviews, nil
+}
+
+// GetAllReviews scans for every review across all roasts
+func (rm *ReviewModels) GetAllReviews() ([]Review, error) {
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(rm.tableName),
+		FilterExpression: aws.String("begins_with(PK, :pkval) and begins_with(SK, :skval)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pkval": &types.AttributeValueMemberS{Value: "ROAST#"},
+			":skval": &types.AttributeValueMemberS{Value: "REVIEW#"},
+		},
 	}
 
 	var reviews []Review
-	err = attributevalue.UnmarshalListOfMaps(result.Items, &reviews)
-	return reviews, err
+	paginator := dynamodb.NewScanPaginator(rm.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		var items []Review
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, items...)
+	}
+	return reviews, nil
 }
 
 func (rm *ReviewModels) GetReviewByKey(roastKey, reviewKey string) (*Review, error) {
@@ -343,7 +660,7 @@ func (rm *ReviewModels) GetReviewByKey(roastKey, reviewKey string) (*Review, err
 	}
 
 	if result.Item == nil {
-		return nil, fmt.Errorf("no review found with key: %s", reviewKey)
+		return nil, fmt.Errorf("%w with key: %s", ErrReviewNotFound, reviewKey)
 	}
 
 	var review Review
@@ -357,6 +674,64 @@ func (rm *ReviewModels) GetReviewByKey(roastKey, reviewKey string) (*Review, err
 	return &review, nil
 }
 
+// UpdateReviewerImages sets the reviewer's profile photo shown on a review, removing it if images is empty
+func (rm *ReviewModels) UpdateReviewerImages(roastKey, reviewKey string, images map[string]string) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(rm.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: roastKey},
+			"SK": &types.AttributeValueMemberS{Value: reviewKey},
+		},
+		ConditionExpression: aws.String("attribute_exists(PK)"),
+		UpdateExpression:    aws.String("REMOVE Images, ImageURL"),
+	}
+	if len(images) > 0 {
+		av, err := attributevalue.Marshal(images)
+		if err != nil {
+			return fmt.Errorf("error marshalling images: %w", err)
+		}
+		input.UpdateExpression = aws.String("SET Images = :i REMOVE ImageURL")
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{":i": av}
+	}
+
+	_, err := rm.client.UpdateItem(context.Background(), input)
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return fmt.Errorf("%w with key: %s", ErrReviewNotFound, reviewKey)
+	}
+	if err != nil {
+		return fmt.Errorf("error updating reviewer images: %w", err)
+	}
+	return nil
+}
+
+// UpdateReviewPhotos replaces the photos attached to a review
+func (rm *ReviewModels) UpdateReviewPhotos(roastKey, reviewKey string, photos []Photo) error {
+	av, err := attributevalue.Marshal(photos)
+	if err != nil {
+		return fmt.Errorf("error marshalling photos: %w", err)
+	}
+
+	_, err = rm.client.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+		TableName: aws.String(rm.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: roastKey},
+			"SK": &types.AttributeValueMemberS{Value: reviewKey},
+		},
+		UpdateExpression:          aws.String("set Photos = :p"),
+		ConditionExpression:       aws.String("attribute_exists(PK)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":p": av},
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return fmt.Errorf("%w with key: %s", ErrReviewNotFound, reviewKey)
+	}
+	if err != nil {
+		return fmt.Errorf("error updating review photos: %w", err)
+	}
+	return nil
+}
+
 func (rm *ReviewModels) RemoveReview(roastKey, reviewKey string) error {
 	input := &dynamodb.DeleteItemInput{
 		TableName: aws.String(rm.tableName),
@@ -404,6 +779,10 @@ func (rm *UserModels) GetUserByPrefix(userPrefix string) (*User, error) {
 
 // CreateUser creates a new user in DynamoDB
 func (um *UserModels) CreateUser(user User) error {
+	user.EntityType = EntityUser
+	if user.CreatedAt == 0 {
+		user.CreatedAt = time.Now().UnixMilli()
+	}
 	av, err := attributevalue.MarshalMap(user)
 	if err != nil {
 		return err
@@ -496,47 +875,53 @@ func (um *UserModels) RemoveSavedRoast(userID, roastID string) error {
 
 // GetUserReviews retrieves all reviews a user has made from dynamoDB
 func (rm *UserModels) GetUserReviews(userID string) ([]Review, error) {
-	// TODO - query doesn't work would need secondary index if scales to avoid scanning
-	//input := &dynamodb.QueryInput{
-	//	TableName:              aws.String(rm.tableName),
-	//	KeyConditionExpression: aws.String("PK = :pkval and begins_with(SK, :skval)"),
-	//	ExpressionAttributeValues: map[string]types.AttributeValue{
-	//		":pkval":  &types.AttributeValueMemberS{Value: "ROAST#"},
-	//		":skval":  &types.AttributeValueMemberS{Value: "REVIEW#"},
-	//		":userID": &types.AttributeValueMemberS{Value: userID},
-	//	},
-	//	FilterExpression: aws.String("userID = :userID"),
-	//}
-
-	input := &dynamodb.ScanInput{
-		TableName:        aws.String(rm.tableName),
-		FilterExpression: aws.String("UserID = :userID"),
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(rm.tableName),
+		IndexName:              aws.String(dynamo.UserIndex),
+		KeyConditionExpression: aws.String("UserID = :userID and begins_with(SK, :skval)"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":userID": &types.AttributeValueMemberS{Value: userID},
+			":skval":  &types.AttributeValueMemberS{Value: "REVIEW#"},
 		},
 	}
 
 	// TODO - Should probably pass ctx through rather than use background
-	result, err := rm.client.Scan(context.Background(), input)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(result.Items) == 0 {
-		return nil, nil
-	}
-
 	var reviews []Review
-	for _, item := range result.Items {
-		var review Review
-		err = attributevalue.UnmarshalMap(item, &review)
+	paginator := dynamodb.NewQueryPaginator(rm.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
 		if err != nil {
 			return nil, err
 		}
-		reviews = append(reviews, review)
+		var items []Review
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, items...)
 	}
+	return reviews, nil
+}
 
-	return reviews, err
+// SetRoles replaces the roles granted to a user
+func (um *UserModels) SetRoles(userID string, roles []string) error {
+	userKey := "USER#" + userID
+	user, err := um.GetUserByPrefix(userKey)
+	if err != nil {
+		return fmt.Errorf("error retrieving user: %w", err)
+	}
+	if user == nil {
+		return fmt.Errorf("user not found with userID: %s", userID)
+	}
+	for _, role := range roles {
+		if !ValidRole(role) {
+			return fmt.Errorf("invalid role: %s", role)
+		}
+	}
+	user.Roles = roles
+	if err := um.UpdateUser(*user); err != nil {
+		return fmt.Errorf("error updating users roles: %w", err)
+	}
+	return nil
 }
 
 // UpdateSettings retrieves all reviews a user has made from dynamoDB
@@ -557,3 +942,60 @@ func (um *UserModels) UpdateSettings(userID, displayName, firstName, lastName st
 	}
 	return nil
 }
+
+// AddReviewDeviation records how far one of the user's reviews was from the roast's average
+func (um *UserModels) AddReviewDeviation(userID string, deviation float64) error {
+	userKey := "USER#" + userID
+	_, err := um.client.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+		TableName: aws.String(um.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: userKey},
+			"SK": &types.AttributeValueMemberS{Value: "PROFILE#" + userID},
+		},
+		UpdateExpression:    aws.String("ADD DeviationSum :d, DeviationCount :one"),
+		ConditionExpression: aws.String("attribute_exists(PK)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":d":   &types.AttributeValueMemberN{Value: strconv.FormatFloat(deviation, 'f', -1, 64)},
+			":one": &types.AttributeValueMemberN{Value: "1"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error recording review deviation: %w", err)
+	}
+	return nil
+}
+
+// SetProfilePhoto sets the user's profile photo to the variants processed from key, removing it if key is empty
+func (um *UserModels) SetProfilePhoto(userID, key string, images map[string]string) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(um.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "USER#" + userID},
+			"SK": &types.AttributeValueMemberS{Value: "PROFILE#" + userID},
+		},
+		ConditionExpression: aws.String("attribute_exists(PK)"),
+		// The single URL from before variants is superseded either way
+		UpdateExpression: aws.String("REMOVE ProfilePhotoUrl, Images, ProfilePhotoKey"),
+	}
+	if key != "" {
+		av, err := attributevalue.Marshal(images)
+		if err != nil {
+			return fmt.Errorf("error marshalling images: %w", err)
+		}
+		input.UpdateExpression = aws.String("SET Images = :i, ProfilePhotoKey = :k REMOVE ProfilePhotoUrl")
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":i": av,
+			":k": &types.AttributeValueMemberS{Value: key},
+		}
+	}
+
+	_, err := um.client.UpdateItem(context.Background(), input)
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return fmt.Errorf("user not found with userID: %s", userID)
+	}
+	if err != nil {
+		return fmt.Errorf("error updating profile photo: %w", err)
+	}
+	return nil
+}
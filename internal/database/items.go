@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ItemModels works with raw items regardless of entity type, used for exporting and importing the table
+type ItemModels struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewItemModels(dynamo *dynamodb.Client) ItemModels {
+	tn := os.Getenv("TABLE_NAME")
+	return ItemModels{client: dynamo, tableName: tn}
+}
+
+// ScanAll calls fn for every item in the table, following pagination
+func (im *ItemModels) ScanAll(fn func(item map[string]types.AttributeValue) error) error {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(im.tableName),
+	}
+
+	paginator := dynamodb.NewScanPaginator(im.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return fmt.Errorf("failed to scan items: %w", err)
+		}
+		for _, item := range page.Items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PutItem writes a raw item, replacing any existing item with the same keys
+func (im *ItemModels) PutItem(item map[string]types.AttributeValue) error {
+	input := &dynamodb.PutItemInput{
+		Item:      item,
+		TableName: aws.String(im.tableName),
+	}
+	_, err := im.client.PutItem(context.Background(), input)
+	return err
+}
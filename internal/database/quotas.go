@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const EntityUploadQuota = "UploadQuota"
+
+// ErrQuotaExceeded is returned when a user has used up their uploads for the day
+var ErrQuotaExceeded = errors.New("daily upload quota exceeded")
+
+// quotaRetention is how long quota items are kept before DynamoDB's TTL removes them
+const quotaRetention = 48 * time.Hour
+
+// UseUploadQuota counts an upload against the user's quota for the day, stored under the user as QUOTA#<yyyy-mm-dd>.
+// It returns ErrQuotaExceeded without counting the upload if the user has already made limit uploads.
+func (um *UserModels) UseUploadQuota(userID string, day time.Time, limit int) error {
+	day = day.UTC()
+	expiresAt := day.Truncate(24 * time.Hour).Add(quotaRetention)
+
+	_, err := um.client.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+		TableName: aws.String(um.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "USER#" + userID},
+			"SK": &types.AttributeValueMemberS{Value: "QUOTA#" + day.Format("2006-01-02")},
+		},
+		UpdateExpression:    aws.String("ADD UploadCount :one SET EntityType = :et, ExpiresAt = :exp"),
+		ConditionExpression: aws.String("attribute_not_exists(UploadCount) OR UploadCount < :limit"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one":   &types.AttributeValueMemberN{Value: "1"},
+			":limit": &types.AttributeValueMemberN{Value: strconv.Itoa(limit)},
+			":et":    &types.AttributeValueMemberS{Value: EntityUploadQuota},
+			":exp":   &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)},
+		},
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return ErrQuotaExceeded
+	}
+	if err != nil {
+		return fmt.Errorf("error updating upload quota: %w", err)
+	}
+	return nil
+}
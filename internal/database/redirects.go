@@ -0,0 +1,164 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	EntityRoastRedirect = "RoastRedirect"
+	redirectSK          = "REDIRECT"
+	// maxRedirects is how many merges in a row are followed when resolving a roast ID
+	maxRedirects = 5
+)
+
+// RoastRedirect is left in place of a roast merged into another, so its old ID still resolves
+type RoastRedirect struct {
+	RoastKey   string `dynamodbav:"PK" json:"-"`
+	SK         string `dynamodbav:"SK" json:"-"`
+	EntityType string `dynamodbav:"EntityType" json:"-"`
+	RoastID    string `dynamodbav:"RoastID" json:"roastID"`
+	TargetID   string `dynamodbav:"TargetID" json:"targetID"`
+	// CreatedAt is when the roast was merged in epoch milliseconds
+	CreatedAt int64 `dynamodbav:"CreatedAt" json:"createdAt"`
+}
+
+// PutRedirect records that roastID has been merged into targetID
+func (rm *RoastModels) PutRedirect(roastID, targetID string) error {
+	av, err := attributevalue.MarshalMap(RoastRedirect{
+		RoastKey:   "ROAST#" + roastID,
+		SK:         redirectSK,
+		EntityType: EntityRoastRedirect,
+		RoastID:    roastID,
+		TargetID:   targetID,
+		CreatedAt:  time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return fmt.Errorf("error marshalling redirect: %w", err)
+	}
+
+	_, err = rm.client.PutItem(context.Background(), &dynamodb.PutItemInput{
+		TableName: aws.String(rm.tableName),
+		Item:      av,
+	})
+	return err
+}
+
+// ResolveRoastID returns the ID of the roast that roastID was merged into, following merges of merged
+// roasts, or roastID itself if it hasn't been merged
+func (rm *RoastModels) ResolveRoastID(roastID string) (string, error) {
+	for i := 0; i < maxRedirects; i++ {
+		result, err := rm.client.GetItem(context.Background(), &dynamodb.GetItemInput{
+			TableName: aws.String(rm.tableName),
+			Key: map[string]types.AttributeValue{
+				"PK": &types.AttributeValueMemberS{Value: "ROAST#" + roastID},
+				"SK": &types.AttributeValueMemberS{Value: redirectSK},
+			},
+		})
+		if err != nil {
+			return "", err
+		}
+		if result.Item == nil {
+			return roastID, nil
+		}
+
+		var redirect RoastRedirect
+		if err := attributevalue.UnmarshalMap(result.Item, &redirect); err != nil {
+			return "", err
+		}
+		roastID = redirect.TargetID
+	}
+	return "", errors.New("too many redirects")
+}
+
+// GetRoastByID gets a roast, following the redirect left by merging it into another roast. The roast
+// returned has the ID it was merged into.
+func (rm *RoastModels) GetRoastByID(roastID string) (*Roast, error) {
+	roast, err := rm.GetRoastByPrefix("ROAST#" + roastID)
+	if err != nil || roast != nil {
+		return roast, err
+	}
+	resolved, err := rm.ResolveRoastID(roastID)
+	if err != nil || resolved == roastID {
+		return nil, err
+	}
+	return rm.GetRoastByPrefix("ROAST#" + resolved)
+}
+
+// MoveReview moves a review to another roast, keeping its review key. It fails if the target roast
+// already has a review with the same key.
+func (rm *ReviewModels) MoveReview(review Review, target *Roast) error {
+	oldKey := itemKey(review.RoastKey, review.ReviewKey)
+	review.RoastKey = target.RoastKey
+	review.RoastID = target.RoastID
+	review.RoastName = target.Name
+	review.EntityType = EntityReview
+	av, err := attributevalue.MarshalMap(review)
+	if err != nil {
+		return fmt.Errorf("error marshalling review: %w", err)
+	}
+	if err := moveItem(rm.client, rm.tableName, oldKey, av); err != nil {
+		return fmt.Errorf("error moving review %s: %w", review.ReviewKey, err)
+	}
+	return nil
+}
+
+// moveItem replaces the item at oldKey with item in a single transaction, failing if item's key is already taken
+func moveItem(client *dynamodb.Client, tableName string, oldKey, item map[string]types.AttributeValue) error {
+	_, err := client.TransactWriteItems(context.Background(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Put: &types.Put{
+				TableName:           aws.String(tableName),
+				Item:                item,
+				ConditionExpression: aws.String("attribute_not_exists(PK)"),
+			}},
+			{Delete: &types.Delete{
+				TableName:           aws.String(tableName),
+				Key:                 oldKey,
+				ConditionExpression: aws.String("attribute_exists(PK)"),
+			}},
+		},
+	})
+	return err
+}
+
+func itemKey(pk, sk string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: pk},
+		"SK": &types.AttributeValueMemberS{Value: sk},
+	}
+}
+
+// GetUsersWithSavedRoast scans for the users who have saved roastID
+func (um *UserModels) GetUsersWithSavedRoast(roastID string) ([]User, error) {
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(um.tableName),
+		FilterExpression: aws.String("EntityType = :entity and contains(SavedRoasts, :roastID)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":entity":  &types.AttributeValueMemberS{Value: EntityUser},
+			":roastID": &types.AttributeValueMemberS{Value: roastID},
+		},
+	}
+
+	var users []User
+	paginator := dynamodb.NewScanPaginator(um.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		var items []User
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, err
+		}
+		users = append(users, items...)
+	}
+	return users, nil
+}
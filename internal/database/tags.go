@@ -0,0 +1,258 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	EntityTagCategory   = "TagCategory"
+	EntityTagSuggestion = "TagSuggestion"
+)
+
+// tagsKey is the partition every tag category is stored under, so the whole vocabulary is a single query
+const tagsKey = "TAGS"
+
+// ErrAlreadySuggested is returned when a user suggests a tag for a roast they've already suggested it for
+var ErrAlreadySuggested = errors.New("tag already suggested")
+
+type TagModels struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+// TagCategory groups related tags, e.g. dietary
+type TagCategory struct {
+	PK         string `dynamodbav:"PK" json:"-"`
+	SK         string `dynamodbav:"SK" json:"-"`
+	EntityType string `dynamodbav:"EntityType" json:"-"`
+	ID         string `dynamodbav:"CategoryID" json:"id"`
+	Name       string `dynamodbav:"Name" json:"name"`
+	Tags       []Tag  `dynamodbav:"Tags" json:"tags"`
+}
+
+// Tag is identified by a slug, e.g. gluten-free-gravy, which is what's stored on roasts and reviews
+type Tag struct {
+	ID   string `dynamodbav:"ID" json:"id"`
+	Name string `dynamodbav:"Name" json:"name"`
+}
+
+// TagSuggestion is a user's suggestion that a roast has a tag, waiting for a moderator to approve or reject it
+type TagSuggestion struct {
+	RoastKey   string `dynamodbav:"PK" json:"-"`
+	SK         string `dynamodbav:"SK" json:"-"`
+	EntityType string `dynamodbav:"EntityType" json:"-"`
+	RoastID    string `dynamodbav:"RoastID" json:"roastID"`
+	Tag        string `dynamodbav:"Tag" json:"tag"`
+	UserID     string `dynamodbav:"UserID" json:"userID"`
+	CreatedAt  int64  `dynamodbav:"CreatedAt" json:"createdAt"`
+}
+
+func NewTagModels(dynamo *dynamodb.Client) TagModels {
+	tn := os.Getenv("TABLE_NAME")
+	return TagModels{client: dynamo, tableName: tn}
+}
+
+// GetTagCategories returns every tag category
+func (tm *TagModels) GetTagCategories() ([]TagCategory, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(tm.tableName),
+		KeyConditionExpression: aws.String("PK = :pkval and begins_with(SK, :skval)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pkval": &types.AttributeValueMemberS{Value: tagsKey},
+			":skval": &types.AttributeValueMemberS{Value: "CATEGORY#"},
+		},
+	}
+
+	categories := []TagCategory{}
+	paginator := dynamodb.NewQueryPaginator(tm.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		var items []TagCategory
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, err
+		}
+		categories = append(categories, items...)
+	}
+	return categories, nil
+}
+
+// PutTagCategory creates a tag category or replaces the one with the same ID
+func (tm *TagModels) PutTagCategory(category TagCategory) error {
+	category.PK = tagsKey
+	category.SK = "CATEGORY#" + category.ID
+	category.EntityType = EntityTagCategory
+	av, err := attributevalue.MarshalMap(category)
+	if err != nil {
+		return fmt.Errorf("error marshalling tag category: %w", err)
+	}
+
+	_, err = tm.client.PutItem(context.Background(), &dynamodb.PutItemInput{
+		TableName: aws.String(tm.tableName),
+		Item:      av,
+	})
+	return err
+}
+
+// DeleteTagCategory deletes a tag category, roasts and reviews keep any of its tags they already have
+func (tm *TagModels) DeleteTagCategory(id string) error {
+	_, err := tm.client.DeleteItem(context.Background(), &dynamodb.DeleteItemInput{
+		TableName: aws.String(tm.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: tagsKey},
+			"SK": &types.AttributeValueMemberS{Value: "CATEGORY#" + id},
+		},
+	})
+	return err
+}
+
+// CreateTagSuggestion stores a suggestion, each user can only suggest each tag once for a roast
+func (tm *TagModels) CreateTagSuggestion(suggestion TagSuggestion) error {
+	suggestion.RoastKey = "ROAST#" + suggestion.RoastID
+	suggestion.SK = tagSuggestionPrefix(suggestion.Tag) + suggestion.UserID
+	suggestion.EntityType = EntityTagSuggestion
+	if suggestion.CreatedAt == 0 {
+		suggestion.CreatedAt = time.Now().UnixMilli()
+	}
+	av, err := attributevalue.MarshalMap(suggestion)
+	if err != nil {
+		return fmt.Errorf("error marshalling tag suggestion: %w", err)
+	}
+
+	_, err = tm.client.PutItem(context.Background(), &dynamodb.PutItemInput{
+		TableName:           aws.String(tm.tableName),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(PK)"),
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return ErrAlreadySuggested
+	}
+	return err
+}
+
+// GetTagSuggestions scans for every pending tag suggestion
+func (tm *TagModels) GetTagSuggestions() ([]TagSuggestion, error) {
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(tm.tableName),
+		FilterExpression: aws.String("EntityType = :et"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":et": &types.AttributeValueMemberS{Value: EntityTagSuggestion},
+		},
+	}
+
+	suggestions := []TagSuggestion{}
+	paginator := dynamodb.NewScanPaginator(tm.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		var items []TagSuggestion
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, items...)
+	}
+	return suggestions, nil
+}
+
+// DeleteTagSuggestions deletes every user's suggestion of a tag for a roast, returning how many there were
+func (tm *TagModels) DeleteTagSuggestions(roastID, tag string) (int, error) {
+	roastKey := "ROAST#" + roastID
+	result, err := tm.client.Query(context.Background(), &dynamodb.QueryInput{
+		TableName:              aws.String(tm.tableName),
+		KeyConditionExpression: aws.String("PK = :pkval and begins_with(SK, :skval)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pkval": &types.AttributeValueMemberS{Value: roastKey},
+			":skval": &types.AttributeValueMemberS{Value: tagSuggestionPrefix(tag)},
+		},
+		ProjectionExpression: aws.String("SK"),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error querying tag suggestions: %w", err)
+	}
+
+	for _, item := range result.Items {
+		_, err := tm.client.DeleteItem(context.Background(), &dynamodb.DeleteItemInput{
+			TableName: aws.String(tm.tableName),
+			Key: map[string]types.AttributeValue{
+				"PK": &types.AttributeValueMemberS{Value: roastKey},
+				"SK": item["SK"],
+			},
+		})
+		if err != nil {
+			return 0, fmt.Errorf("error deleting tag suggestion: %w", err)
+		}
+	}
+	return len(result.Items), nil
+}
+
+// GetRoastTagSuggestions returns the pending tag suggestions for a roast
+func (tm *TagModels) GetRoastTagSuggestions(roastID string) ([]TagSuggestion, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(tm.tableName),
+		KeyConditionExpression: aws.String("PK = :pkval and begins_with(SK, :skval)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pkval": &types.AttributeValueMemberS{Value: "ROAST#" + roastID},
+			":skval": &types.AttributeValueMemberS{Value: "TAGSUGGESTION#"},
+		},
+	}
+
+	suggestions := []TagSuggestion{}
+	paginator := dynamodb.NewQueryPaginator(tm.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		var items []TagSuggestion
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, items...)
+	}
+	return suggestions, nil
+}
+
+// MoveTagSuggestion moves a tag suggestion to another roast. It fails if the same user has already
+// suggested the tag for the target.
+func (tm *TagModels) MoveTagSuggestion(suggestion TagSuggestion, targetID string) error {
+	oldKey := itemKey(suggestion.RoastKey, suggestion.SK)
+	suggestion.RoastID = targetID
+	suggestion.RoastKey = "ROAST#" + targetID
+	suggestion.SK = tagSuggestionPrefix(suggestion.Tag) + suggestion.UserID
+	suggestion.EntityType = EntityTagSuggestion
+	av, err := attributevalue.MarshalMap(suggestion)
+	if err != nil {
+		return fmt.Errorf("error marshalling tag suggestion: %w", err)
+	}
+	if err := moveItem(tm.client, tm.tableName, oldKey, av); err != nil {
+		return fmt.Errorf("error moving tag suggestion %s: %w", suggestion.SK, err)
+	}
+	return nil
+}
+
+// DeleteTagSuggestion deletes a single user's suggestion of a tag
+func (tm *TagModels) DeleteTagSuggestion(suggestion TagSuggestion) error {
+	_, err := tm.client.DeleteItem(context.Background(), &dynamodb.DeleteItemInput{
+		TableName: aws.String(tm.tableName),
+		Key:       itemKey(suggestion.RoastKey, suggestion.SK),
+	})
+	return err
+}
+
+func tagSuggestionPrefix(tag string) string {
+	return "TAGSUGGESTION#" + tag + "#"
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const EntityTrend = "Trend"
+
+// Trend holds the ratings given to a roast in one month, stored under the roast as TREND#<yyyy-mm>
+type Trend struct {
+	RoastKey    string `dynamodbav:"PK" json:"-"`
+	SK          string `dynamodbav:"SK" json:"-"`
+	EntityType  string `dynamodbav:"EntityType" json:"-"`
+	RoastID     string `dynamodbav:"RoastID" json:"roastID"`
+	Month       string `dynamodbav:"Month" json:"month"`
+	Count       int    `dynamodbav:"ReviewCount" json:"count"`
+	OverallSum  int    `dynamodbav:"OverallSum" json:"-"`
+	MeatSum     int    `dynamodbav:"MeatSum" json:"-"`
+	PotatoesSum int    `dynamodbav:"PotatoesSum" json:"-"`
+	VegSum      int    `dynamodbav:"VegSum" json:"-"`
+	GravySum    int    `dynamodbav:"GravySum" json:"-"`
+}
+
+// AddToTrend atomically adds a review's ratings to a roast's monthly trend, use a delta of -1 to remove them
+func (rm *RoastModels) AddToTrend(review Review, month string, delta int) error {
+	roastID := review.RoastID
+	if roastID == "" {
+		roastID = strings.TrimPrefix(review.RoastKey, "ROAST#")
+	}
+
+	exprAttrValues, err := attributevalue.MarshalMap(map[string]interface{}{
+		":n":  delta,
+		":o":  review.OverallRating * delta,
+		":m":  review.MeatRating * delta,
+		":p":  review.PotatoesRating * delta,
+		":v":  review.VegRating * delta,
+		":g":  review.GravyRating * delta,
+		":id": roastID,
+		":mo": month,
+		":et": EntityTrend,
+	})
+	if err != nil {
+		return fmt.Errorf("error marshalling attribute values for update: %w", err)
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: review.RoastKey},
+			"SK": &types.AttributeValueMemberS{Value: "TREND#" + month},
+		},
+		TableName:                 aws.String(rm.tableName),
+		UpdateExpression:          aws.String("ADD ReviewCount :n, OverallSum :o, MeatSum :m, PotatoesSum :p, VegSum :v, GravySum :g SET RoastID = :id, #mo = :mo, EntityType = :et"),
+		ExpressionAttributeNames:  map[string]string{"#mo": "Month"},
+		ExpressionAttributeValues: exprAttrValues,
+	}
+
+	_, err = rm.client.UpdateItem(context.Background(), input)
+	return err
+}
+
+// PutTrend replaces a roast's monthly trend, used when rebuilding trends from reviews
+func (rm *RoastModels) PutTrend(trend Trend) error {
+	trend.SK = "TREND#" + trend.Month
+	trend.EntityType = EntityTrend
+	av, err := attributevalue.MarshalMap(trend)
+	if err != nil {
+		return err
+	}
+	input := &dynamodb.PutItemInput{
+		Item:      av,
+		TableName: aws.String(rm.tableName),
+	}
+	_, err = rm.client.PutItem(context.Background(), input)
+	return err
+}
+
+// GetTrends returns a roast's monthly trends from fromMonth onwards, oldest first
+func (rm *RoastModels) GetTrends(roastKey, fromMonth string) ([]Trend, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(rm.tableName),
+		KeyConditionExpression: aws.String("PK = :pkval and SK between :from and :to"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pkval": &types.AttributeValueMemberS{Value: roastKey},
+			":from":  &types.AttributeValueMemberS{Value: "TREND#" + fromMonth},
+			// ~ sorts after every digit so this includes all later months
+			":to": &types.AttributeValueMemberS{Value: "TREND#~"},
+		},
+	}
+
+	var trends []Trend
+	paginator := dynamodb.NewQueryPaginator(rm.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		var items []Trend
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, err
+		}
+		trends = append(trends, items...)
+	}
+	return trends, nil
+}
+
+// GetAllTrends scans for every roast's monthly trends from fromMonth onwards
+func (rm *RoastModels) GetAllTrends(fromMonth string) ([]Trend, error) {
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(rm.tableName),
+		FilterExpression: aws.String("begins_with(PK, :pkval) and SK between :from and :to"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pkval": &types.AttributeValueMemberS{Value: "ROAST#"},
+			":from":  &types.AttributeValueMemberS{Value: "TREND#" + fromMonth},
+			":to":    &types.AttributeValueMemberS{Value: "TREND#~"},
+		},
+	}
+
+	var trends []Trend
+	paginator := dynamodb.NewScanPaginator(rm.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		var items []Trend
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, err
+		}
+		trends = append(trends, items...)
+	}
+	return trends, nil
+}
+
+// DeleteTrend removes a roast's trend for a month
+func (rm *RoastModels) DeleteTrend(roastKey, month string) error {
+	return rm.deleteRoastByPKAndSK(roastKey, "TREND#"+month)
+}
@@ -0,0 +1,170 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const EntityPriceRecord = "PriceRecord"
+
+// Venue describes when a roast is served, how to get a table and what it costs
+type Venue struct {
+	// Schedule is when the roast is served each week
+	Schedule []ServingWindow `dynamodbav:"Schedule,omitempty" json:"schedule,omitempty"`
+	// Timezone is the IANA timezone the schedule is in, empty is venues.DefaultTimezone
+	Timezone string `dynamodbav:"Timezone,omitempty" json:"timezone,omitempty"`
+	// Booking is one of the venues.Booking values
+	Booking string `dynamodbav:"Booking,omitempty" json:"booking,omitempty"`
+	// Prices are the current menu prices, a change is also recorded in the roast's price history
+	Prices  []MenuPrice `dynamodbav:"Prices,omitempty" json:"prices,omitempty"`
+	Contact *Contact    `dynamodbav:"Contact,omitempty" json:"contact,omitempty"`
+}
+
+// ServingWindow is a time the roast is served on a day of the week, times are 24 hour HH:MM
+type ServingWindow struct {
+	// Day is a lower case day of the week, or venues.BankHoliday for the times it's served on bank holidays
+	Day    string `dynamodbav:"Day" json:"day"`
+	Opens  string `dynamodbav:"Opens" json:"opens"`
+	Closes string `dynamodbav:"Closes" json:"closes"`
+}
+
+// MenuPrice is the price of one roast on the menu, e.g. beef or nut roast
+type MenuPrice struct {
+	Item string `dynamodbav:"Item" json:"item"`
+	// Amount is in the currency's minor unit, e.g. pence
+	Amount int `dynamodbav:"Amount" json:"amount"`
+	// Currency is an ISO 4217 code, e.g. GBP
+	Currency string `dynamodbav:"Currency" json:"currency"`
+	// EffectiveFrom is the date the price applies from as YYYY-MM-DD
+	EffectiveFrom string `dynamodbav:"EffectiveFrom" json:"effectiveFrom"`
+}
+
+// ServingTime is a serving window on a particular date
+type ServingTime struct {
+	Opens  time.Time `json:"opens"`
+	Closes time.Time `json:"closes"`
+}
+
+type Contact struct {
+	Phone   string `dynamodbav:"Phone,omitempty" json:"phone,omitempty"`
+	Email   string `dynamodbav:"Email,omitempty" json:"email,omitempty"`
+	Website string `dynamodbav:"Website,omitempty" json:"website,omitempty"`
+}
+
+// PriceRecord is a menu price kept in a roast's price history, stored under the roast as
+// PRICE#<effective date>#<item> so the history is in date order
+type PriceRecord struct {
+	RoastKey   string `dynamodbav:"PK" json:"-"`
+	SK         string `dynamodbav:"SK" json:"-"`
+	EntityType string `dynamodbav:"EntityType" json:"-"`
+	RoastID    string `dynamodbav:"RoastID" json:"roastID"`
+	MenuPrice
+	// RecordedAt is when the price was entered in epoch milliseconds
+	RecordedAt int64 `dynamodbav:"RecordedAt" json:"recordedAt"`
+}
+
+// UpdateVenue replaces a roast's venue details
+func (rm *RoastModels) UpdateVenue(roast *Roast) error {
+	av, err := attributevalue.Marshal(roast.Venue)
+	if err != nil {
+		return fmt.Errorf("error marshalling venue: %w", err)
+	}
+
+	_, err = rm.client.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+		TableName: aws.String(rm.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: roast.RoastKey},
+			"SK": &types.AttributeValueMemberS{Value: roast.SK},
+		},
+		UpdateExpression:          aws.String("set Venue = :v"),
+		ConditionExpression:       aws.String("attribute_exists(PK)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":v": av},
+	})
+	if err != nil {
+		return fmt.Errorf("error updating venue: %w", err)
+	}
+	return nil
+}
+
+// PutPriceRecord adds a price to a roast's price history, replacing the record of the same item and date
+func (rm *RoastModels) PutPriceRecord(record PriceRecord) error {
+	av, err := marshalPriceRecord(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = rm.client.PutItem(context.Background(), &dynamodb.PutItemInput{
+		TableName: aws.String(rm.tableName),
+		Item:      av,
+	})
+	return err
+}
+
+// MovePriceRecord moves a price record into another roast's price history. It fails if the target
+// already has a record of the same item and date.
+func (rm *RoastModels) MovePriceRecord(record PriceRecord, targetID string) error {
+	oldKey := itemKey(record.RoastKey, record.SK)
+	record.RoastID = targetID
+	av, err := marshalPriceRecord(record)
+	if err != nil {
+		return err
+	}
+	if err := moveItem(rm.client, rm.tableName, oldKey, av); err != nil {
+		return fmt.Errorf("error moving price record %s: %w", record.SK, err)
+	}
+	return nil
+}
+
+// DeletePriceRecord deletes a record from a roast's price history
+func (rm *RoastModels) DeletePriceRecord(record PriceRecord) error {
+	_, err := rm.client.DeleteItem(context.Background(), &dynamodb.DeleteItemInput{
+		TableName: aws.String(rm.tableName),
+		Key:       itemKey(record.RoastKey, record.SK),
+	})
+	return err
+}
+
+// marshalPriceRecord keys a price record under its roast and marshals it
+func marshalPriceRecord(record PriceRecord) (map[string]types.AttributeValue, error) {
+	record.RoastKey = "ROAST#" + record.RoastID
+	record.SK = "PRICE#" + record.EffectiveFrom + "#" + record.Item
+	record.EntityType = EntityPriceRecord
+	av, err := attributevalue.MarshalMap(record)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling price record: %w", err)
+	}
+	return av, nil
+}
+
+// GetPriceHistory returns a roast's price history, oldest first
+func (rm *RoastModels) GetPriceHistory(roastID string) ([]PriceRecord, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(rm.tableName),
+		KeyConditionExpression: aws.String("PK = :pkval and begins_with(SK, :skval)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pkval": &types.AttributeValueMemberS{Value: "ROAST#" + roastID},
+			":skval": &types.AttributeValueMemberS{Value: "PRICE#"},
+		},
+	}
+
+	records := []PriceRecord{}
+	paginator := dynamodb.NewQueryPaginator(rm.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		var items []PriceRecord
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, err
+		}
+		records = append(records, items...)
+	}
+	return records, nil
+}
@@ -0,0 +1,196 @@
+// Package geo handles roast coordinates, indexing them by geohash so roasts near a point can be found
+// by querying a handful of cells rather than scanning every roast.
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// CellPrecision is the geohash length roasts are partitioned by in the geo index, about 39km by 20km
+	CellPrecision = 4
+	// HashPrecision is the geohash length stored for each roast, accurate to a few metres
+	HashPrecision = 9
+	// MaxQueryPrecision is the finest geohash length searched, used for small radii so fewer roasts are read
+	MaxQueryPrecision = 6
+	// MaxRadiusKm limits proximity searches to a reasonable number of cells
+	MaxRadiusKm = 50
+	// MaxCells is the most cells a proximity search queries, which limits the radius near the poles
+	MaxCells = 48
+	// earthRadiusKm is the mean radius of the earth
+	earthRadiusKm = 6371.0
+)
+
+const base32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+var (
+	// ErrInvalidPoint is returned when coordinates can't be parsed or are out of range
+	ErrInvalidPoint = errors.New("coordinates must be lat,lng in degrees")
+	// ErrTooManyCells is returned for a proximity search covering too large an area to query
+	ErrTooManyCells = fmt.Errorf("search area covers more than %d cells of the geo index, try a smaller radius", MaxCells)
+)
+
+// Point is a latitude and longitude in degrees
+type Point struct {
+	Lat float64
+	Lng float64
+}
+
+// ParsePoint parses coordinates in the form lat,lng
+func ParsePoint(s string) (Point, error) {
+	lat, lng, ok := strings.Cut(s, ",")
+	if !ok {
+		return Point{}, ErrInvalidPoint
+	}
+	var p Point
+	var err error
+	if p.Lat, err = strconv.ParseFloat(strings.TrimSpace(lat), 64); err != nil {
+		return Point{}, ErrInvalidPoint
+	}
+	if p.Lng, err = strconv.ParseFloat(strings.TrimSpace(lng), 64); err != nil {
+		return Point{}, ErrInvalidPoint
+	}
+	if err := p.Validate(); err != nil {
+		return Point{}, err
+	}
+	return p, nil
+}
+
+// Validate checks the point is on the earth
+func (p Point) Validate() error {
+	if math.IsNaN(p.Lat) || math.IsNaN(p.Lng) || p.Lat < -90 || p.Lat > 90 || p.Lng < -180 || p.Lng > 180 {
+		return fmt.Errorf("%w: %v,%v is out of range", ErrInvalidPoint, p.Lat, p.Lng)
+	}
+	return nil
+}
+
+// Distance is the great-circle distance between two points in kilometres
+func Distance(a, b Point) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat, dLng := lat2-lat1, radians(b.Lng-a.Lng)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Encode returns the geohash of a point with precision characters
+func Encode(p Point, precision int) string {
+	latRange, lngRange := [2]float64{-90, 90}, [2]float64{-180, 180}
+	hash := make([]byte, 0, precision)
+	bits, ch := 0, 0
+	// Bits alternate between longitude and latitude, starting with longitude
+	even := true
+	for len(hash) < precision {
+		var value float64
+		var r *[2]float64
+		if even {
+			value, r = p.Lng, &lngRange
+		} else {
+			value, r = p.Lat, &latRange
+		}
+		mid := (r[0] + r[1]) / 2
+		ch <<= 1
+		if value >= mid {
+			ch |= 1
+			r[0] = mid
+		} else {
+			r[1] = mid
+		}
+		even = !even
+
+		if bits++; bits == 5 {
+			hash = append(hash, base32[ch])
+			bits, ch = 0, 0
+		}
+	}
+	return string(hash)
+}
+
+// cellSize returns the height and width in degrees of a geohash cell with precision characters
+func cellSize(precision int) (float64, float64) {
+	lngBits := (5*precision + 1) / 2
+	latBits := 5*precision - lngBits
+	return 180 / math.Pow(2, float64(latBits)), 360 / math.Pow(2, float64(lngBits))
+}
+
+// QueryPrecision returns the geohash length of the cells to search within radiusKm of center, the finest
+// whose cells are at least as wide as the circle so it's covered by a few of them. Cells are never coarser
+// than CellPrecision, which the geo index is partitioned by.
+func QueryPrecision(center Point, radiusKm float64) int {
+	kmPerDegree := radians(earthRadiusKm)
+	for precision := MaxQueryPrecision; precision > CellPrecision; precision-- {
+		height, width := cellSize(precision)
+		widthKm := width * kmPerDegree * math.Cos(radians(center.Lat))
+		if 2*radiusKm <= math.Min(height*kmPerDegree, widthKm) {
+			return precision
+		}
+	}
+	return CellPrecision
+}
+
+// Cover returns the cells to query for every point within radiusKm of center, at the precision chosen
+// by QueryPrecision. It fails with ErrTooManyCells rather than return more than MaxCells.
+func Cover(center Point, radiusKm float64) ([]string, error) {
+	cells := Cells(center, radiusKm, QueryPrecision(center, radiusKm))
+	if len(cells) > MaxCells {
+		return nil, ErrTooManyCells
+	}
+	return cells, nil
+}
+
+// Cells returns the geohash cells with precision characters covering every point within radiusKm of center
+func Cells(center Point, radiusKm float64, precision int) []string {
+	latDelta := degrees(radiusKm / earthRadiusKm)
+	minLat, maxLat := math.Max(-90, center.Lat-latDelta), math.Min(90, center.Lat+latDelta)
+	// Degrees of longitude shrink towards the poles, so the box is widened by the latitude furthest from the equator
+	lngDelta := 180.0
+	if widest := math.Max(math.Abs(minLat), math.Abs(maxLat)); widest < 89 {
+		lngDelta = math.Min(180, latDelta/math.Cos(radians(widest)))
+	}
+	minLng, maxLng := center.Lng-lngDelta, center.Lng+lngDelta
+
+	height, width := cellSize(precision)
+	seen := map[string]bool{}
+	var cells []string
+	// Stepping by the cell size from one edge of the box, then adding the far edge, visits every cell it overlaps
+	for lat := minLat; ; lat += height {
+		lat = math.Min(lat, maxLat)
+		for lng := minLng; ; lng += width {
+			lng = math.Min(lng, maxLng)
+			cell := Encode(Point{Lat: lat, Lng: wrapLng(lng)}, precision)
+			if !seen[cell] {
+				seen[cell] = true
+				cells = append(cells, cell)
+			}
+			if lng >= maxLng {
+				break
+			}
+		}
+		if lat >= maxLat {
+			break
+		}
+	}
+	return cells
+}
+
+// wrapLng brings a longitude past the antimeridian back into range
+func wrapLng(lng float64) float64 {
+	switch {
+	case lng > 180:
+		return lng - 360
+	case lng < -180:
+		return lng + 360
+	}
+	return lng
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func degrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
@@ -0,0 +1,76 @@
+package geo
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	testCases := []struct {
+		point     Point
+		precision int
+		expected  string
+	}{
+		// Reference values from the original geohash.org implementation
+		{Point{Lat: 57.64911, Lng: 10.40744}, 11, "u4pruydqqvj"},
+		{Point{Lat: 51.5074, Lng: -0.1278}, 6, "gcpvj0"},
+		{Point{Lat: -33.8688, Lng: 151.2093}, 5, "r3gx2"},
+	}
+
+	for _, tc := range testCases {
+		if hash := Encode(tc.point, tc.precision); hash != tc.expected {
+			t.Errorf("Encode(%v, %d) = %s; want %s", tc.point, tc.precision, hash, tc.expected)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	london, manchester := Point{Lat: 51.5074, Lng: -0.1278}, Point{Lat: 53.4808, Lng: -2.2426}
+	if d := Distance(london, manchester); math.Abs(d-262) > 2 {
+		t.Errorf("Distance(london, manchester) = %.1f; want about 262", d)
+	}
+	if d := Distance(london, london); d != 0 {
+		t.Errorf("Distance(london, london) = %v; want 0", d)
+	}
+}
+
+func TestCells(t *testing.T) {
+	center := Point{Lat: 51.5074, Lng: -0.1278}
+	cells := Cells(center, 30, CellPrecision)
+
+	// Every point on the circle must fall in one of the cells
+	for bearing := 0.0; bearing < 360; bearing += 15 {
+		lat := center.Lat + degrees(30/earthRadiusKm)*math.Cos(radians(bearing))
+		lng := center.Lng + degrees(30/earthRadiusKm)*math.Sin(radians(bearing))/math.Cos(radians(lat))
+		cell := Encode(Point{Lat: lat, Lng: lng}, CellPrecision)
+		if !strings.Contains(strings.Join(cells, ","), cell) {
+			t.Errorf("Cells() = %v; missing %s at bearing %v", cells, cell, bearing)
+		}
+	}
+	if len(cells) > 12 {
+		t.Errorf("Cells() returned %d cells; want a handful", len(cells))
+	}
+}
+
+func TestParsePoint(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Point
+		err      error
+	}{
+		{"51.5074,-0.1278", Point{Lat: 51.5074, Lng: -0.1278}, nil},
+		{" 51.5 , -0.1 ", Point{Lat: 51.5, Lng: -0.1}, nil},
+		{"51.5", Point{}, ErrInvalidPoint},
+		{"north,south", Point{}, ErrInvalidPoint},
+		{"91,0", Point{}, ErrInvalidPoint},
+	}
+
+	for _, tc := range testCases {
+		point, err := ParsePoint(tc.input)
+		if !errors.Is(err, tc.err) || point != tc.expected {
+			t.Errorf("ParsePoint(%q) = %v, %v; want %v, %v", tc.input, point, err, tc.expected, tc.err)
+		}
+	}
+}
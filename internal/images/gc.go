@@ -0,0 +1,102 @@
+package images
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/pkg/storage"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// References collects every object key an item could refer to. Images are referenced by URL in
+// several formats over time, so every string attribute is taken as both a key and a URL whose path is a key.
+type References struct {
+	pathPrefix string
+	keys       map[string]bool
+}
+
+// NewReferences returns an empty set of references to objects whose URL paths have keys after pathPrefix,
+// e.g. the bucket in path style URLs
+func NewReferences(pathPrefix string) *References {
+	return &References{pathPrefix: pathPrefix, keys: map[string]bool{}}
+}
+
+// Add records the object keys referenced anywhere in an item, including nested maps and lists
+func (r *References) Add(item map[string]types.AttributeValue) {
+	for _, value := range item {
+		r.addValue(value)
+	}
+}
+
+func (r *References) addValue(value types.AttributeValue) {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		r.addString(v.Value)
+	case *types.AttributeValueMemberSS:
+		for _, s := range v.Value {
+			r.addString(s)
+		}
+	case *types.AttributeValueMemberM:
+		r.Add(v.Value)
+	case *types.AttributeValueMemberL:
+		for _, element := range v.Value {
+			r.addValue(element)
+		}
+	}
+}
+
+func (r *References) addString(s string) {
+	if s == "" {
+		return
+	}
+	r.keys[s] = true
+
+	parsed, err := url.Parse(s)
+	if err != nil || parsed.Host == "" {
+		return
+	}
+	key := strings.TrimPrefix(parsed.Path, "/")
+	r.keys[key] = true
+	r.keys[strings.TrimPrefix(key, r.pathPrefix+"/")] = true
+}
+
+// URLKey returns the object key in the path of a URL after pathPrefix, or "" if it isn't a URL
+func URLKey(rawURL, pathPrefix string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(parsed.Path, "/"), pathPrefix+"/")
+}
+
+// Referenced reports whether an object key is referenced
+func (r *References) Referenced(key string) bool {
+	return r.keys[key]
+}
+
+// GCReport describes a garbage collection of unreferenced objects
+type GCReport struct {
+	Scanned    int `json:"scanned"`
+	Referenced int `json:"referenced"`
+	// Recent counts unreferenced objects still within the grace period, e.g. uploads that haven't been confirmed
+	Recent   int              `json:"recent"`
+	Orphaned []storage.Object `json:"orphaned"`
+	Deleted  int              `json:"deleted"`
+	DryRun   bool             `json:"dryRun"`
+}
+
+// Check adds an object to the report, returning true if it's an orphan that should be deleted
+func (g *GCReport) Check(object storage.Object, references *References, now time.Time, grace time.Duration) bool {
+	g.Scanned++
+	switch {
+	case references.Referenced(object.Key):
+		g.Referenced++
+		return false
+	case now.Sub(object.LastModified) < grace:
+		g.Recent++
+		return false
+	}
+	g.Orphaned = append(g.Orphaned, object)
+	return true
+}
@@ -0,0 +1,64 @@
+package images
+
+import (
+	"testing"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/pkg/storage"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestGCReport(t *testing.T) {
+	references := NewReferences("roast-images")
+	references.Add(map[string]types.AttributeValue{
+		"ImageURL": &types.AttributeValueMemberS{Value: "https://roast-images.s3.amazonaws.com/upload/1700000000"},
+		"Photos": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+			&types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+				"Key": &types.AttributeValueMemberS{Value: "users/u1/photos/p1"},
+				"Images": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+					"thumb": &types.AttributeValueMemberS{Value: "http://localhost:9000/roast-images/users/u1/photos/p1/thumb.jpg"},
+				}},
+			}},
+		}},
+	})
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-48 * time.Hour)
+	testCases := []struct {
+		object   storage.Object
+		expected bool
+	}{
+		{storage.Object{Key: "upload/1700000000", LastModified: old}, false},
+		{storage.Object{Key: "users/u1/photos/p1/thumb.jpg", LastModified: old}, false},
+		{storage.Object{Key: "users/u1/photos/p1/card.jpg", LastModified: old}, true},
+		{storage.Object{Key: "users/u1/uploads/pending", LastModified: now.Add(-time.Hour)}, false},
+	}
+
+	var report GCReport
+	for _, tc := range testCases {
+		if orphaned := report.Check(tc.object, references, now, 24*time.Hour); orphaned != tc.expected {
+			t.Errorf("Check(%s) = %v; want %v", tc.object.Key, orphaned, tc.expected)
+		}
+	}
+	if report.Scanned != 4 || report.Referenced != 2 || report.Recent != 1 || len(report.Orphaned) != 1 {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestURLKey(t *testing.T) {
+	testCases := []struct {
+		url      string
+		expected string
+	}{
+		{"https://roast-images.s3.amazonaws.com/users/u1/profile.jpg", "users/u1/profile.jpg"},
+		{"http://localhost:9000/roast-images/users/u1/profile.jpg", "users/u1/profile.jpg"},
+		{"users/u1/profile.jpg", ""},
+		{"", ""},
+	}
+
+	for _, tc := range testCases {
+		if key := URLKey(tc.url, "roast-images"); key != tc.expected {
+			t.Errorf("URLKey(%q) = %q; want %q", tc.url, key, tc.expected)
+		}
+	}
+}
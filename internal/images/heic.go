@@ -0,0 +1,241 @@
+package images
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// errHEICMetadata is returned when a HEIC's metadata can't be found, so it can't be kept without leaking it
+var errHEICMetadata = fmt.Errorf("%w: HEIC metadata couldn't be read", ErrUndecodable)
+
+// heicBox is an ISO BMFF box, data is its content after the header
+type heicBox struct {
+	kind  string
+	start int
+	data  []byte
+}
+
+// heicBoxes splits data into the boxes it contains, start is the offset of data in the file
+func heicBoxes(data []byte, start int) ([]heicBox, error) {
+	var boxes []heicBox
+	for i := 0; i < len(data); {
+		if i+8 > len(data) {
+			return nil, errHEICMetadata
+		}
+		size, header := int(binary.BigEndian.Uint32(data[i:i+4])), 8
+		switch size {
+		case 0:
+			size = len(data) - i
+		case 1:
+			if i+16 > len(data) {
+				return nil, errHEICMetadata
+			}
+			large := binary.BigEndian.Uint64(data[i+8 : i+16])
+			if large > uint64(len(data)-i) {
+				return nil, errHEICMetadata
+			}
+			size, header = int(large), 16
+		}
+		if size < header || i+size > len(data) {
+			return nil, errHEICMetadata
+		}
+		boxes = append(boxes, heicBox{kind: string(data[i+4 : i+8]), start: start + i + header, data: data[i+header : i+size]})
+		i += size
+	}
+	return boxes, nil
+}
+
+// heicReader reads the big endian fields of a box, recording if it runs out of data
+type heicReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *heicReader) uint(size int) uint64 {
+	if r.err != nil || r.pos+size > len(r.data) {
+		r.err = errHEICMetadata
+		return 0
+	}
+	var v uint64
+	for _, b := range r.data[r.pos : r.pos+size] {
+		v = v<<8 | uint64(b)
+	}
+	r.pos += size
+	return v
+}
+
+func (r *heicReader) cstring() string {
+	for i := r.pos; i < len(r.data); i++ {
+		if r.data[i] == 0 {
+			s := string(r.data[r.pos:i])
+			r.pos = i + 1
+			return s
+		}
+	}
+	r.err = errHEICMetadata
+	return ""
+}
+
+// stripHEICMetadata returns a copy of a HEIC image with its Exif and XMP items blanked out, so GPS tags
+// and the like aren't served. The image data is left alone as HEIC can't be decoded without cgo.
+func stripHEICMetadata(data []byte) ([]byte, error) {
+	boxes, err := heicBoxes(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	var meta *heicBox
+	for i := range boxes {
+		if boxes[i].kind == "meta" {
+			meta = &boxes[i]
+		}
+	}
+	// meta is a full box so its children start after the version and flags
+	if meta == nil || len(meta.data) < 4 {
+		return nil, errHEICMetadata
+	}
+	children, err := heicBoxes(meta.data[4:], meta.start+4)
+	if err != nil {
+		return nil, err
+	}
+
+	var iinf, iloc, idat *heicBox
+	for i := range children {
+		switch children[i].kind {
+		case "iinf":
+			iinf = &children[i]
+		case "iloc":
+			iloc = &children[i]
+		case "idat":
+			idat = &children[i]
+		}
+	}
+	if iinf == nil || iloc == nil {
+		return nil, errHEICMetadata
+	}
+
+	metadataItems, err := heicMetadataItems(*iinf)
+	if err != nil {
+		return nil, err
+	}
+	stripped := make([]byte, len(data))
+	copy(stripped, data)
+	if len(metadataItems) == 0 {
+		return stripped, nil
+	}
+	if err := blankHEICItems(stripped, *iloc, idat, metadataItems); err != nil {
+		return nil, err
+	}
+	return stripped, nil
+}
+
+// heicMetadataItems returns the IDs of the Exif and XMP items listed in an iinf box
+func heicMetadataItems(iinf heicBox) (map[uint64]bool, error) {
+	r := &heicReader{data: iinf.data}
+	countSize := 4
+	if r.uint(4)>>24 == 0 {
+		countSize = 2
+	}
+	count := r.uint(countSize)
+	if r.err != nil {
+		return nil, r.err
+	}
+	entries, err := heicBoxes(r.data[r.pos:], 0)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(entries)) < count {
+		return nil, errHEICMetadata
+	}
+
+	items := map[uint64]bool{}
+	for _, entry := range entries {
+		if entry.kind != "infe" {
+			continue
+		}
+		r := &heicReader{data: entry.data}
+		version := r.uint(4) >> 24
+		// Earlier versions don't give an item type, HEIC always uses 2 or 3
+		if version < 2 {
+			return nil, errHEICMetadata
+		}
+		idSize := 2
+		if version == 3 {
+			idSize = 4
+		}
+		id := r.uint(idSize)
+		r.uint(2)
+		itemType := string(r.data[min(r.pos, len(r.data)):min(r.pos+4, len(r.data))])
+		r.uint(4)
+		r.cstring()
+		isMetadata := itemType == "Exif"
+		if itemType == "mime" {
+			isMetadata = r.cstring() == "application/rdf+xml"
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		if isMetadata {
+			items[id] = true
+		}
+	}
+	return items, nil
+}
+
+// blankHEICItems zeroes the data of items in data, found from the iloc box and any idat box
+func blankHEICItems(data []byte, iloc heicBox, idat *heicBox, items map[uint64]bool) error {
+	r := &heicReader{data: iloc.data}
+	version := r.uint(4) >> 24
+	if version > 2 {
+		return errHEICMetadata
+	}
+	sizes := r.uint(1)
+	offsetSize, lengthSize := int(sizes>>4), int(sizes&0xF)
+	sizes = r.uint(1)
+	baseOffsetSize, indexSize := int(sizes>>4), int(sizes&0xF)
+	if version == 0 {
+		indexSize = 0
+	}
+	idSize := 2
+	if version == 2 {
+		idSize = 4
+	}
+
+	count := r.uint(idSize)
+	for n := uint64(0); n < count && r.err == nil; n++ {
+		id := r.uint(idSize)
+		method := uint64(0)
+		if version > 0 {
+			method = r.uint(2) & 0xF
+		}
+		r.uint(2)
+		base := r.uint(baseOffsetSize)
+		extents := r.uint(2)
+		for e := uint64(0); e < extents && r.err == nil; e++ {
+			r.uint(indexSize)
+			offset, length := r.uint(offsetSize), r.uint(lengthSize)
+			if !items[id] {
+				continue
+			}
+
+			var target []byte
+			switch method {
+			case 0:
+				target = data
+			case 1:
+				if idat == nil {
+					return errHEICMetadata
+				}
+				target = data[idat.start : idat.start+len(idat.data)]
+			default:
+				return fmt.Errorf("%w: item %d is constructed from other items", errHEICMetadata, id)
+			}
+			start := base + offset
+			if length == 0 || start > uint64(len(target)) || length > uint64(len(target))-start {
+				return errHEICMetadata
+			}
+			clear(target[start : start+length])
+		}
+	}
+	return r.err
+}
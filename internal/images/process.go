@@ -0,0 +1,226 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Variant names, every processed image is stored in each size
+const (
+	VariantThumb = "thumb"
+	VariantCard  = "card"
+	VariantFull  = "full"
+)
+
+// Variant is a size an image is resized to, fitting its longest side within MaxSize pixels
+type Variant struct {
+	Name    string
+	MaxSize int
+}
+
+// Variants lists every size stored for a processed image, largest first
+var Variants = []Variant{
+	{Name: VariantFull, MaxSize: 1600},
+	{Name: VariantCard, MaxSize: 640},
+	{Name: VariantThumb, MaxSize: 200},
+}
+
+// ProcessedContentType is the content type of every variant of a decoded image
+const ProcessedContentType = ContentTypeJPEG
+
+const jpegQuality = 85
+
+// Limits on the size of an image that is decoded. Compressed images can declare far more pixels than their
+// size suggests, so these are checked before decoding allocates them.
+const (
+	MaxSide   = 12000
+	MaxPixels = 40_000_000
+)
+
+var ErrUndecodable = errors.New("image couldn't be decoded")
+
+// VariantKey is the object key a variant of the image uploaded to key is stored under
+func VariantKey(key, variant string) string {
+	return key + "/" + variant + ".jpg"
+}
+
+// Process decodes an uploaded image and re-encodes it as a JPEG in each variant size. Re-encoding
+// drops any metadata the upload had, such as GPS EXIF tags, so the EXIF orientation is applied first.
+//
+// HEIC images can't be decoded without cgo, so the original with its Exif and XMP metadata blanked out is
+// kept as every variant instead, and it's rejected if they can't be found. VariantContentType gives the
+// content type the variants are stored as.
+func Process(data []byte) (map[string][]byte, error) {
+	if Sniff(data) == ContentTypeHEIC {
+		stripped, err := stripHEICMetadata(data)
+		if err != nil {
+			return nil, err
+		}
+		variants := map[string][]byte{}
+		for _, variant := range Variants {
+			variants[variant.Name] = stripped
+		}
+		return variants, nil
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUndecodable, err)
+	}
+	if config.Width > MaxSide || config.Height > MaxSide || config.Width*config.Height > MaxPixels {
+		return nil, fmt.Errorf("%w, %dx%d is more than %dx%d or %d pixels", ErrTooLarge, config.Width, config.Height, MaxSide, MaxSide, MaxPixels)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUndecodable, err)
+	}
+
+	variants := map[string][]byte{}
+	src := img
+	for i, variant := range Variants {
+		resized := resize(src, variant.MaxSize)
+		if i == 0 {
+			// Orient once at the largest size, the smaller variants are resized from it
+			resized = orient(resized, orientation(data))
+		}
+		src = resized
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, fmt.Errorf("error encoding %s variant: %w", variant.Name, err)
+		}
+		variants[variant.Name] = buf.Bytes()
+	}
+	return variants, nil
+}
+
+// VariantContentType is the content type of the variants Process returns for data
+func VariantContentType(data []byte) string {
+	if Sniff(data) == ContentTypeHEIC {
+		return ContentTypeHEIC
+	}
+	return ProcessedContentType
+}
+
+// resize scales img to fit within maxSize on its longest side onto a white background, so transparent
+// PNGs don't turn black as JPEGs. Images are never scaled up.
+func resize(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if longest := max(width, height); longest > maxSize {
+		width = max(width*maxSize/longest, 1)
+		height = max(height*maxSize/longest, 1)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+	return dst
+}
+
+// orient transforms img so it displays upright given its EXIF orientation from 1 to 8
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		// Orientations 5 to 8 are rotated a quarter turn so swap width and height
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, img.At(bounds.Min.X+sx, bounds.Min.Y+sy))
+		}
+	}
+	return dst
+}
+
+// orientation reads the EXIF orientation tag from a JPEG, returning 1 (upright) if it has none
+func orientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	// Walk the JPEG segments looking for the APP1 Exif segment
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if marker == 0xDA || length < 2 || i+2+length > len(data) {
+			// Image data starts at the start of scan marker, so there's no EXIF
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation reads the orientation tag from IFD0 of a TIFF structure
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd : ifd+2]))
+	for n := 0; n < entries; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8 : entry+10]))
+		}
+	}
+	return 1
+}
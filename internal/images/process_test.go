@@ -0,0 +1,211 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// withOrientation inserts an APP1 Exif segment with an orientation tag and a GPS IFD pointer after the SOI marker
+func withOrientation(jpg []byte, orientation uint16) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, uint16(42))
+	binary.Write(&tiff, binary.BigEndian, uint32(8))
+	binary.Write(&tiff, binary.BigEndian, uint16(2))
+	// Orientation, SHORT, count 1
+	binary.Write(&tiff, binary.BigEndian, []uint16{0x0112, 3})
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	// GPSInfo IFD pointer, LONG, count 1
+	binary.Write(&tiff, binary.BigEndian, []uint16{0x8825, 4})
+	binary.Write(&tiff, binary.BigEndian, []uint32{1, 0})
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var out bytes.Buffer
+	out.Write(jpg[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(len(segment)+2))
+	out.Write(segment)
+	out.Write(jpg[2:])
+	return out.Bytes()
+}
+
+func encodeJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	// A landscape photo taken with the camera rotated, so it should come out portrait
+	upload := withOrientation(encodeJPEG(t, 2000, 1000), 6)
+	if o := orientation(upload); o != 6 {
+		t.Fatalf("orientation() = %d; want 6", o)
+	}
+
+	variants, err := Process(upload)
+	if err != nil {
+		t.Fatalf("Process() returned error: %v", err)
+	}
+
+	expected := map[string]image.Point{
+		VariantFull:  {800, 1600},
+		VariantCard:  {320, 640},
+		VariantThumb: {100, 200},
+	}
+	for name, size := range expected {
+		data, ok := variants[name]
+		if !ok {
+			t.Fatalf("no %s variant", name)
+		}
+		if bytes.Contains(data, []byte("Exif")) {
+			t.Errorf("%s variant still has EXIF data", name)
+		}
+		config, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s variant isn't a JPEG: %v", name, err)
+		}
+		if config.Width != size.X || config.Height != size.Y {
+			t.Errorf("%s variant is %dx%d; want %dx%d", name, config.Width, config.Height, size.X, size.Y)
+		}
+	}
+}
+
+func TestProcessTransparentPNG(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 50, 50))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	variants, err := Process(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Process() returned error: %v", err)
+	}
+	thumb, err := jpeg.Decode(bytes.NewReader(variants[VariantThumb]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thumb.Bounds().Dx() != 50 {
+		t.Errorf("small image was resized to %d wide; want it left at 50", thumb.Bounds().Dx())
+	}
+	if r, g, b, _ := thumb.At(25, 25).RGBA(); r < 0xF000 || g < 0xF000 || b < 0xF000 {
+		t.Errorf("transparent pixel became %v; want white", thumb.At(25, 25))
+	}
+}
+
+func TestProcessTooManyPixels(t *testing.T) {
+	// A tiny PNG that declares more pixels than can be decoded
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[16:20], 50000)
+	binary.BigEndian.PutUint32(data[20:24], 50000)
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+
+	if _, err := Process(data); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Process() of a 50000x50000 PNG = %v; want %v", err, ErrTooLarge)
+	}
+}
+
+// heicTestBox builds an ISO BMFF box
+func heicTestBox(kind string, content ...[]byte) []byte {
+	data := bytes.Join(content, nil)
+	return append(binary.BigEndian.AppendUint32(nil, uint32(8+len(data))), append([]byte(kind), data...)...)
+}
+
+// heicTestImage builds a HEIC with an image item and an Exif item holding gps, both in the mdat box
+func heicTestImage(gps []byte) []byte {
+	pixels := []byte("pixels")
+	infe := func(id uint16, itemType string) []byte {
+		return heicTestBox("infe", []byte{2, 0, 0, 0}, binary.BigEndian.AppendUint16(nil, id), []byte{0, 0}, []byte(itemType), []byte{0})
+	}
+	iinf := heicTestBox("iinf", []byte{0, 0, 0, 0, 0, 2}, infe(1, "hvc1"), infe(2, "Exif"))
+
+	// iloc version 0 with 4 byte offsets and lengths, which are filled in once the mdat's offset is known
+	iloc := func(mdat uint32) []byte {
+		extent := func(id uint16, offset uint32, length int) []byte {
+			entry := binary.BigEndian.AppendUint16(nil, id)
+			entry = append(entry, 0, 0, 0, 1)
+			entry = binary.BigEndian.AppendUint32(entry, offset)
+			return binary.BigEndian.AppendUint32(entry, uint32(length))
+		}
+		return heicTestBox("iloc", []byte{0, 0, 0, 0, 0x44, 0, 0, 2}, extent(1, mdat, len(pixels)), extent(2, mdat+uint32(len(pixels)), len(gps)))
+	}
+
+	ftyp := heicTestBox("ftyp", []byte("heic\x00\x00\x00\x00mif1heic"))
+	meta := func(mdat uint32) []byte { return heicTestBox("meta", []byte{0, 0, 0, 0}, iinf, iloc(mdat)) }
+	mdatStart := uint32(len(ftyp) + len(meta(0)) + 8)
+	return bytes.Join([][]byte{ftyp, meta(mdatStart), heicTestBox("mdat", pixels, gps)}, nil)
+}
+
+func TestProcessHEIC(t *testing.T) {
+	gps := []byte("GPSLatitude 51.4545")
+	upload := heicTestImage(gps)
+	variants, err := Process(upload)
+	if err != nil {
+		t.Fatalf("Process() returned error: %v", err)
+	}
+
+	want := bytes.Replace(upload, gps, make([]byte, len(gps)), 1)
+	for _, variant := range Variants {
+		if !bytes.Equal(variants[variant.Name], want) {
+			t.Errorf("%s variant isn't the HEIC with its Exif blanked out", variant.Name)
+		}
+	}
+	if !bytes.Contains(upload, gps) {
+		t.Error("Process() modified the upload")
+	}
+	if contentType := VariantContentType(upload); contentType != ContentTypeHEIC {
+		t.Errorf("VariantContentType() = %q; want %q", contentType, ContentTypeHEIC)
+	}
+
+	// Without a meta box the Exif can't be found so the image is rejected rather than kept
+	if _, err := Process([]byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic")); !errors.Is(err, ErrUndecodable) {
+		t.Errorf("Process() without metadata boxes error = %v; want %v", err, ErrUndecodable)
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// 2x1 image with a red pixel on the left
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	red := color.RGBA{R: 255, A: 255}
+	src.Set(0, 0, red)
+
+	testCases := []struct {
+		orientation int
+		size        image.Point
+		red         image.Point
+	}{
+		{1, image.Point{2, 1}, image.Point{0, 0}},
+		{2, image.Point{2, 1}, image.Point{1, 0}},
+		{3, image.Point{2, 1}, image.Point{1, 0}},
+		{6, image.Point{1, 2}, image.Point{0, 0}},
+		{8, image.Point{1, 2}, image.Point{0, 1}},
+	}
+
+	for _, tc := range testCases {
+		dst := orient(src, tc.orientation)
+		if size := dst.Bounds().Size(); size != tc.size {
+			t.Errorf("orient(%d) size = %v; want %v", tc.orientation, size, tc.size)
+			continue
+		}
+		if dst.At(tc.red.X, tc.red.Y) != red {
+			t.Errorf("orient(%d) red pixel not at %v", tc.orientation, tc.red)
+		}
+	}
+}
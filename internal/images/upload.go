@@ -0,0 +1,131 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Content types that can be uploaded
+const (
+	ContentTypeJPEG = "image/jpeg"
+	ContentTypePNG  = "image/png"
+	ContentTypeWebP = "image/webp"
+	ContentTypeHEIC = "image/heic"
+)
+
+// ContentTypes lists every content type that can be uploaded
+var ContentTypes = []string{ContentTypeJPEG, ContentTypePNG, ContentTypeWebP, ContentTypeHEIC}
+
+// SniffLength is how many bytes from the start of an object Sniff needs
+const SniffLength = 32
+
+var (
+	ErrUnsupportedType = fmt.Errorf("content type must be one of %v", ContentTypes)
+	ErrInvalidSize     = errors.New("size must be greater than 0")
+	ErrTooLarge        = errors.New("image is too large")
+	ErrContentMismatch = errors.New("uploaded file isn't the declared image type")
+)
+
+// Limits on uploads
+type Limits struct {
+	// MaxBytes is the largest upload allowed
+	MaxBytes int64
+	// DailyUploads is how many uploads each user can request a day
+	DailyUploads int
+}
+
+// Upload is a client's request to upload an image
+type Upload struct {
+	ContentType string `json:"contentType" query:"contentType"`
+	Size        int64  `json:"size" query:"size"`
+}
+
+// Validate checks the upload is a supported type within the size limit
+func (u Upload) Validate(limits Limits) error {
+	if !supported(u.ContentType) {
+		return ErrUnsupportedType
+	}
+	if u.Size <= 0 {
+		return ErrInvalidSize
+	}
+	if u.Size > limits.MaxBytes {
+		return fmt.Errorf("%w, the limit is %d bytes", ErrTooLarge, limits.MaxBytes)
+	}
+	return nil
+}
+
+func supported(contentType string) bool {
+	for _, t := range ContentTypes {
+		if t == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// heicBrands are the ISO base media file brands used by HEIC and HEIF images
+var heicBrands = [][]byte{
+	[]byte("heic"), []byte("heix"), []byte("hevc"), []byte("hevx"),
+	[]byte("heim"), []byte("heis"), []byte("mif1"), []byte("msf1"),
+}
+
+// Sniff returns the content type of an image from its magic bytes, or "" if it isn't a supported image
+func Sniff(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}):
+		return ContentTypeJPEG
+	case bytes.HasPrefix(header, []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}):
+		return ContentTypePNG
+	case len(header) >= 12 && bytes.Equal(header[0:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WEBP")):
+		return ContentTypeWebP
+	case len(header) >= 12 && bytes.Equal(header[4:8], []byte("ftyp")):
+		for _, brand := range heicBrands {
+			if bytes.Equal(header[8:12], brand) {
+				return ContentTypeHEIC
+			}
+		}
+	}
+	return ""
+}
+
+// Verify checks an uploaded object's magic bytes match the content type it was uploaded as
+func Verify(header []byte, contentType string) error {
+	if sniffed := Sniff(header); sniffed == "" || sniffed != contentType {
+		return ErrContentMismatch
+	}
+	return nil
+}
+
+// UploadKeyPrefix is where a user's general image uploads are stored in the image bucket
+func UploadKeyPrefix(userID string) string {
+	return "users/" + userID + "/uploads/"
+}
+
+// NewUploadKey returns a new object key for one of the user's uploads, random so concurrent uploads can't collide
+func NewUploadKey(userID string) string {
+	return UploadKeyPrefix(userID) + uuid.NewString()
+}
+
+// ProfileKeyPrefix is where a user's profile photos are stored in the image bucket
+func ProfileKeyPrefix(userID string) string {
+	return "users/" + userID + "/profile/"
+}
+
+// NewProfileKey returns a new object key for a user's profile photo
+func NewProfileKey(userID string) string {
+	return ProfileKeyPrefix(userID) + uuid.NewString()
+}
+
+// OwnsKey reports whether key is under prefix followed by a generated ID, so it can't reach another user's objects
+func OwnsKey(prefix, key string) bool {
+	id, found := strings.CutPrefix(key, prefix)
+	if !found {
+		return false
+	}
+	_, err := uuid.Parse(id)
+	return err == nil
+}
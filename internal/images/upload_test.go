@@ -0,0 +1,74 @@
+package images
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUploadValidate(t *testing.T) {
+	limits := Limits{MaxBytes: 1000}
+	testCases := []struct {
+		upload   Upload
+		expected error
+	}{
+		{Upload{ContentType: ContentTypeWebP, Size: 1000}, nil},
+		{Upload{ContentType: "image/gif", Size: 10}, ErrUnsupportedType},
+		{Upload{ContentType: ContentTypeJPEG}, ErrInvalidSize},
+		{Upload{ContentType: ContentTypeHEIC, Size: 1001}, ErrTooLarge},
+	}
+
+	for _, tc := range testCases {
+		if err := tc.upload.Validate(limits); !errors.Is(err, tc.expected) {
+			t.Errorf("Validate(%+v) = %v; want %v", tc.upload, err, tc.expected)
+		}
+	}
+}
+
+func TestSniff(t *testing.T) {
+	testCases := []struct {
+		name     string
+		header   []byte
+		expected string
+	}{
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 'J', 'F', 'I', 'F'}, ContentTypeJPEG},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), ContentTypePNG},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), ContentTypeWebP},
+		{"heic", []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00"), ContentTypeHEIC},
+		{"mp4", []byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00"), ""},
+		{"html", []byte("<html><script>"), ""},
+		{"empty", nil, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if sniffed := Sniff(tc.header); sniffed != tc.expected {
+				t.Errorf("Sniff() = %q; want %q", sniffed, tc.expected)
+			}
+		})
+	}
+
+	if err := Verify([]byte{0xFF, 0xD8, 0xFF}, ContentTypePNG); !errors.Is(err, ErrContentMismatch) {
+		t.Errorf("Verify() of a jpeg uploaded as png = %v; want %v", err, ErrContentMismatch)
+	}
+}
+
+func TestOwnsKey(t *testing.T) {
+	profileKey, uploadKey := NewProfileKey("user1"), NewUploadKey("user1")
+	testCases := []struct {
+		prefix   string
+		key      string
+		expected bool
+	}{
+		{ProfileKeyPrefix("user1"), profileKey, true},
+		{ProfileKeyPrefix("user2"), profileKey, false},
+		{ProfileKeyPrefix("user1"), uploadKey, false},
+		{UploadKeyPrefix("user1"), uploadKey, true},
+		{ProfileKeyPrefix("user1"), ProfileKeyPrefix("user1") + "avatar.jpg", false},
+	}
+
+	for _, tc := range testCases {
+		if owned := OwnsKey(tc.prefix, tc.key); owned != tc.expected {
+			t.Errorf("OwnsKey(%q, %q) = %v; want %v", tc.prefix, tc.key, owned, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"strings"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/pkg/dynamo"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// entityTypeMigration backfills EntityType so items can be told apart without relying on key formats,
+// which have drifted between PROFILE#<date>, PROFILE#<userID> and REVIEW#<epoch>
+var entityTypeMigration = Migration{
+	Version:     1,
+	Description: "backfill EntityType on roasts, reviews and users",
+	Apply: func(item Item) (Item, error) {
+		if stringAttr(item, "EntityType") != "" {
+			return nil, nil
+		}
+
+		entityType := entityTypeFromKeys(stringAttr(item, dynamo.PartitionKey), stringAttr(item, dynamo.SortKey))
+		if entityType == "" {
+			return nil, nil
+		}
+
+		item["EntityType"] = &types.AttributeValueMemberS{Value: entityType}
+		return item, nil
+	},
+}
+
+func entityTypeFromKeys(pk, sk string) string {
+	switch {
+	case strings.HasPrefix(pk, "ROAST#") && strings.HasPrefix(sk, "PROFILE"):
+		return database.EntityRoast
+	case strings.HasPrefix(pk, "ROAST#") && strings.HasPrefix(sk, "REVIEW#"):
+		return database.EntityReview
+	case strings.HasPrefix(pk, "USER#") && strings.HasPrefix(sk, "PROFILE"):
+		return database.EntityUser
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestEntityTypeMigration(t *testing.T) {
+	testCases := []struct {
+		name     string
+		pk, sk   string
+		existing string
+		expected string
+	}{
+		{"DatedRoastProfile", "ROAST#RedLion", "PROFILE#19102026", "", "Roast"},
+		{"Review", "ROAST#RedLion", "REVIEW#1729339200000", "", "Review"},
+		{"UserProfile", "USER#abc", "PROFILE#abc", "", "User"},
+		{"AlreadyBackfilled", "ROAST#RedLion", "PROFILE#19102026", "Roast", ""},
+		{"UnknownItem", "META#MIGRATIONS", "STATE", "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			item := Item{
+				"PK": &types.AttributeValueMemberS{Value: tc.pk},
+				"SK": &types.AttributeValueMemberS{Value: tc.sk},
+			}
+			if tc.existing != "" {
+				item["EntityType"] = &types.AttributeValueMemberS{Value: tc.existing}
+			}
+
+			updated, err := entityTypeMigration.Apply(item)
+			if err != nil {
+				t.Fatalf("Apply() unexpected error: %v", err)
+			}
+			if tc.expected == "" {
+				if updated != nil {
+					t.Errorf("Apply() updated item; want unchanged")
+				}
+				return
+			}
+			if got := stringAttr(updated, "EntityType"); got != tc.expected {
+				t.Errorf("Apply() EntityType = %v; want %v", got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"github.com/94DanielBrown/roasts-api/internal/images"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// imageVariantsMigration moves the single image URL of roasts, reviews and users into the Images
+// variants map returned by the API. Images from before processing weren't resized so only have a full variant.
+var imageVariantsMigration = Migration{
+	Version:     2,
+	Description: "move ImageURL and ProfilePhotoUrl into Images",
+	Apply: func(item Item) (Item, error) {
+		if _, ok := item["Images"]; ok {
+			return nil, nil
+		}
+
+		url := stringAttr(item, "ImageURL")
+		if url == "" {
+			url = stringAttr(item, "ProfilePhotoUrl")
+		}
+		if url == "" {
+			return nil, nil
+		}
+
+		item["Images"] = &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+			images.VariantFull: &types.AttributeValueMemberS{Value: url},
+		}}
+		return item, nil
+	},
+}
@@ -0,0 +1,46 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestImageVariantsMigration(t *testing.T) {
+	testCases := []struct {
+		name     string
+		item     Item
+		expected string
+	}{
+		{"RoastImage", Item{"ImageURL": &types.AttributeValueMemberS{Value: "https://img/roast.jpg"}}, "https://img/roast.jpg"},
+		{"ProfilePhoto", Item{"ProfilePhotoUrl": &types.AttributeValueMemberS{Value: "https://img/me.jpg"}}, "https://img/me.jpg"},
+		{"NoImage", Item{"ImageURL": &types.AttributeValueMemberS{Value: ""}}, ""},
+		{"AlreadyMigrated", Item{
+			"ImageURL": &types.AttributeValueMemberS{Value: "https://img/old.jpg"},
+			"Images":   &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{}},
+		}, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			updated, err := imageVariantsMigration.Apply(tc.item)
+			if err != nil {
+				t.Fatalf("Apply() unexpected error: %v", err)
+			}
+			if tc.expected == "" {
+				if updated != nil {
+					t.Errorf("Apply() updated item; want unchanged")
+				}
+				return
+			}
+
+			variants, ok := updated["Images"].(*types.AttributeValueMemberM)
+			if !ok {
+				t.Fatalf("Apply() Images = %T; want a map", updated["Images"])
+			}
+			if got := stringAttr(variants.Value, "full"); got != tc.expected {
+				t.Errorf("Apply() full variant = %v; want %v", got, tc.expected)
+			}
+		})
+	}
+}
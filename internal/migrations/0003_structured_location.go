@@ -0,0 +1,63 @@
+package migrations
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// postcodePattern matches a full or outward-only UK postcode
+var postcodePattern = regexp.MustCompile(`(?i)^[A-Z]{1,2}[0-9][A-Z0-9]?( ?[0-9][A-Z]{2})?$`)
+
+// structuredLocationMigration splits the free-text Location of roasts into an Address. Locations were
+// written as "line, town, postcode" with any part left out, so the postcode is taken from the end if it looks
+// like one and the town is the last part before it. Coordinates can't be derived so have to be added later.
+var structuredLocationMigration = Migration{
+	Version:     3,
+	Description: "split roast Location into a structured Address",
+	Apply: func(item Item) (Item, error) {
+		if stringAttr(item, "EntityType") != database.EntityRoast {
+			return nil, nil
+		}
+		if _, ok := item["Address"]; ok {
+			return nil, nil
+		}
+
+		address := parseLocation(stringAttr(item, "Location"))
+		if address == (database.Address{}) {
+			return nil, nil
+		}
+
+		fields := map[string]types.AttributeValue{}
+		for name, value := range map[string]string{"Line": address.Line, "Town": address.Town, "Postcode": address.Postcode} {
+			if value != "" {
+				fields[name] = &types.AttributeValueMemberS{Value: value}
+			}
+		}
+		item["Address"] = &types.AttributeValueMemberM{Value: fields}
+		return item, nil
+	},
+}
+
+// parseLocation splits a free-text location into the parts of an address
+func parseLocation(location string) database.Address {
+	var parts []string
+	for _, part := range strings.Split(location, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	var address database.Address
+	if n := len(parts); n > 0 && postcodePattern.MatchString(parts[n-1]) {
+		address.Postcode = strings.ToUpper(parts[n-1])
+		parts = parts[:n-1]
+	}
+	if n := len(parts); n > 0 {
+		address.Town = parts[n-1]
+		address.Line = strings.Join(parts[:n-1], ", ")
+	}
+	return address
+}
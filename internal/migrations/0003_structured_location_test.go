@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestParseLocation(t *testing.T) {
+	testCases := []struct {
+		location string
+		expected database.Address
+	}{
+		{"Bristol", database.Address{Town: "Bristol"}},
+		{"The Crown, 12 High Street, Bristol", database.Address{Line: "The Crown, 12 High Street", Town: "Bristol"}},
+		{"The Crown, Bristol, bs1 4dj", database.Address{Line: "The Crown", Town: "Bristol", Postcode: "BS1 4DJ"}},
+		{"Leeds,LS1", database.Address{Town: "Leeds", Postcode: "LS1"}},
+		{" , ", database.Address{}},
+	}
+
+	for _, tc := range testCases {
+		if address := parseLocation(tc.location); address != tc.expected {
+			t.Errorf("parseLocation(%q) = %+v; want %+v", tc.location, address, tc.expected)
+		}
+	}
+}
+
+func TestStructuredLocationMigration(t *testing.T) {
+	roast := func(location string) Item {
+		return Item{
+			"EntityType": &types.AttributeValueMemberS{Value: database.EntityRoast},
+			"Location":   &types.AttributeValueMemberS{Value: location},
+		}
+	}
+	migrated := roast("Bristol")
+	migrated["Address"] = &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{}}
+
+	testCases := []struct {
+		name     string
+		item     Item
+		expected string
+	}{
+		{"Roast", roast("The Crown, Bristol"), "Bristol"},
+		{"NoLocation", roast(""), ""},
+		{"AlreadyMigrated", migrated, ""},
+		{"Review", Item{
+			"EntityType": &types.AttributeValueMemberS{Value: database.EntityReview},
+			"Location":   &types.AttributeValueMemberS{Value: "Bristol"},
+		}, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			updated, err := structuredLocationMigration.Apply(tc.item)
+			if err != nil {
+				t.Fatalf("Apply() unexpected error: %v", err)
+			}
+			if tc.expected == "" {
+				if updated != nil {
+					t.Errorf("Apply() updated item; want unchanged")
+				}
+				return
+			}
+
+			address, ok := updated["Address"].(*types.AttributeValueMemberM)
+			if !ok {
+				t.Fatalf("Apply() Address = %T; want a map", updated["Address"])
+			}
+			if town := stringAttr(address.Value, "Town"); town != tc.expected {
+				t.Errorf("Apply() town = %v; want %v", town, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package migrations
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/ratings"
+	"github.com/94DanielBrown/roasts-api/internal/reviews"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// reviewWeightMigration sets the Weight of reviews written before reviews were weighted, which otherwise
+// count fully while reviews from new accounts count for as little as a fifth. Each review is weighed by
+// its author's credibility when it was written: how old their account was and how many reviews they'd
+// written before it. Their consensus with other reviewers and whether the review was an outlier can't be
+// reconstructed so aren't taken into account. Run `roastctl aggregates recompute` afterwards to rebuild
+// the weighted ratings of roasts from the new weights.
+var reviewWeightMigration = newReviewWeightMigration()
+
+// reviewHistory is what's collected about reviewers to weigh their earlier reviews
+type reviewHistory struct {
+	// createdAt is when each user's account was created, keyed by user ID
+	createdAt map[string]time.Time
+	// written is when each of a user's reviews was written, keyed by user ID then review
+	written map[string]map[string]time.Time
+}
+
+func newReviewWeightMigration() Migration {
+	history := &reviewHistory{createdAt: map[string]time.Time{}, written: map[string]map[string]time.Time{}}
+	return Migration{
+		Version:     4,
+		Description: "weigh reviews written before weighting by their author's credibility at the time",
+		Collect:     history.collect,
+		Apply:       history.apply,
+	}
+}
+
+// collect records when accounts were created and reviews written. Items are keyed so resuming collects
+// the same history again rather than adding to it.
+func (h *reviewHistory) collect(item Item) error {
+	switch stringAttr(item, "EntityType") {
+	case database.EntityUser:
+		var user database.User
+		if err := attributevalue.UnmarshalMap(item, &user); err != nil {
+			return err
+		}
+		if user.CreatedAt > 0 {
+			h.createdAt[strings.TrimPrefix(user.UserKey, "USER#")] = time.UnixMilli(user.CreatedAt)
+		}
+	case database.EntityReview:
+		var review database.Review
+		if err := attributevalue.UnmarshalMap(item, &review); err != nil {
+			return err
+		}
+		if h.written[review.UserID] == nil {
+			h.written[review.UserID] = map[string]time.Time{}
+		}
+		h.written[review.UserID][review.RoastKey+"/"+review.ReviewKey] = reviews.AddedAt(review)
+	}
+	return nil
+}
+
+func (h *reviewHistory) apply(item Item) (Item, error) {
+	if stringAttr(item, "EntityType") != database.EntityReview {
+		return nil, nil
+	}
+	if _, ok := item["Weight"]; ok {
+		return nil, nil
+	}
+
+	var review database.Review
+	if err := attributevalue.UnmarshalMap(item, &review); err != nil {
+		return nil, err
+	}
+	weight := h.reviewer(review).Credibility()
+	item["Weight"] = &types.AttributeValueMemberN{Value: strconv.FormatFloat(weight, 'f', -1, 64)}
+	return item, nil
+}
+
+// reviewer is the review's author as they were when it was written. Reviews without a time count as
+// written before all of the author's others, and accounts without a creation time as established.
+func (h *reviewHistory) reviewer(review database.Review) ratings.Reviewer {
+	key, addedAt := review.RoastKey+"/"+review.ReviewKey, reviews.AddedAt(review)
+
+	var reviewer ratings.Reviewer
+	for other, writtenAt := range h.written[review.UserID] {
+		if other != key && earlier(writtenAt, other, addedAt, key) {
+			reviewer.ReviewCount++
+		}
+	}
+	if createdAt, ok := h.createdAt[review.UserID]; ok && !addedAt.IsZero() && addedAt.After(createdAt) {
+		reviewer.AccountAge = addedAt.Sub(createdAt)
+	}
+	return reviewer
+}
+
+// earlier orders reviews by when they were written, then by key for reviews written at the same time
+func earlier(a time.Time, aKey string, b time.Time, bKey string) bool {
+	if !a.Equal(b) {
+		return a.Before(b)
+	}
+	return aKey < bKey
+}
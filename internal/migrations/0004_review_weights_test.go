@@ -0,0 +1,90 @@
+package migrations
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestReviewWeightMigration(t *testing.T) {
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	user := func(id string, createdAt time.Time) Item {
+		item := Item{
+			"PK":         &types.AttributeValueMemberS{Value: "USER#" + id},
+			"SK":         &types.AttributeValueMemberS{Value: "PROFILE"},
+			"EntityType": &types.AttributeValueMemberS{Value: database.EntityUser},
+		}
+		if !createdAt.IsZero() {
+			item["CreatedAt"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(createdAt.UnixMilli(), 10)}
+		}
+		return item
+	}
+	review := func(userID, roastID string, addedAt time.Time) Item {
+		return Item{
+			"PK":         &types.AttributeValueMemberS{Value: "ROAST#" + roastID},
+			"SK":         &types.AttributeValueMemberS{Value: "REVIEW#" + strconv.FormatInt(addedAt.UnixMilli(), 10)},
+			"EntityType": &types.AttributeValueMemberS{Value: database.EntityReview},
+			"UserID":     &types.AttributeValueMemberS{Value: userID},
+		}
+	}
+
+	weighed := review("veteran", "Crown", created)
+	weighed["Weight"] = &types.AttributeValueMemberN{Value: "0.5"}
+	items := map[string]Item{
+		"FirstReviewOfNewAccount":   review("newcomer", "RedLion", created.Add(24*time.Hour)),
+		"SecondReviewOfNewAccount":  review("newcomer", "Crown", created.Add(48*time.Hour)),
+		"ReviewOfAccountWithoutAge": review("veteran", "RedLion", created),
+		"AlreadyWeighed":            weighed,
+	}
+
+	history := newReviewWeightMigration()
+	for _, item := range []Item{user("newcomer", created), user("veteran", time.Time{})} {
+		if err := history.Collect(item); err != nil {
+			t.Fatalf("Collect() unexpected error: %v", err)
+		}
+	}
+	for _, item := range items {
+		if err := history.Collect(item); err != nil {
+			t.Fatalf("Collect() unexpected error: %v", err)
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		expected float64
+	}{
+		// A day old with no reviews, then two days old with one
+		{"FirstReviewOfNewAccount", 0.2 + 0.8*(1.0/30)/2},
+		{"SecondReviewOfNewAccount", 0.2 + 0.8*(2.0/30+1.0/5)/2},
+		// An unknown account age counts as established and the veteran's other review was written at the same time
+		{"ReviewOfAccountWithoutAge", 0.2 + 0.8*(1+1.0/5)/2},
+		{"AlreadyWeighed", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			updated, err := history.Apply(items[tc.name])
+			if err != nil {
+				t.Fatalf("Apply() unexpected error: %v", err)
+			}
+			if tc.expected == 0 {
+				if updated != nil {
+					t.Errorf("Apply() updated item; want unchanged")
+				}
+				return
+			}
+
+			weight, ok := updated["Weight"].(*types.AttributeValueMemberN)
+			if !ok {
+				t.Fatalf("Apply() Weight = %T; want a number", updated["Weight"])
+			}
+			if value, _ := strconv.ParseFloat(weight.Value, 64); math.Abs(value-tc.expected) > 1e-9 {
+				t.Errorf("Apply() weight = %v; want %v", value, tc.expected)
+			}
+		})
+	}
+}
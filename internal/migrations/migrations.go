@@ -0,0 +1,330 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/pkg/dynamo"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Keys of the metadata item recording which migrations have been applied
+const (
+	metadataPK = "META#MIGRATIONS"
+	metadataSK = "STATE"
+)
+
+// Item is a raw item from the table
+type Item = map[string]types.AttributeValue
+
+// Migration is a versioned backfill applied to every item in the table.
+// Apply returns the item to write back, or nil to leave it unchanged. If the returned item's
+// PK or SK differ from the original it's written as a new item and the original is deleted.
+// Apply must be idempotent as an interrupted migration resumes from the last saved page.
+type Migration struct {
+	Version     int
+	Description string
+	// Collect, if set, is given every item in the table before any are applied, for migrations that
+	// depend on other items. It's called again for every item when an interrupted migration resumes.
+	Collect func(item Item) error
+	Apply   func(item Item) (Item, error)
+}
+
+// Result describes a migration run
+type Result struct {
+	Version     int    `json:"version"`
+	Description string `json:"description"`
+	Scanned     int    `json:"scanned"`
+	Changed     int    `json:"changed"`
+	Resumed     bool   `json:"resumed"`
+}
+
+// Status describes whether a migration has been applied
+type Status struct {
+	Version     int    `json:"version"`
+	Description string `json:"description"`
+	Applied     bool   `json:"applied"`
+	InProgress  bool   `json:"inProgress"`
+}
+
+type metadata struct {
+	PK      string `dynamodbav:"PK"`
+	SK      string `dynamodbav:"SK"`
+	Applied []int  `dynamodbav:"Applied"`
+	// Checkpoints holds the last evaluated key of an interrupted migration keyed by version
+	Checkpoints map[string]map[string]string `dynamodbav:"Checkpoints"`
+	UpdatedAt   int64                        `dynamodbav:"UpdatedAt"`
+}
+
+// Runner applies migrations in version order, recording progress in a metadata item
+type Runner struct {
+	client     *dynamodb.Client
+	tableName  string
+	migrations []Migration
+	logger     *slog.Logger
+	// DryRun scans and reports what would change without writing anything
+	DryRun bool
+}
+
+// NewRunner returns a Runner for the registered migrations
+func NewRunner(client *dynamodb.Client, tableName string, logger *slog.Logger) (*Runner, error) {
+	migrations := make([]Migration, len(registered))
+	copy(migrations, registered)
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", migrations[i].Version)
+		}
+	}
+
+	return &Runner{client: client, tableName: tableName, migrations: migrations, logger: logger}, nil
+}
+
+// Status returns every registered migration and whether it has been applied
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	meta, err := r.loadMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, m := range r.migrations {
+		_, inProgress := meta.Checkpoints[strconv.Itoa(m.Version)]
+		statuses = append(statuses, Status{
+			Version:     m.Version,
+			Description: m.Description,
+			Applied:     meta.applied(m.Version),
+			InProgress:  inProgress,
+		})
+	}
+	return statuses, nil
+}
+
+// Run applies every pending migration, resuming an interrupted one from its checkpoint
+func (r *Runner) Run(ctx context.Context) ([]Result, error) {
+	meta, err := r.loadMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	for _, m := range r.migrations {
+		if meta.applied(m.Version) {
+			continue
+		}
+
+		result, err := r.apply(ctx, meta, m)
+		results = append(results, result)
+		if err != nil {
+			return results, fmt.Errorf("migration %d failed: %w", m.Version, err)
+		}
+	}
+	return results, nil
+}
+
+func (r *Runner) apply(ctx context.Context, meta *metadata, m Migration) (Result, error) {
+	version := strconv.Itoa(m.Version)
+	result := Result{Version: m.Version, Description: m.Description}
+
+	if m.Collect != nil {
+		if err := r.collect(ctx, m); err != nil {
+			return result, err
+		}
+	}
+
+	input := &dynamodb.ScanInput{TableName: aws.String(r.tableName)}
+	if checkpoint, ok := meta.Checkpoints[version]; ok && !r.DryRun {
+		input.ExclusiveStartKey = decodeKey(checkpoint)
+		result.Resumed = true
+		r.logger.Info("resuming migration from checkpoint", "version", m.Version, "checkpoint", checkpoint)
+	}
+
+	for {
+		out, err := r.client.Scan(ctx, input)
+		if err != nil {
+			return result, fmt.Errorf("error scanning table: %w", err)
+		}
+
+		for _, item := range out.Items {
+			if isMetadata(item) {
+				continue
+			}
+			result.Scanned++
+
+			updated, err := m.Apply(item)
+			if err != nil {
+				return result, fmt.Errorf("error applying migration to %s/%s: %w", stringAttr(item, dynamo.PartitionKey), stringAttr(item, dynamo.SortKey), err)
+			}
+			if updated == nil {
+				continue
+			}
+			result.Changed++
+
+			if r.DryRun {
+				r.logger.Debug("would update item", "version", m.Version, "PK", stringAttr(item, dynamo.PartitionKey), "SK", stringAttr(item, dynamo.SortKey))
+				continue
+			}
+			if err := r.write(ctx, item, updated); err != nil {
+				return result, err
+			}
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+
+		if !r.DryRun {
+			meta.Checkpoints[version] = encodeKey(out.LastEvaluatedKey)
+			if err := r.saveMetadata(ctx, meta); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	if r.DryRun {
+		return result, nil
+	}
+
+	delete(meta.Checkpoints, version)
+	meta.Applied = append(meta.Applied, m.Version)
+	if err := r.saveMetadata(ctx, meta); err != nil {
+		return result, err
+	}
+	r.logger.Info("migration applied", "version", m.Version, "scanned", result.Scanned, "changed", result.Changed)
+	return result, nil
+}
+
+// collect passes every item in the table to the migration's Collect
+func (r *Runner) collect(ctx context.Context, m Migration) error {
+	paginator := dynamodb.NewScanPaginator(r.client, &dynamodb.ScanInput{TableName: aws.String(r.tableName)})
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("error scanning table: %w", err)
+		}
+		for _, item := range out.Items {
+			if isMetadata(item) {
+				continue
+			}
+			if err := m.Collect(item); err != nil {
+				return fmt.Errorf("error collecting %s/%s: %w", stringAttr(item, dynamo.PartitionKey), stringAttr(item, dynamo.SortKey), err)
+			}
+		}
+	}
+	return nil
+}
+
+// write puts the updated item, deleting the original if its keys have changed
+func (r *Runner) write(ctx context.Context, original, updated Item) error {
+	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      updated,
+	})
+	if err != nil {
+		return fmt.Errorf("error writing item: %w", err)
+	}
+
+	pk, sk := stringAttr(original, dynamo.PartitionKey), stringAttr(original, dynamo.SortKey)
+	if pk == stringAttr(updated, dynamo.PartitionKey) && sk == stringAttr(updated, dynamo.SortKey) {
+		return nil
+	}
+
+	_, err = r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			dynamo.PartitionKey: &types.AttributeValueMemberS{Value: pk},
+			dynamo.SortKey:      &types.AttributeValueMemberS{Value: sk},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting rekeyed item %s/%s: %w", pk, sk, err)
+	}
+	return nil
+}
+
+func (r *Runner) loadMetadata(ctx context.Context) (*metadata, error) {
+	out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			dynamo.PartitionKey: &types.AttributeValueMemberS{Value: metadataPK},
+			dynamo.SortKey:      &types.AttributeValueMemberS{Value: metadataSK},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error loading migration metadata: %w", err)
+	}
+
+	meta := &metadata{PK: metadataPK, SK: metadataSK}
+	if out.Item != nil {
+		if err := attributevalue.UnmarshalMap(out.Item, meta); err != nil {
+			return nil, fmt.Errorf("error unmarshalling migration metadata: %w", err)
+		}
+	}
+	if meta.Checkpoints == nil {
+		meta.Checkpoints = map[string]map[string]string{}
+	}
+	return meta, nil
+}
+
+func (r *Runner) saveMetadata(ctx context.Context, meta *metadata) error {
+	meta.UpdatedAt = time.Now().Unix()
+	av, err := attributevalue.MarshalMap(meta)
+	if err != nil {
+		return fmt.Errorf("error marshalling migration metadata: %w", err)
+	}
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      av,
+	})
+	if err != nil {
+		return fmt.Errorf("error saving migration metadata: %w", err)
+	}
+	return nil
+}
+
+func (m *metadata) applied(version int) bool {
+	for _, v := range m.Applied {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+func isMetadata(item Item) bool {
+	return stringAttr(item, dynamo.PartitionKey) == metadataPK
+}
+
+func stringAttr(item Item, name string) string {
+	if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+		return v.Value
+	}
+	return ""
+}
+
+// encodeKey stores a scan's last evaluated key, table keys are always strings in the single-table design
+func encodeKey(key Item) map[string]string {
+	encoded := map[string]string{}
+	for name := range key {
+		encoded[name] = stringAttr(key, name)
+	}
+	return encoded
+}
+
+func decodeKey(encoded map[string]string) Item {
+	key := Item{}
+	for name, value := range encoded {
+		key[name] = &types.AttributeValueMemberS{Value: value}
+	}
+	return key
+}
@@ -0,0 +1,9 @@
+package migrations
+
+// registered lists every migration, new migrations are appended with the next version number
+var registered = []Migration{
+	entityTypeMigration,
+	imageVariantsMigration,
+	structuredLocationMigration,
+	reviewWeightMigration,
+}
@@ -0,0 +1,261 @@
+// Package places resolves UK postcodes and town names to coordinates in memory, from a bundled CSV of
+// postcode districts and towns or one given in config, so roasts can be located without a geocoding service.
+package places
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/geo"
+	"github.com/94DanielBrown/roasts-api/internal/utils"
+)
+
+//go:embed places.csv
+var bundled string
+
+// Kinds of place in the dataset
+const (
+	KindTown     = "town"
+	KindPostcode = "postcode"
+	// KindArea is a postcode area such as BS, located at the mean of its districts when a district isn't known
+	KindArea = "area"
+)
+
+// ErrUnknownPlace is returned when a query doesn't match any postcode or town
+var ErrUnknownPlace = errors.New("unknown postcode or town")
+
+var (
+	// outwardPattern matches the outward code of a postcode, e.g. BS1 or EC1A
+	outwardPattern = regexp.MustCompile(`^([A-Z]{1,2})[0-9][A-Z0-9]?$`)
+	// inwardPattern matches the inward code that ends a full postcode, e.g. 4DJ
+	inwardPattern   = regexp.MustCompile(`[0-9][A-Z]{2}$`)
+	nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Place is a town or postcode district and its centroid
+type Place struct {
+	Kind string `json:"kind"`
+	// Postcode is the outward code of a postcode district or the letters of an area, empty for towns
+	Postcode string  `json:"postcode,omitempty"`
+	Town     string  `json:"town,omitempty"`
+	Lat      float64 `json:"lat"`
+	Lng      float64 `json:"lng"`
+}
+
+// Point is the place's centroid
+func (p Place) Point() geo.Point {
+	return geo.Point{Lat: p.Lat, Lng: p.Lng}
+}
+
+// Gazetteer answers postcode and town lookups
+type Gazetteer struct {
+	postcodes map[string]Place
+	areas     map[string]Place
+	// towns are in the order of the dataset, which breaks ties between equally close fuzzy matches
+	towns []town
+}
+
+// town is a place with the normalised names it can be looked up by
+type town struct {
+	place Place
+	names []string
+}
+
+// Load returns the gazetteer in path, or the bundled one if path is empty
+func Load(path string) (*Gazetteer, error) {
+	if path == "" {
+		return Parse(strings.NewReader(bundled))
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening places file: %w", err)
+	}
+	defer file.Close()
+	return Parse(file)
+}
+
+// Parse reads a CSV with the columns kind, code, name, lat, lng and aliases. Lines starting with # are ignored.
+func Parse(r io.Reader) (*Gazetteer, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 6
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading places header: %w", err)
+	}
+	if strings.Join(header, ",") != "kind,code,name,lat,lng,aliases" {
+		return nil, fmt.Errorf("unexpected places header %v", header)
+	}
+
+	g := &Gazetteer{postcodes: map[string]Place{}, areas: map[string]Place{}}
+	areaCounts := map[string]int{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading places: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		place := Place{Kind: record[0], Town: strings.TrimSpace(record[2])}
+		if place.Lat, err = strconv.ParseFloat(record[3], 64); err != nil {
+			return nil, fmt.Errorf("line %d: invalid lat %q", line, record[3])
+		}
+		if place.Lng, err = strconv.ParseFloat(record[4], 64); err != nil {
+			return nil, fmt.Errorf("line %d: invalid lng %q", line, record[4])
+		}
+		if err := place.Point().Validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		switch place.Kind {
+		case KindTown:
+			t := town{place: place, names: []string{normalise(place.Town)}}
+			for _, alias := range strings.Split(record[5], "|") {
+				if alias = normalise(alias); alias != "" {
+					t.names = append(t.names, alias)
+				}
+			}
+			g.towns = append(g.towns, t)
+		case KindPostcode:
+			place.Postcode = strings.ToUpper(strings.ReplaceAll(record[1], " ", ""))
+			match := outwardPattern.FindStringSubmatch(place.Postcode)
+			if match == nil {
+				return nil, fmt.Errorf("line %d: invalid postcode district %q", line, record[1])
+			}
+			g.postcodes[place.Postcode] = place
+
+			// Areas accumulate the sum of their districts' coordinates until they're averaged below
+			area := g.areas[match[1]]
+			area.Lat += place.Lat
+			area.Lng += place.Lng
+			g.areas[match[1]] = area
+			areaCounts[match[1]]++
+		default:
+			return nil, fmt.Errorf("line %d: unknown kind %q", line, place.Kind)
+		}
+	}
+
+	for code, area := range g.areas {
+		n := float64(areaCounts[code])
+		g.areas[code] = Place{Kind: KindArea, Postcode: code, Lat: area.Lat / n, Lng: area.Lng / n}
+	}
+	return g, nil
+}
+
+// Resolve looks up a query as a postcode if it looks like one and otherwise as a town
+func (g *Gazetteer) Resolve(query string) (Place, error) {
+	if place, ok := g.Postcode(query); ok {
+		return place, nil
+	}
+	if place, ok := g.Town(query); ok {
+		return place, nil
+	}
+	return Place{}, fmt.Errorf("%w: %q", ErrUnknownPlace, query)
+}
+
+// Postcode looks up a full postcode or outward code by its district, falling back to its area
+func (g *Gazetteer) Postcode(postcode string) (Place, bool) {
+	outward := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(postcode), " ", ""))
+	if len(outward) >= 5 && inwardPattern.MatchString(outward) {
+		outward = outward[:len(outward)-3]
+	}
+	match := outwardPattern.FindStringSubmatch(outward)
+	if match == nil {
+		return Place{}, false
+	}
+
+	if place, ok := g.postcodes[outward]; ok {
+		return place, true
+	}
+	// Central London districts are split into sub-districts such as EC1A
+	if last := outward[len(outward)-1]; last >= 'A' && last <= 'Z' {
+		if place, ok := g.postcodes[outward[:len(outward)-1]]; ok {
+			return place, true
+		}
+	}
+	area, ok := g.areas[match[1]]
+	return area, ok
+}
+
+// Town looks up a town by name or alias, allowing for a few typos in longer names
+func (g *Gazetteer) Town(name string) (Place, bool) {
+	query := normalise(name)
+	if query == "" {
+		return Place{}, false
+	}
+
+	best, bestDistance := -1, maxTypos(query)+1
+	for i, t := range g.towns {
+		for _, candidate := range t.names {
+			if distance := utils.EditDistance(query, candidate, bestDistance); distance < bestDistance {
+				best, bestDistance = i, distance
+			}
+		}
+		if bestDistance == 0 {
+			break
+		}
+	}
+	if best < 0 {
+		return Place{}, false
+	}
+	return g.towns[best].place, true
+}
+
+// Locate fills in the coordinates of an address from its postcode, or its town if the postcode isn't known,
+// and its town from its postcode if it doesn't have one. It reports whether the address has coordinates.
+func (g *Gazetteer) Locate(address *database.Address) bool {
+	if address == nil {
+		return false
+	}
+
+	place, ok := g.Postcode(address.Postcode)
+	if ok && place.Kind == KindArea && address.Town != "" {
+		// A known town is more precise than the mean of a whole postcode area
+		if town, found := g.Town(address.Town); found {
+			place = town
+		}
+	} else if !ok {
+		place, ok = g.Town(address.Town)
+	}
+	if !ok {
+		return address.Located()
+	}
+
+	if address.Town == "" {
+		address.Town = place.Town
+	}
+	if !address.Located() {
+		address.Lat, address.Lng = place.Lat, place.Lng
+	}
+	return true
+}
+
+// normalise lower cases a name and reduces punctuation to single spaces, so "Stoke-on-Trent" matches "stoke on trent"
+func normalise(name string) string {
+	name = strings.ReplaceAll(strings.ToLower(name), "'", "")
+	return strings.TrimSpace(nonAlphanumeric.ReplaceAllString(name, " "))
+}
+
+// maxTypos is how many edits a town name can be from a query and still match it
+func maxTypos(query string) int {
+	switch n := len(query); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
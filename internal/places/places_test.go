@@ -0,0 +1,102 @@
+package places
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+)
+
+func TestBundled(t *testing.T) {
+	g, err := Load("")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	testCases := []struct {
+		query    string
+		kind     string
+		expected string
+	}{
+		{"BS1 4DJ", KindPostcode, "BS1"},
+		{"bs14dj", KindPostcode, "BS1"},
+		{"EC1A 1BB", KindPostcode, "EC1"},
+		{"BS16", KindArea, "BS"},
+		{"Bristol", KindTown, "Bristol"},
+		{"bristl", KindTown, "Bristol"},
+		{"Newcastle", KindTown, "Newcastle upon Tyne"},
+		{"stoke on trent", KindTown, "Stoke-on-Trent"},
+		{"Mancester", KindTown, "Manchester"},
+		{"St. Albans", KindTown, "St Albans"},
+	}
+
+	for _, tc := range testCases {
+		place, err := g.Resolve(tc.query)
+		if err != nil {
+			t.Errorf("Resolve(%q) error = %v", tc.query, err)
+			continue
+		}
+		got := place.Town
+		if tc.kind != KindTown {
+			got = place.Postcode
+		}
+		if place.Kind != tc.kind || got != tc.expected {
+			t.Errorf("Resolve(%q) = %s %s; want %s %s", tc.query, place.Kind, got, tc.kind, tc.expected)
+		}
+	}
+
+	for _, query := range []string{"Atlantis", "Bat", "ZZ1", ""} {
+		if _, err := g.Resolve(query); !errors.Is(err, ErrUnknownPlace) {
+			t.Errorf("Resolve(%q) error = %v; want %v", query, err, ErrUnknownPlace)
+		}
+	}
+}
+
+func TestLocate(t *testing.T) {
+	g, err := Parse(strings.NewReader(`kind,code,name,lat,lng,aliases
+town,,Bristol,51.45,-2.59,
+town,,Bath,51.38,-2.36,
+postcode,BS1,Bristol,51.453,-2.593,
+postcode,BA1,Bath,51.385,-2.365,
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		address  database.Address
+		expected database.Address
+		located  bool
+	}{
+		{"Postcode", database.Address{Postcode: "BS1 4DJ"}, database.Address{Postcode: "BS1 4DJ", Town: "Bristol", Lat: 51.453, Lng: -2.593}, true},
+		{"Town", database.Address{Town: "Bath"}, database.Address{Town: "Bath", Lat: 51.38, Lng: -2.36}, true},
+		{"AreaThenTown", database.Address{Postcode: "BS9", Town: "Bath"}, database.Address{Postcode: "BS9", Town: "Bath", Lat: 51.38, Lng: -2.36}, true},
+		{"AlreadyLocated", database.Address{Town: "Bath", Lat: 1, Lng: 1}, database.Address{Town: "Bath", Lat: 1, Lng: 1}, true},
+		{"Unknown", database.Address{Town: "Atlantis"}, database.Address{Town: "Atlantis"}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			address := tc.address
+			if located := g.Locate(&address); located != tc.located || address != tc.expected {
+				t.Errorf("Locate() = %v, %+v; want %v, %+v", located, address, tc.located, tc.expected)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	testCases := []string{
+		"kind,name\n",
+		"kind,code,name,lat,lng,aliases\ntown,,Bristol,north,-2.59,\n",
+		"kind,code,name,lat,lng,aliases\npostcode,BRISTOL,Bristol,51.45,-2.59,\n",
+		"kind,code,name,lat,lng,aliases\ncity,,Bristol,51.45,-2.59,\n",
+	}
+	for _, input := range testCases {
+		if _, err := Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("Parse(%q) succeeded; want an error", input)
+		}
+	}
+}
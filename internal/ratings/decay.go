@@ -0,0 +1,125 @@
+package ratings
+
+import (
+	"math"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/reviews"
+)
+
+// minDecayedWeight is the weight below which decayed ratings are treated as having no reviews left
+const minDecayedWeight = 1e-9
+
+// decayFactor is how much a rating's weight has decayed after elapsed, halving every half life
+func decayFactor(elapsed, halfLife time.Duration) float64 {
+	if halfLife <= 0 || elapsed <= 0 {
+		return 1
+	}
+	return math.Pow(0.5, float64(elapsed)/float64(halfLife))
+}
+
+// decayTo decays the running sums and weight forward to t, so new reviews can be added without rescanning
+func decayTo(decay *database.DecayedRatings, t time.Time, halfLife time.Duration) {
+	if decay.UpdatedAt == 0 {
+		decay.UpdatedAt = t.Unix()
+		return
+	}
+	updatedAt := time.Unix(decay.UpdatedAt, 0)
+	if !t.After(updatedAt) {
+		return
+	}
+
+	factor := decayFactor(t.Sub(updatedAt), halfLife)
+	for criterion := range decay.Sums {
+		decay.Sums[criterion] *= factor
+	}
+	decay.Weight *= factor
+	decay.UpdatedAt = t.Unix()
+}
+
+// addDecayed adds a review to the roast's time-decayed ratings, or removes it when sign is -1.
+// The review is weighted by how long ago it was added relative to the latest review. Reviews dated in the
+// future count as added now, otherwise they'd decay every other review away.
+func addDecayed(roast *database.Roast, review database.Review, sign float64, halfLife time.Duration) {
+	now := time.Now()
+	addedAt := reviews.AddedAt(review)
+	if addedAt.IsZero() || addedAt.After(now) {
+		addedAt = now
+	}
+
+	decay := &roast.Decay
+	if decay.Sums == nil {
+		decay.Sums = map[string]float64{}
+	}
+	reference := addedAt
+	if decay.UpdatedAt > addedAt.Unix() {
+		reference = time.Unix(decay.UpdatedAt, 0)
+	}
+	decayTo(decay, reference, halfLife)
+
+	weight := sign * decayFactor(reference.Sub(addedAt), halfLife)
+	for criterion, score := range Scores(review) {
+		decay.Sums[criterion] += weight * float64(score)
+	}
+	decay.Weight += weight
+
+	if decay.Weight < minDecayedWeight {
+		roast.Decay = database.DecayedRatings{}
+	}
+}
+
+// SetCurrentRatings sets the roast's recency-weighted ratings as of now. As reviews age their weight decays
+// and the rating is pulled back towards the prior, so a roast nobody has reviewed in years fades.
+func SetCurrentRatings(roast *database.Roast, settings Settings, now time.Time) {
+	roast.CurrentRating, roast.CurrentRatings = 0, nil
+	decay := roast.Decay
+	if decay.Weight < minDecayedWeight || decay.UpdatedAt == 0 {
+		return
+	}
+
+	factor := decayFactor(now.Sub(time.Unix(decay.UpdatedAt, 0)), settings.HalfLife)
+	weight := decay.Weight * factor
+	roast.CurrentRatings = map[string]float64{}
+	for criterion, sum := range decay.Sums {
+		roast.CurrentRatings[criterion] = (settings.Prior.Weight*settings.Prior.Mean + sum*factor) / (settings.Prior.Weight + weight)
+	}
+	roast.CurrentRating = roast.CurrentRatings[CriterionOverall]
+}
+
+// rebuildDecayed rebuilds a roast's time-decayed ratings from all of its reviews, decayed to the
+// time the stored ratings were last updated if that's later than the newest review
+func rebuildDecayed(roast *database.Roast, roastReviews []database.Review, halfLife time.Duration) {
+	storedAt := roast.Decay.UpdatedAt
+	roast.Decay = database.DecayedRatings{}
+	for _, review := range roastReviews {
+		addDecayed(roast, review, 1, halfLife)
+	}
+	if roast.Decay.Weight > 0 && storedAt > roast.Decay.UpdatedAt {
+		decayTo(&roast.Decay, time.Unix(storedAt, 0), halfLife)
+	}
+}
+
+// compareDecayed reports drift in the time-decayed ratings. The sums only line up when both are decayed
+// to the same time, which rebuildDecayed ensures unless the stored ratings have missed a newer review.
+func compareDecayed(stored, rebuilt database.DecayedRatings) []Discrepancy {
+	if stored.UpdatedAt != rebuilt.UpdatedAt {
+		return []Discrepancy{{Field: "Decay.UpdatedAt", Stored: float64(stored.UpdatedAt), Actual: float64(rebuilt.UpdatedAt)}}
+	}
+
+	discrepancies := []Discrepancy{}
+	if math.Abs(stored.Weight-rebuilt.Weight) > driftTolerance {
+		discrepancies = append(discrepancies, Discrepancy{Field: "Decay.Weight", Stored: stored.Weight, Actual: rebuilt.Weight})
+	}
+	for _, criterion := range Criteria {
+		if math.Abs(stored.Sums[criterion]-rebuilt.Sums[criterion]) > driftTolerance {
+			discrepancies = append(discrepancies, Discrepancy{Field: "Decay.Sums." + criterion, Stored: stored.Sums[criterion], Actual: rebuilt.Sums[criterion]})
+		}
+	}
+	return discrepancies
+}
+
+// HalfLifeDays converts a half life in days from config into a duration
+func HalfLifeDays(days float64) time.Duration {
+	return time.Duration(days * float64(24*time.Hour))
+}
@@ -0,0 +1,82 @@
+package ratings
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+)
+
+func TestCurrentRatings(t *testing.T) {
+	halfLife := 30 * 24 * time.Hour
+	settings := Settings{Prior: Prior{Mean: 5, Weight: 1}, HalfLife: halfLife}
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	old := database.Review{OverallRating: 2, DateAdded: int(now.Add(-2 * halfLife).UnixMilli())}
+	recent := database.Review{OverallRating: 10, DateAdded: int(now.UnixMilli())}
+
+	var roast database.Roast
+	addDecayed(&roast, old, 1, halfLife)
+	addDecayed(&roast, recent, 1, halfLife)
+
+	// The old review has decayed to a quarter weight: (5*1 + 2*0.25 + 10*1) / (1 + 0.25 + 1)
+	SetCurrentRatings(&roast, settings, now)
+	if want := 15.5 / 2.25; math.Abs(roast.CurrentRating-want) > 1e-9 {
+		t.Errorf("CurrentRating = %v; want %v", roast.CurrentRating, want)
+	}
+
+	// With no new reviews the rating fades back towards the prior
+	SetCurrentRatings(&roast, settings, now.Add(10*halfLife))
+	if math.Abs(roast.CurrentRating-settings.Prior.Mean) > 0.1 {
+		t.Errorf("CurrentRating after ten half lives = %v; want close to %v", roast.CurrentRating, settings.Prior.Mean)
+	}
+
+	addDecayed(&roast, old, -1, halfLife)
+	addDecayed(&roast, recent, -1, halfLife)
+	SetCurrentRatings(&roast, settings, now)
+	if roast.CurrentRating != 0 || roast.Decay.Weight != 0 {
+		t.Errorf("after removing every review CurrentRating = %v, Decay = %+v; want zero", roast.CurrentRating, roast.Decay)
+	}
+}
+
+func TestRebuildDecayed(t *testing.T) {
+	halfLife := 30 * 24 * time.Hour
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	roastReviews := []database.Review{
+		{OverallRating: 8, MeatRating: 7, DateAdded: int(now.Add(-halfLife).UnixMilli())},
+		{OverallRating: 6, MeatRating: 9, DateAdded: int(now.UnixMilli())},
+	}
+
+	var stored database.Roast
+	for _, review := range roastReviews {
+		addDecayed(&stored, review, 1, halfLife)
+	}
+
+	rebuilt := stored
+	rebuildDecayed(&rebuilt, []database.Review{roastReviews[1], roastReviews[0]}, halfLife)
+	if discrepancies := compareDecayed(stored.Decay, rebuilt.Decay); len(discrepancies) != 0 {
+		t.Errorf("compareDecayed() = %+v; want none", discrepancies)
+	}
+
+	rebuildDecayed(&rebuilt, roastReviews[:1], halfLife)
+	if discrepancies := compareDecayed(stored.Decay, rebuilt.Decay); len(discrepancies) == 0 {
+		t.Error("compareDecayed() found no drift after a review was dropped")
+	}
+}
+
+func TestAddDecayedFutureReview(t *testing.T) {
+	halfLife := 30 * 24 * time.Hour
+	now := time.Now()
+	var roast database.Roast
+	addDecayed(&roast, database.Review{OverallRating: 8, DateAdded: int(now.UnixMilli())}, 1, halfLife)
+	addDecayed(&roast, database.Review{OverallRating: 4, DateAdded: int(now.Add(100 * halfLife).UnixMilli())}, 1, halfLife)
+
+	// The future review counts as added now so the existing review keeps its weight
+	if roast.Decay.Weight < 1.99 {
+		t.Errorf("Decay.Weight = %v; want about 2", roast.Decay.Weight)
+	}
+	if updatedAt := time.Unix(roast.Decay.UpdatedAt, 0); updatedAt.After(time.Now()) {
+		t.Errorf("Decay.UpdatedAt = %v; want no later than now", updatedAt)
+	}
+}
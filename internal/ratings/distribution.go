@@ -0,0 +1,131 @@
+package ratings
+
+import (
+	"math"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+)
+
+// Criteria rated on every review, used as the keys of a roast's rating distribution
+const (
+	CriterionOverall  = "overall"
+	CriterionMeat     = "meat"
+	CriterionPotatoes = "potatoes"
+	CriterionVeg      = "veg"
+	CriterionGravy    = "gravy"
+)
+
+// Criteria lists every criterion in the order they're shown
+var Criteria = []string{CriterionOverall, CriterionMeat, CriterionPotatoes, CriterionVeg, CriterionGravy}
+
+// MaxScore is the highest score a criterion can be given, scores start at 1
+const MaxScore = 10
+
+// Rating returns a roast's average for a criterion
+func Rating(roast database.Roast, criterion string) float64 {
+	switch criterion {
+	case CriterionOverall:
+		return roast.OverallRating
+	case CriterionMeat:
+		return roast.MeatRating
+	case CriterionPotatoes:
+		return roast.PotatoesRating
+	case CriterionVeg:
+		return roast.VegRating
+	case CriterionGravy:
+		return roast.GravyRating
+	}
+	return 0
+}
+
+// DistributionStats summarises how a criterion's scores are spread
+type DistributionStats struct {
+	Median float64 `json:"median"`
+	StdDev float64 `json:"stdDev"`
+}
+
+// Scores returns a review's score for each criterion
+func Scores(review database.Review) map[string]int {
+	return map[string]int{
+		CriterionOverall:  review.OverallRating,
+		CriterionMeat:     review.MeatRating,
+		CriterionPotatoes: review.PotatoesRating,
+		CriterionVeg:      review.VegRating,
+		CriterionGravy:    review.GravyRating,
+	}
+}
+
+// addToDistribution adds delta to the count of each of the review's scores, delta is -1 when a review is removed
+func addToDistribution(roast *database.Roast, review database.Review, delta int) {
+	if roast.RatingDistribution == nil {
+		roast.RatingDistribution = map[string][]int{}
+	}
+
+	for criterion, score := range Scores(review) {
+		if score < 1 || score > MaxScore {
+			continue
+		}
+		counts := roast.RatingDistribution[criterion]
+		if len(counts) != MaxScore {
+			counts = make([]int, MaxScore)
+		}
+		counts[score-1] = max(counts[score-1]+delta, 0)
+		roast.RatingDistribution[criterion] = counts
+	}
+}
+
+// Stats returns the median and standard deviation of each criterion's distribution
+func Stats(distribution map[string][]int) map[string]DistributionStats {
+	stats := map[string]DistributionStats{}
+	for criterion, counts := range distribution {
+		if s, ok := countStats(counts); ok {
+			stats[criterion] = s
+		}
+	}
+	return stats
+}
+
+// countStats calculates stats from the number of times each score was given, ok is false if there are no scores
+func countStats(counts []int) (DistributionStats, bool) {
+	total := 0
+	var sum float64
+	for i, count := range counts {
+		total += count
+		sum += float64((i + 1) * count)
+	}
+	if total == 0 {
+		return DistributionStats{}, false
+	}
+
+	mean := sum / float64(total)
+	var variance float64
+	for i, count := range counts {
+		diff := float64(i+1) - mean
+		variance += diff * diff * float64(count)
+	}
+	variance /= float64(total)
+
+	return DistributionStats{
+		Median: median(counts, total),
+		StdDev: math.Sqrt(variance),
+	}, true
+}
+
+// median finds the middle score, averaging the two middle scores when there's an even number
+func median(counts []int, total int) float64 {
+	scoreAt := func(position int) int {
+		seen := 0
+		for i, count := range counts {
+			seen += count
+			if seen > position {
+				return i + 1
+			}
+		}
+		return len(counts)
+	}
+
+	if total%2 == 1 {
+		return float64(scoreAt(total / 2))
+	}
+	return float64(scoreAt(total/2-1)+scoreAt(total/2)) / 2
+}
@@ -0,0 +1,62 @@
+package ratings
+
+import (
+	"math"
+	"testing"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+)
+
+func TestAddToDistribution(t *testing.T) {
+	var roast database.Roast
+	review := database.Review{OverallRating: 8, MeatRating: 10, PotatoesRating: 1, VegRating: 5, GravyRating: 0}
+
+	addToDistribution(&roast, review, 1)
+	addToDistribution(&roast, review, 1)
+	if got := roast.RatingDistribution[CriterionOverall][7]; got != 2 {
+		t.Errorf("overall count for 8 = %v; want 2", got)
+	}
+	if got := roast.RatingDistribution[CriterionMeat][9]; got != 2 {
+		t.Errorf("meat count for 10 = %v; want 2", got)
+	}
+	if _, ok := roast.RatingDistribution[CriterionGravy]; ok {
+		t.Errorf("out of range gravy score was counted")
+	}
+
+	addToDistribution(&roast, review, -1)
+	addToDistribution(&roast, review, -1)
+	addToDistribution(&roast, review, -1)
+	if got := roast.RatingDistribution[CriterionOverall][7]; got != 0 {
+		t.Errorf("overall count for 8 after removing = %v; want 0", got)
+	}
+}
+
+func TestCountStats(t *testing.T) {
+	testCases := []struct {
+		name       string
+		counts     []int
+		wantMedian float64
+		wantStdDev float64
+		wantOK     bool
+	}{
+		{"Empty", make([]int, MaxScore), 0, 0, false},
+		{"SingleScore", []int{0, 0, 0, 0, 0, 0, 3, 0, 0, 0}, 7, 0, true},
+		{"EvenSplit", []int{1, 0, 0, 0, 0, 0, 0, 0, 0, 1}, 5.5, 4.5, true},
+		{"OddCount", []int{1, 1, 1, 0, 0, 0, 0, 0, 0, 0}, 2, math.Sqrt(2.0 / 3.0), true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stats, ok := countStats(tc.counts)
+			if ok != tc.wantOK {
+				t.Fatalf("countStats() ok = %v; want %v", ok, tc.wantOK)
+			}
+			if stats.Median != tc.wantMedian {
+				t.Errorf("Median = %v; want %v", stats.Median, tc.wantMedian)
+			}
+			if math.Abs(stats.StdDev-tc.wantStdDev) > 1e-9 {
+				t.Errorf("StdDev = %v; want %v", stats.StdDev, tc.wantStdDev)
+			}
+		})
+	}
+}
@@ -7,8 +7,7 @@ import (
 	"github.com/94DanielBrown/roasts-api/internal/database"
 )
 
-// TODO - This doens't update the combined ratings for criteria filter i.e MeatGravyRating etc
-func UpdateAverages(roastModels database.RoastModels, review database.Review, ratingOperation string) error {
+func UpdateAverages(roastModels database.RoastModels, review database.Review, ratingOperation string, settings Settings) error {
 	roast, err := roastModels.GetRoastByPrefix(review.RoastKey)
 	if err != nil {
 		fmt.Println("error")
@@ -18,31 +17,112 @@ func UpdateAverages(roastModels database.RoastModels, review database.Review, ra
 		return errors.New("no roast found")
 	}
 
-	var newCount int
+	delta := 1
 	if ratingOperation == "plusCount" {
-		newCount = roast.ReviewCount + 1
-		roast.OverallRating = ((roast.OverallRating * float64(roast.ReviewCount)) + float64(review.OverallRating)) / float64(newCount)
-		roast.MeatRating = ((roast.MeatRating * float64(roast.ReviewCount)) + float64(review.MeatRating)) / float64(newCount)
-		roast.PotatoesRating = ((roast.PotatoesRating * float64(roast.ReviewCount)) + float64(review.PotatoesRating)) / float64(newCount)
-		roast.VegRating = ((roast.VegRating * float64(roast.ReviewCount)) + float64(review.VegRating)) / float64(newCount)
-		roast.GravyRating = ((roast.GravyRating * float64(roast.ReviewCount)) + float64(review.GravyRating)) / float64(newCount)
+		addReview(roast, review)
+		addDecayed(roast, review, 1, settings.HalfLife)
+		updateWeighted(roast, review, 1, settings)
 	} else if ratingOperation == "minusCount" {
-		newCount = roast.ReviewCount - 1
-		roast.OverallRating = ((roast.OverallRating * float64(roast.ReviewCount)) - float64(review.OverallRating)) / float64(newCount)
-		roast.MeatRating = ((roast.MeatRating * float64(roast.ReviewCount)) - float64(review.MeatRating)) / float64(newCount)
-		roast.PotatoesRating = ((roast.PotatoesRating * float64(roast.ReviewCount)) - float64(review.PotatoesRating)) / float64(newCount)
-		roast.VegRating = ((roast.VegRating * float64(roast.ReviewCount)) - float64(review.VegRating)) / float64(newCount)
-		roast.GravyRating = ((roast.GravyRating * float64(roast.ReviewCount)) - float64(review.GravyRating)) / float64(newCount)
+		removeReview(roast, review)
+		addDecayed(roast, review, -1, settings.HalfLife)
+		updateWeighted(roast, review, -1, settings)
+		delta = -1
 	} else {
 		return fmt.Errorf("invalid rating operation")
 	}
 
-	roast.ReviewCount = newCount
+	if err := saveAggregates(roastModels, roast); err != nil {
+		return err
+	}
+	return updateTrend(roastModels, review, delta)
+}
 
-	err = roastModels.UpdateRoast(roast)
+// ReplaceReview updates the aggregates when a review is edited, removing the old review's ratings and adding the new ones
+func ReplaceReview(roastModels database.RoastModels, oldReview, newReview database.Review, settings Settings) error {
+	roast, err := roastModels.GetRoastByPrefix(newReview.RoastKey)
+	if err != nil {
+		return err
+	}
+	if roast == nil {
+		return errors.New("no roast found")
+	}
+
+	removeReview(roast, oldReview)
+	addReview(roast, newReview)
+	addDecayed(roast, oldReview, -1, settings.HalfLife)
+	addDecayed(roast, newReview, 1, settings.HalfLife)
+	updateWeighted(roast, oldReview, -1, settings)
+	updateWeighted(roast, newReview, 1, settings)
+	if err := saveAggregates(roastModels, roast); err != nil {
+		return err
+	}
+
+	if err := updateTrend(roastModels, oldReview, -1); err != nil {
+		return err
+	}
+	return updateTrend(roastModels, newReview, 1)
+}
+
+func saveAggregates(roastModels database.RoastModels, roast *database.Roast) error {
+	setCombinedRatings(roast)
+
+	err := roastModels.UpdateRoast(roast)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+func addReview(roast *database.Roast, review database.Review) {
+	newCount := roast.ReviewCount + 1
+	roast.OverallRating = ((roast.OverallRating * float64(roast.ReviewCount)) + float64(review.OverallRating)) / float64(newCount)
+	roast.MeatRating = ((roast.MeatRating * float64(roast.ReviewCount)) + float64(review.MeatRating)) / float64(newCount)
+	roast.PotatoesRating = ((roast.PotatoesRating * float64(roast.ReviewCount)) + float64(review.PotatoesRating)) / float64(newCount)
+	roast.VegRating = ((roast.VegRating * float64(roast.ReviewCount)) + float64(review.VegRating)) / float64(newCount)
+	roast.GravyRating = ((roast.GravyRating * float64(roast.ReviewCount)) + float64(review.GravyRating)) / float64(newCount)
+	roast.ReviewCount = newCount
+	roast.PhotoCount += len(review.Photos)
+	addToDistribution(roast, review, 1)
+	addTagCounts(roast, review, 1)
+}
+
+func removeReview(roast *database.Roast, review database.Review) {
+	newCount := roast.ReviewCount - 1
+	if newCount <= 0 {
+		// Removing the last review would otherwise divide by zero, leave the roast with no ratings instead
+		newCount = 0
+		roast.OverallRating, roast.MeatRating, roast.PotatoesRating, roast.VegRating, roast.GravyRating = 0, 0, 0, 0, 0
+	} else {
+		roast.OverallRating = ((roast.OverallRating * float64(roast.ReviewCount)) - float64(review.OverallRating)) / float64(newCount)
+		roast.MeatRating = ((roast.MeatRating * float64(roast.ReviewCount)) - float64(review.MeatRating)) / float64(newCount)
+		roast.PotatoesRating = ((roast.PotatoesRating * float64(roast.ReviewCount)) - float64(review.PotatoesRating)) / float64(newCount)
+		roast.VegRating = ((roast.VegRating * float64(roast.ReviewCount)) - float64(review.VegRating)) / float64(newCount)
+		roast.GravyRating = ((roast.GravyRating * float64(roast.ReviewCount)) - float64(review.GravyRating)) / float64(newCount)
+	}
+	roast.ReviewCount = newCount
+	roast.PhotoCount = max(roast.PhotoCount-len(review.Photos), 0)
+	addToDistribution(roast, review, -1)
+	addTagCounts(roast, review, -1)
+}
+
+// setCombinedRatings sets the ratings used by the criteria filter, e.g MeatGravyRating, as the mean of their criteria
+func setCombinedRatings(roast *database.Roast) {
+	mean := func(ratings ...float64) float64 {
+		var sum float64
+		for _, rating := range ratings {
+			sum += rating
+		}
+		return sum / float64(len(ratings))
+	}
+
+	roast.MeatPotatoesRating = mean(roast.MeatRating, roast.PotatoesRating)
+	roast.MeatVegRating = mean(roast.MeatRating, roast.VegRating)
+	roast.MeatGravyRating = mean(roast.MeatRating, roast.GravyRating)
+	roast.PotatoesVegRating = mean(roast.PotatoesRating, roast.VegRating)
+	roast.PotatoesGravyRating = mean(roast.PotatoesRating, roast.GravyRating)
+	roast.VegGravyRating = mean(roast.VegRating, roast.GravyRating)
+	roast.MeatPotatoesVegRating = mean(roast.MeatRating, roast.PotatoesRating, roast.VegRating)
+	roast.MeatPotatoesGravyRating = mean(roast.MeatRating, roast.PotatoesRating, roast.GravyRating)
+	roast.MeatVegGravyRating = mean(roast.MeatRating, roast.VegRating, roast.GravyRating)
+}
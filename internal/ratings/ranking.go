@@ -0,0 +1,79 @@
+package ratings
+
+import (
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+)
+
+// DefaultPriorMean is the middle of the 1 to 10 rating scale, the global mean before there are any reviews
+const DefaultPriorMean = 5.5
+
+// Prior is the belief a roast's rating is pulled towards until it has enough reviews to outweigh it
+type Prior struct {
+	// Mean rating of the prior, when zero the global mean across all reviews is used
+	Mean float64
+	// Weight is the number of reviews the prior counts as
+	Weight float64
+}
+
+// Settings configures how roast aggregates are calculated
+type Settings struct {
+	Prior Prior
+	// HalfLife is how long it takes a review's weight in the current rating to halve
+	HalfLife time.Duration
+	// Weighting keeps weighted ratings alongside the raw ones, counting each review by its reviewer's credibility
+	Weighting bool
+}
+
+// Score returns the Bayesian average of a roast's mean rating, so a single 10/10 review doesn't
+// outrank fifty 9/10 reviews. Roasts with no reviews aren't ranked and score 0.
+func (p Prior) Score(mean float64, count int) float64 {
+	if count <= 0 {
+		return 0
+	}
+	return (p.Weight*p.Mean + mean*float64(count)) / (p.Weight + float64(count))
+}
+
+// WithGlobalMean returns the prior with its mean set to the mean of every review across roasts if it isn't configured
+func (p Prior) WithGlobalMean(roasts []database.Roast) Prior {
+	if p.Mean != 0 {
+		return p
+	}
+	p.Mean = GlobalMean(roasts)
+	return p
+}
+
+// GlobalMean is the mean overall rating of every review, calculated from each roast's average, or
+// DefaultPriorMean if there aren't any reviews
+func GlobalMean(roasts []database.Roast) float64 {
+	var sum float64
+	var count int
+	for _, roast := range roasts {
+		sum += roast.OverallRating * float64(roast.ReviewCount)
+		count += roast.ReviewCount
+	}
+	if count == 0 {
+		return DefaultPriorMean
+	}
+	return sum / float64(count)
+}
+
+// ResolveSettings fills in the prior's mean from the stored roasts if it isn't configured
+func ResolveSettings(settings Settings, roastModels database.RoastModels) (Settings, error) {
+	if settings.Prior.Mean != 0 {
+		return settings, nil
+	}
+	roasts, err := roastModels.GetAllRoasts()
+	if err != nil {
+		return settings, err
+	}
+	settings.Prior = settings.Prior.WithGlobalMean(roasts)
+	return settings, nil
+}
+
+// SetRankingScore scores the roast's overall rating against the prior. It's set when roasts are read rather
+// than stored, so every roast is scored against the same prior mean.
+func SetRankingScore(roast *database.Roast, settings Settings) {
+	roast.RankingScore = settings.Prior.Score(roast.OverallRating, roast.ReviewCount)
+}
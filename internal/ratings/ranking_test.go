@@ -0,0 +1,41 @@
+package ratings
+
+import (
+	"testing"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+)
+
+func TestPriorScore(t *testing.T) {
+	prior := Prior{Mean: 7, Weight: 5}
+
+	oneTen := prior.Score(10, 1)
+	fiftyNines := prior.Score(9, 50)
+	if oneTen >= fiftyNines {
+		t.Errorf("one 10/10 review scored %v, fifty 9/10 reviews scored %v; want fifty 9s ranked higher", oneTen, fiftyNines)
+	}
+
+	if score := prior.Score(0, 0); score != 0 {
+		t.Errorf("Score() with no reviews = %v; want 0", score)
+	}
+}
+
+func TestGlobalMean(t *testing.T) {
+	roasts := []database.Roast{
+		{OverallRating: 9, ReviewCount: 3},
+		{OverallRating: 5, ReviewCount: 1},
+		{},
+	}
+	if mean := GlobalMean(roasts); mean != 8 {
+		t.Errorf("GlobalMean() = %v; want 8", mean)
+	}
+
+	if mean := GlobalMean([]database.Roast{{}}); mean != DefaultPriorMean {
+		t.Errorf("GlobalMean() without reviews = %v; want %v", mean, DefaultPriorMean)
+	}
+
+	prior := Prior{Mean: 6, Weight: 5}.WithGlobalMean(roasts)
+	if prior.Mean != 6 {
+		t.Errorf("WithGlobalMean() replaced a configured mean with %v", prior.Mean)
+	}
+}
@@ -0,0 +1,233 @@
+package ratings
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/utils"
+)
+
+// driftTolerance is how far a stored aggregate can be from the rebuilt value before it's reported
+const driftTolerance = 1e-6
+
+// Discrepancy is a stored aggregate that doesn't match the value rebuilt from the roast's reviews
+type Discrepancy struct {
+	Field  string  `json:"field"`
+	Stored float64 `json:"stored"`
+	Actual float64 `json:"actual"`
+	// Note explains values that can't be represented in JSON, e.g. a NaN left by removing the last review
+	Note string `json:"note,omitempty"`
+}
+
+// RecomputeResult describes the aggregates rebuilt for a roast
+type RecomputeResult struct {
+	RoastID       string        `json:"roastID"`
+	ReviewCount   int           `json:"reviewCount"`
+	Discrepancies []Discrepancy `json:"discrepancies"`
+	// Fixed is true if the rebuilt aggregates were saved
+	Fixed bool `json:"fixed"`
+}
+
+// Recompute rebuilds a roast's aggregates from its reviews and reports any that have drifted.
+// The rebuilt aggregates are only saved if fix is true and something has drifted.
+func Recompute(roastModels database.RoastModels, reviewModels database.ReviewModels, roastID string, settings Settings, fix bool) (RecomputeResult, error) {
+	roastKey := "ROAST#" + roastID
+	roast, err := roastModels.GetRoastByPrefix(roastKey)
+	if err != nil {
+		return RecomputeResult{}, err
+	}
+	if roast == nil {
+		return RecomputeResult{}, errors.New("no roast found")
+	}
+
+	rebuilt, err := rebuildRoast(reviewModels, roast, settings)
+	if err != nil {
+		return RecomputeResult{}, err
+	}
+	return saveRebuilt(roastModels, roast, rebuilt, fix)
+}
+
+// RecomputeAll rebuilds the aggregates of every roast
+func RecomputeAll(roastModels database.RoastModels, reviewModels database.ReviewModels, settings Settings, fix bool) ([]RecomputeResult, error) {
+	roasts, err := roastModels.GetAllRoasts()
+	if err != nil {
+		return nil, err
+	}
+
+	results := []RecomputeResult{}
+	for i := range roasts {
+		rebuilt, err := rebuildRoast(reviewModels, &roasts[i], settings)
+		if err != nil {
+			return results, err
+		}
+		result, err := saveRebuilt(roastModels, &roasts[i], rebuilt, fix)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// rebuiltRoast is a roast's aggregates and monthly trends rebuilt from its reviews
+type rebuiltRoast struct {
+	roast  database.Roast
+	trends map[string]database.Trend
+}
+
+// rebuildRoast rebuilds a copy of roast's aggregates and its trends from its reviews
+func rebuildRoast(reviewModels database.ReviewModels, roast *database.Roast, settings Settings) (*rebuiltRoast, error) {
+	roastReviews, err := reviewModels.GetReviewsByRoast(roast.RoastKey)
+	if err != nil {
+		return nil, err
+	}
+
+	rebuilt := &rebuiltRoast{roast: *roast, trends: buildTrends(roast, roastReviews)}
+	rebuildAggregates(&rebuilt.roast, roastReviews, settings)
+	return rebuilt, nil
+}
+
+// saveRebuilt compares the stored and rebuilt aggregates and trends, saving the rebuilt ones if fix is true and they differ
+func saveRebuilt(roastModels database.RoastModels, stored *database.Roast, rebuilt *rebuiltRoast, fix bool) (RecomputeResult, error) {
+	storedTrends, err := roastModels.GetTrends(stored.RoastKey, "")
+	if err != nil {
+		return RecomputeResult{}, err
+	}
+
+	aggregateDiscrepancies := compareAggregates(stored, &rebuilt.roast)
+	trendDiscrepancies, changedMonths, staleMonths := compareTrends(storedTrends, rebuilt.trends)
+	result := RecomputeResult{
+		RoastID:       stored.RoastID,
+		ReviewCount:   rebuilt.roast.ReviewCount,
+		Discrepancies: append(aggregateDiscrepancies, trendDiscrepancies...),
+	}
+	if !fix || len(result.Discrepancies) == 0 {
+		return result, nil
+	}
+
+	if len(aggregateDiscrepancies) > 0 {
+		if err := roastModels.UpdateRoast(&rebuilt.roast); err != nil {
+			return result, err
+		}
+	}
+	for _, month := range changedMonths {
+		if err := roastModels.PutTrend(rebuilt.trends[month]); err != nil {
+			return result, err
+		}
+	}
+	for _, month := range staleMonths {
+		if err := roastModels.DeleteTrend(stored.RoastKey, month); err != nil {
+			return result, err
+		}
+	}
+	result.Fixed = true
+	return result, nil
+}
+
+// rebuildAggregates sets the review count and averages of roast from all of its reviews
+func rebuildAggregates(roast *database.Roast, roastReviews []database.Review, settings Settings) {
+	var overall, meat, potatoes, veg, gravy []float64
+	roast.RatingDistribution = map[string][]int{}
+	roast.PhotoCount, roast.TagCounts = 0, nil
+	for _, review := range roastReviews {
+		addToDistribution(roast, review, 1)
+		addTagCounts(roast, review, 1)
+		roast.PhotoCount += len(review.Photos)
+		overall = append(overall, float64(review.OverallRating))
+		meat = append(meat, float64(review.MeatRating))
+		potatoes = append(potatoes, float64(review.PotatoesRating))
+		veg = append(veg, float64(review.VegRating))
+		gravy = append(gravy, float64(review.GravyRating))
+	}
+
+	roast.ReviewCount = len(roastReviews)
+	roast.OverallRating = utils.CalculateAverageRating(overall)
+	roast.MeatRating = utils.CalculateAverageRating(meat)
+	roast.PotatoesRating = utils.CalculateAverageRating(potatoes)
+	roast.VegRating = utils.CalculateAverageRating(veg)
+	roast.GravyRating = utils.CalculateAverageRating(gravy)
+	setCombinedRatings(roast)
+	rebuildDecayed(roast, roastReviews, settings.HalfLife)
+	rebuildWeighted(roast, roastReviews, settings)
+}
+
+// aggregateFields lists every stored aggregate by name so they can be compared
+func aggregateFields(roast *database.Roast) []struct {
+	name  string
+	value float64
+} {
+	return []struct {
+		name  string
+		value float64
+	}{
+		{"ReviewCount", float64(roast.ReviewCount)},
+		{"OverallRating", roast.OverallRating},
+		{"MeatRating", roast.MeatRating},
+		{"PotatoesRating", roast.PotatoesRating},
+		{"VegRating", roast.VegRating},
+		{"GravyRating", roast.GravyRating},
+		{"MeatPotatoesRating", roast.MeatPotatoesRating},
+		{"MeatVegRating", roast.MeatVegRating},
+		{"MeatGravyRating", roast.MeatGravyRating},
+		{"PotatoesVegRating", roast.PotatoesVegRating},
+		{"PotatoesGravyRating", roast.PotatoesGravyRating},
+		{"VegGravyRating", roast.VegGravyRating},
+		{"MeatPotatoesVegRating", roast.MeatPotatoesVegRating},
+		{"MeatPotatoesGravyRating", roast.MeatPotatoesGravyRating},
+		{"MeatVegGravyRating", roast.MeatVegGravyRating},
+		{"PhotoCount", float64(roast.PhotoCount)},
+	}
+}
+
+func compareAggregates(stored, rebuilt *database.Roast) []Discrepancy {
+	discrepancies := []Discrepancy{}
+	actual := aggregateFields(rebuilt)
+	for i, field := range aggregateFields(stored) {
+		// NaN never compares equal so is always reported
+		if math.Abs(field.value-actual[i].value) <= driftTolerance {
+			continue
+		}
+		discrepancy := Discrepancy{Field: field.name, Stored: field.value, Actual: actual[i].value}
+		if math.IsNaN(field.value) || math.IsInf(field.value, 0) {
+			discrepancy.Stored = 0
+			discrepancy.Note = fmt.Sprintf("stored value is %v", field.value)
+		}
+		discrepancies = append(discrepancies, discrepancy)
+	}
+
+	discrepancies = append(discrepancies, compareDecayed(stored.Decay, rebuilt.Decay)...)
+	discrepancies = append(discrepancies, compareWeighted(stored.Weighted, rebuilt.Weighted)...)
+	discrepancies = append(discrepancies, compareTagCounts(stored.TagCounts, rebuilt.TagCounts)...)
+
+	for _, criterion := range Criteria {
+		storedCounts, actualCounts := stored.RatingDistribution[criterion], rebuilt.RatingDistribution[criterion]
+		for score := 1; score <= MaxScore; score++ {
+			storedCount, actualCount := countAt(storedCounts, score), countAt(actualCounts, score)
+			if storedCount == actualCount {
+				continue
+			}
+			discrepancies = append(discrepancies, Discrepancy{
+				Field:  fmt.Sprintf("RatingDistribution.%s[%d]", criterion, score),
+				Stored: float64(storedCount),
+				Actual: float64(actualCount),
+			})
+		}
+	}
+	return discrepancies
+}
+
+func countAt(counts []int, score int) int {
+	if score > len(counts) {
+		return 0
+	}
+	return counts[score-1]
+}
+
+// Rebuilt returns a copy of roast with its aggregates rebuilt from reviews, for previewing changes
+// to a roast's reviews before they're made
+func Rebuilt(roast database.Roast, reviews []database.Review, settings Settings) database.Roast {
+	rebuildAggregates(&roast, reviews, settings)
+	return roast
+}
@@ -0,0 +1,76 @@
+package ratings
+
+import (
+	"math"
+	"testing"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+)
+
+func TestRebuildAggregates(t *testing.T) {
+	roastReviews := []database.Review{
+		{OverallRating: 8, MeatRating: 9, PotatoesRating: 7, VegRating: 6, GravyRating: 10},
+		{OverallRating: 6, MeatRating: 7, PotatoesRating: 5, VegRating: 4, GravyRating: 8, Photos: []database.Photo{{Key: "a"}, {Key: "b"}}},
+	}
+
+	var roast database.Roast
+	settings := Settings{Prior: Prior{Mean: 5, Weight: 2}}
+	rebuildAggregates(&roast, roastReviews, settings)
+
+	if roast.ReviewCount != 2 {
+		t.Errorf("ReviewCount = %v; want 2", roast.ReviewCount)
+	}
+	if roast.OverallRating != 7 {
+		t.Errorf("OverallRating = %v; want 7", roast.OverallRating)
+	}
+	if roast.MeatGravyRating != 8.5 {
+		t.Errorf("MeatGravyRating = %v; want 8.5", roast.MeatGravyRating)
+	}
+	// The ranking score isn't stored so is only set when the roast is read
+	if roast.RankingScore != 0 {
+		t.Errorf("RankingScore = %v; want it left for SetRankingScore", roast.RankingScore)
+	}
+	SetRankingScore(&roast, settings)
+	if roast.RankingScore != 6 {
+		t.Errorf("RankingScore = %v; want 6", roast.RankingScore)
+	}
+	if roast.PhotoCount != 2 {
+		t.Errorf("PhotoCount = %v; want 2", roast.PhotoCount)
+	}
+
+	rebuildAggregates(&roast, nil, Settings{})
+	if roast.ReviewCount != 0 || roast.OverallRating != 0 || roast.MeatGravyRating != 0 || roast.PhotoCount != 0 {
+		t.Errorf("aggregates with no reviews = %+v; want zero", roast)
+	}
+}
+
+func TestCompareAggregates(t *testing.T) {
+	testCases := []struct {
+		name     string
+		stored   database.Roast
+		rebuilt  database.Roast
+		expected []string
+	}{
+		{"NoDrift", database.Roast{ReviewCount: 1, OverallRating: 7}, database.Roast{ReviewCount: 1, OverallRating: 7}, nil},
+		{"FloatingPointNoise", database.Roast{OverallRating: 7.000000000001}, database.Roast{OverallRating: 7}, nil},
+		{"MissedReview", database.Roast{ReviewCount: 2, OverallRating: 7}, database.Roast{ReviewCount: 3, OverallRating: 6}, []string{"ReviewCount", "OverallRating"}},
+		{"NaN", database.Roast{OverallRating: math.NaN()}, database.Roast{}, []string{"OverallRating"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			discrepancies := compareAggregates(&tc.stored, &tc.rebuilt)
+			if len(discrepancies) != len(tc.expected) {
+				t.Fatalf("compareAggregates() = %+v; want fields %v", discrepancies, tc.expected)
+			}
+			for i, d := range discrepancies {
+				if d.Field != tc.expected[i] {
+					t.Errorf("discrepancy %d field = %v; want %v", i, d.Field, tc.expected[i])
+				}
+				if math.IsNaN(d.Stored) {
+					t.Errorf("discrepancy %d has NaN stored value, it can't be encoded as JSON", i)
+				}
+			}
+		})
+	}
+}
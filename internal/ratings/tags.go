@@ -0,0 +1,45 @@
+package ratings
+
+import (
+	"sort"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+)
+
+// addTagCounts adds delta to the count of each tag the review gives the roast, delta is -1 when a review is removed
+func addTagCounts(roast *database.Roast, review database.Review, delta int) {
+	for _, tag := range review.Tags {
+		if roast.TagCounts == nil {
+			roast.TagCounts = map[string]int{}
+		}
+		if count := roast.TagCounts[tag] + delta; count > 0 {
+			roast.TagCounts[tag] = count
+		} else {
+			delete(roast.TagCounts, tag)
+		}
+	}
+}
+
+// compareTagCounts reports tags whose stored count doesn't match the rebuilt one, in tag order
+func compareTagCounts(stored, rebuilt map[string]int) []Discrepancy {
+	tags := map[string]bool{}
+	for tag := range stored {
+		tags[tag] = true
+	}
+	for tag := range rebuilt {
+		tags[tag] = true
+	}
+	sorted := make([]string, 0, len(tags))
+	for tag := range tags {
+		sorted = append(sorted, tag)
+	}
+	sort.Strings(sorted)
+
+	var discrepancies []Discrepancy
+	for _, tag := range sorted {
+		if stored[tag] != rebuilt[tag] {
+			discrepancies = append(discrepancies, Discrepancy{Field: "TagCounts." + tag, Stored: float64(stored[tag]), Actual: float64(rebuilt[tag])})
+		}
+	}
+	return discrepancies
+}
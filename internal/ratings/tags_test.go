@@ -0,0 +1,36 @@
+package ratings
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+)
+
+func TestAddTagCounts(t *testing.T) {
+	var roast database.Roast
+	addTagCounts(&roast, database.Review{Tags: []string{"huge-portions", "vegetarian"}}, 1)
+	addTagCounts(&roast, database.Review{Tags: []string{"huge-portions"}}, 1)
+	addTagCounts(&roast, database.Review{}, 1)
+	if expected := map[string]int{"huge-portions": 2, "vegetarian": 1}; !reflect.DeepEqual(roast.TagCounts, expected) {
+		t.Errorf("TagCounts = %v; want %v", roast.TagCounts, expected)
+	}
+
+	// Tags no reviewer gives any more are dropped rather than left at 0
+	addTagCounts(&roast, database.Review{Tags: []string{"vegetarian"}}, -1)
+	addTagCounts(&roast, database.Review{Tags: []string{"vegetarian"}}, -1)
+	if expected := map[string]int{"huge-portions": 2}; !reflect.DeepEqual(roast.TagCounts, expected) {
+		t.Errorf("TagCounts after removing = %v; want %v", roast.TagCounts, expected)
+	}
+}
+
+func TestCompareTagCounts(t *testing.T) {
+	discrepancies := compareTagCounts(map[string]int{"a": 1, "b": 2}, map[string]int{"b": 2, "c": 1})
+	var fields []string
+	for _, d := range discrepancies {
+		fields = append(fields, d.Field)
+	}
+	if expected := []string{"TagCounts.a", "TagCounts.c"}; !reflect.DeepEqual(fields, expected) {
+		t.Errorf("compareTagCounts() fields = %v; want %v", fields, expected)
+	}
+}
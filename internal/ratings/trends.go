@@ -0,0 +1,210 @@
+package ratings
+
+import (
+	"sort"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/reviews"
+)
+
+// RecentWindow is how far back the recent rating looks. Trends are monthly, so the reviews of the month the window
+// starts in are weighted by how much of that month is inside it.
+const RecentWindow = 90 * 24 * time.Hour
+
+const monthLayout = "2006-01"
+
+// Month returns the trend bucket a time falls in
+func Month(t time.Time) string {
+	return t.UTC().Format(monthLayout)
+}
+
+// TrendPoint is the average of each criterion over one month
+type TrendPoint struct {
+	Month    string             `json:"month"`
+	Count    int                `json:"count"`
+	Averages map[string]float64 `json:"averages,omitempty"`
+}
+
+// updateTrend adds or removes a review's ratings from the month it was added in
+func updateTrend(roastModels database.RoastModels, review database.Review, delta int) error {
+	addedAt := reviews.AddedAt(review)
+	if addedAt.IsZero() {
+		addedAt = time.Now()
+	}
+	return roastModels.AddToTrend(review, Month(addedAt), delta)
+}
+
+// TrendSeries returns a point for every month from `from` until `to`, including months without reviews
+func TrendSeries(trends []database.Trend, from, to time.Time) []TrendPoint {
+	byMonth := map[string]database.Trend{}
+	for _, trend := range trends {
+		byMonth[trend.Month] = trend
+	}
+
+	series := []TrendPoint{}
+	for month := startOfMonth(from); !month.After(to); month = month.AddDate(0, 1, 0) {
+		key := Month(month)
+		trend := byMonth[key]
+		series = append(series, TrendPoint{Month: key, Count: trend.Count, Averages: trendAverages([]database.Trend{trend})})
+	}
+	return series
+}
+
+// RecentAverages returns each criterion's average over RecentWindow
+func RecentAverages(trends []database.Trend, now time.Time) map[string]float64 {
+	from := now.Add(-RecentWindow)
+	fromMonth, firstWeight := Month(from), monthFraction(from)
+	return weightedAverages(trends, func(trend database.Trend) float64 {
+		switch {
+		case trend.Month < fromMonth:
+			return 0
+		case trend.Month == fromMonth:
+			return firstWeight
+		default:
+			return 1
+		}
+	})
+}
+
+// RecentOverallByRoast returns the recent overall rating of every roast in trends, keyed by roast ID
+func RecentOverallByRoast(trends []database.Trend, now time.Time) map[string]float64 {
+	byRoast := map[string][]database.Trend{}
+	for _, trend := range trends {
+		byRoast[trend.RoastID] = append(byRoast[trend.RoastID], trend)
+	}
+
+	recent := map[string]float64{}
+	for roastID, roastTrends := range byRoast {
+		if average, ok := RecentAverages(roastTrends, now)[CriterionOverall]; ok {
+			recent[roastID] = average
+		}
+	}
+	return recent
+}
+
+// RecentFromMonth is the first month included in the recent rating
+func RecentFromMonth(now time.Time) string {
+	return Month(now.Add(-RecentWindow))
+}
+
+// trendAverages combines trends into an average per criterion, nil if there were no reviews
+func trendAverages(trends []database.Trend) map[string]float64 {
+	return weightedAverages(trends, func(database.Trend) float64 { return 1 })
+}
+
+// weightedAverages combines trends into an average per criterion with each trend's reviews counting as weight
+// reviews, nil if there were no reviews with any weight
+func weightedAverages(trends []database.Trend, weight func(database.Trend) float64) map[string]float64 {
+	var count, overall, meat, potatoes, veg, gravy float64
+	for _, trend := range trends {
+		w := weight(trend)
+		count += w * float64(trend.Count)
+		overall += w * float64(trend.OverallSum)
+		meat += w * float64(trend.MeatSum)
+		potatoes += w * float64(trend.PotatoesSum)
+		veg += w * float64(trend.VegSum)
+		gravy += w * float64(trend.GravySum)
+	}
+	if count <= 0 {
+		return nil
+	}
+
+	return map[string]float64{
+		CriterionOverall:  overall / count,
+		CriterionMeat:     meat / count,
+		CriterionPotatoes: potatoes / count,
+		CriterionVeg:      veg / count,
+		CriterionGravy:    gravy / count,
+	}
+}
+
+// monthFraction is the fraction of t's month that's from t onwards
+func monthFraction(t time.Time) float64 {
+	start := startOfMonth(t)
+	end := start.AddDate(0, 1, 0)
+	return float64(end.Sub(t.UTC())) / float64(end.Sub(start))
+}
+
+// buildTrends groups reviews into monthly trends, used when rebuilding a roast's aggregates
+func buildTrends(roast *database.Roast, roastReviews []database.Review) map[string]database.Trend {
+	trends := map[string]database.Trend{}
+	for _, review := range roastReviews {
+		addedAt := reviews.AddedAt(review)
+		if addedAt.IsZero() {
+			continue
+		}
+		month := Month(addedAt)
+		trend := trends[month]
+		trend.RoastKey = roast.RoastKey
+		trend.RoastID = roast.RoastID
+		trend.Month = month
+		trend.Count++
+		trend.OverallSum += review.OverallRating
+		trend.MeatSum += review.MeatRating
+		trend.PotatoesSum += review.PotatoesRating
+		trend.VegSum += review.VegRating
+		trend.GravySum += review.GravyRating
+		trends[month] = trend
+	}
+	return trends
+}
+
+// compareTrends reports stored trends that don't match those rebuilt from reviews. It returns the months
+// that need writing and the stored months that no longer have any reviews.
+func compareTrends(stored []database.Trend, rebuilt map[string]database.Trend) (discrepancies []Discrepancy, changedMonths, staleMonths []string) {
+	storedByMonth := map[string]database.Trend{}
+	for _, trend := range stored {
+		storedByMonth[trend.Month] = trend
+		if _, ok := rebuilt[trend.Month]; !ok && trend.Count != 0 {
+			staleMonths = append(staleMonths, trend.Month)
+			discrepancies = append(discrepancies, Discrepancy{Field: "Trend[" + trend.Month + "].count", Stored: float64(trend.Count)})
+		}
+	}
+
+	for _, month := range sortedMonths(rebuilt) {
+		actual, current := rebuilt[month], storedByMonth[month]
+		fields := []struct {
+			name           string
+			stored, actual int
+		}{
+			{"count", current.Count, actual.Count},
+			{"overallSum", current.OverallSum, actual.OverallSum},
+			{"meatSum", current.MeatSum, actual.MeatSum},
+			{"potatoesSum", current.PotatoesSum, actual.PotatoesSum},
+			{"vegSum", current.VegSum, actual.VegSum},
+			{"gravySum", current.GravySum, actual.GravySum},
+		}
+
+		changed := false
+		for _, field := range fields {
+			if field.stored == field.actual {
+				continue
+			}
+			changed = true
+			discrepancies = append(discrepancies, Discrepancy{
+				Field:  "Trend[" + month + "]." + field.name,
+				Stored: float64(field.stored),
+				Actual: float64(field.actual),
+			})
+		}
+		if changed {
+			changedMonths = append(changedMonths, month)
+		}
+	}
+	return discrepancies, changedMonths, staleMonths
+}
+
+func sortedMonths(trends map[string]database.Trend) []string {
+	months := make([]string, 0, len(trends))
+	for month := range trends {
+		months = append(months, month)
+	}
+	sort.Strings(months)
+	return months
+}
+
+func startOfMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
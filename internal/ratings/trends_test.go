@@ -0,0 +1,70 @@
+package ratings
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+)
+
+func TestTrendSeries(t *testing.T) {
+	trends := []database.Trend{
+		{Month: "2026-08", Count: 2, OverallSum: 14, MeatSum: 16},
+		{Month: "2026-10", Count: 1, OverallSum: 9, MeatSum: 10},
+	}
+	from := time.Date(2026, 8, 15, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+
+	series := TrendSeries(trends, from, to)
+	if len(series) != 3 {
+		t.Fatalf("TrendSeries() returned %d months; want 3", len(series))
+	}
+	if series[0].Averages[CriterionOverall] != 7 || series[0].Averages[CriterionMeat] != 8 {
+		t.Errorf("August averages = %v; want overall 7 and meat 8", series[0].Averages)
+	}
+	if series[1].Month != "2026-09" || series[1].Count != 0 || series[1].Averages != nil {
+		t.Errorf("September = %+v; want an empty month", series[1])
+	}
+}
+
+func TestRecentAverages(t *testing.T) {
+	now := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	trends := []database.Trend{
+		{RoastID: "RedLion", Month: "2026-01", Count: 10, OverallSum: 30},
+		{RoastID: "RedLion", Month: "2026-07", Count: 1, OverallSum: 8},
+		{RoastID: "RedLion", Month: "2026-10", Count: 1, OverallSum: 10},
+		{RoastID: "Crown", Month: "2026-02", Count: 1, OverallSum: 10},
+	}
+
+	// The window starts on 21 July, so the July review counts for the 11 of its 31 days inside it
+	recent := RecentOverallByRoast(trends, now)
+	if want := (8*11.0/31 + 10) / (11.0/31 + 1); math.Abs(recent["RedLion"]-want) > 1e-9 {
+		t.Errorf("recent RedLion rating = %v; want %v", recent["RedLion"], want)
+	}
+	if _, ok := recent["Crown"]; ok {
+		t.Errorf("Crown has no recent reviews but was given a recent rating")
+	}
+}
+
+func TestCompareTrends(t *testing.T) {
+	stored := []database.Trend{
+		{Month: "2026-08", Count: 2, OverallSum: 14},
+		{Month: "2026-09", Count: 1, OverallSum: 5},
+	}
+	rebuilt := map[string]database.Trend{
+		"2026-08": {Month: "2026-08", Count: 2, OverallSum: 14},
+		"2026-10": {Month: "2026-10", Count: 1, OverallSum: 9},
+	}
+
+	discrepancies, changed, stale := compareTrends(stored, rebuilt)
+	if len(changed) != 1 || changed[0] != "2026-10" {
+		t.Errorf("changed months = %v; want [2026-10]", changed)
+	}
+	if len(stale) != 1 || stale[0] != "2026-09" {
+		t.Errorf("stale months = %v; want [2026-09]", stale)
+	}
+	if len(discrepancies) != 3 {
+		t.Errorf("discrepancies = %+v; want 3", discrepancies)
+	}
+}
@@ -0,0 +1,43 @@
+package ratings
+
+import "github.com/94DanielBrown/roasts-api/internal/database"
+
+// MaxValueScore is the value score of the best value roast
+const MaxValueScore = 100
+
+// SetValueScores scores each roast's rating against its price band, normalized across roasts so the best
+// value scores MaxValueScore. The ranking score is used as the rating so a single generous review at a low
+// price doesn't top the list. Roasts without reviews or a price range aren't scored.
+// The scores are relative to the roasts passed in, so it should be given every roast before any filtering.
+func SetValueScores(roasts []database.Roast) {
+	best := BestValue(roasts)
+	for i := range roasts {
+		roasts[i].ValueScore = ValueScore(&roasts[i], best)
+	}
+}
+
+// BestValue is the highest rating for the price of any of the roasts, which value scores are relative to
+func BestValue(roasts []database.Roast) float64 {
+	var best float64
+	for i := range roasts {
+		best = max(best, rawValue(&roasts[i]))
+	}
+	return best
+}
+
+// ValueScore scores a roast's value against the best value of every roast, from BestValue. Scores are
+// capped at MaxValueScore as best may have been taken before the roast's latest reviews.
+func ValueScore(roast *database.Roast, best float64) float64 {
+	if best == 0 {
+		return 0
+	}
+	return min(rawValue(roast)/best*MaxValueScore, MaxValueScore)
+}
+
+// rawValue is a roast's rating for its price band, 0 for roasts without reviews or a price range
+func rawValue(roast *database.Roast) float64 {
+	if roast.ReviewCount == 0 || roast.PriceRange <= 0 {
+		return 0
+	}
+	return roast.RankingScore / float64(roast.PriceRange)
+}
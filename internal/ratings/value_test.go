@@ -0,0 +1,44 @@
+package ratings
+
+import (
+	"testing"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+)
+
+func TestSetValueScores(t *testing.T) {
+	roasts := []database.Roast{
+		{RoastID: "Cheap", RankingScore: 8, PriceRange: 1, ReviewCount: 4},
+		{RoastID: "Pricey", RankingScore: 9, PriceRange: 3, ReviewCount: 10},
+		{RoastID: "Unpriced", RankingScore: 9, ReviewCount: 2},
+		{RoastID: "Unreviewed", PriceRange: 1},
+	}
+	SetValueScores(roasts)
+
+	expected := []float64{100, 37.5, 0, 0}
+	for i, roast := range roasts {
+		if roast.ValueScore != expected[i] {
+			t.Errorf("%s ValueScore = %v; want %v", roast.RoastID, roast.ValueScore, expected[i])
+		}
+	}
+}
+
+func TestValueScore(t *testing.T) {
+	roast := database.Roast{RankingScore: 8, PriceRange: 2, ReviewCount: 3}
+	testCases := []struct {
+		best     float64
+		expected float64
+	}{
+		{8, 50},
+		{4, 100},
+		// The best value was taken before this roast's latest reviews
+		{2, 100},
+		{0, 0},
+	}
+
+	for _, tc := range testCases {
+		if score := ValueScore(&roast, tc.best); score != tc.expected {
+			t.Errorf("ValueScore() against %v = %v; want %v", tc.best, score, tc.expected)
+		}
+	}
+}
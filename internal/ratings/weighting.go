@@ -0,0 +1,196 @@
+package ratings
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+)
+
+// Thresholds of the weighting model. A reviewer reaches full credibility once their account is
+// fullCredibilityAge old and they've written fullCredibilityReviews reviews, and until then their
+// reviews count for at least minCredibility.
+const (
+	fullCredibilityAge     = 30 * 24 * time.Hour
+	fullCredibilityReviews = 5
+	minCredibility         = 0.2
+	// minConsensusReviews is how many reviews a reviewer needs before their deviation from consensus counts
+	minConsensusReviews = 3
+	// consensusDeviation is the mean deviation at which a reviewer's reviews count half as much
+	consensusDeviation = 3
+	// outlierStdDevs is how many standard deviations from a roast's average a score can be before it's dampened
+	outlierStdDevs = 2
+	// minOutlierReviews is how many reviews a roast needs before outliers are dampened
+	minOutlierReviews = 5
+)
+
+// Reviewer is what's known about a review's author when it's weighed
+type Reviewer struct {
+	// ReviewCount is how many other reviews they've written
+	ReviewCount int
+	// AccountAge is 0 if it isn't known, which is treated as an established account
+	AccountAge time.Duration
+	// DeviationSum and DeviationCount track how far their overall scores are from each roast's average
+	DeviationSum   float64
+	DeviationCount int
+}
+
+// NewReviewer builds a Reviewer from a user and the reviews they've already written
+func NewReviewer(user *database.User, reviewCount int, now time.Time) Reviewer {
+	reviewer := Reviewer{ReviewCount: reviewCount}
+	if user == nil {
+		return reviewer
+	}
+	if user.CreatedAt > 0 {
+		reviewer.AccountAge = now.Sub(time.UnixMilli(user.CreatedAt))
+	}
+	reviewer.DeviationSum, reviewer.DeviationCount = user.DeviationSum, user.DeviationCount
+	return reviewer
+}
+
+// Credibility is how much the reviewer's reviews count from minCredibility to 1. New accounts
+// with few reviews count less, as do reviewers who consistently disagree with everyone else.
+func (r Reviewer) Credibility() float64 {
+	age := 1.0
+	if r.AccountAge > 0 {
+		age = math.Min(float64(r.AccountAge)/float64(fullCredibilityAge), 1)
+	}
+	history := math.Min(float64(r.ReviewCount)/fullCredibilityReviews, 1)
+	credibility := minCredibility + (1-minCredibility)*(age+history)/2
+
+	if r.DeviationCount >= minConsensusReviews {
+		meanDeviation := r.DeviationSum / float64(r.DeviationCount)
+		credibility *= consensusDeviation / (consensusDeviation + meanDeviation)
+	}
+	return math.Max(credibility, minCredibility)
+}
+
+// ReviewWeight weighs a new review by its reviewer's credibility, dampening overall scores that are
+// outliers against the roast's existing reviews
+func ReviewWeight(review database.Review, roast *database.Roast, reviewer Reviewer) float64 {
+	return reviewer.Credibility() * outlierDampening(review, roast)
+}
+
+// outlierDampening scales down reviews whose overall score is more than outlierStdDevs from the roast's average
+func outlierDampening(review database.Review, roast *database.Roast) float64 {
+	if roast.ReviewCount < minOutlierReviews {
+		return 1
+	}
+	stdDev := Stats(roast.RatingDistribution)[CriterionOverall].StdDev
+	if stdDev == 0 {
+		return 1
+	}
+
+	deviations := math.Abs(float64(review.OverallRating)-roast.OverallRating) / stdDev
+	if deviations <= outlierStdDevs {
+		return 1
+	}
+	return outlierStdDevs / deviations
+}
+
+// WeighReview sets the weight of a new review from its author's history and the roast's existing reviews.
+// It returns how far the review's overall score is from the roast's average, to be recorded against the
+// author with UserModels.AddReviewDeviation once the review is saved. ok is false if the roast had no
+// reviews to compare against.
+func WeighReview(roastModels database.RoastModels, userModels database.UserModels, review *database.Review) (deviation float64, ok bool, err error) {
+	roast, err := roastModels.GetRoastByPrefix(review.RoastKey)
+	if err != nil {
+		return 0, false, err
+	}
+	if roast == nil {
+		return 0, false, errors.New("no roast found")
+	}
+
+	user, err := userModels.GetUserByPrefix("USER#" + review.UserID)
+	if err != nil {
+		return 0, false, err
+	}
+	userReviews, err := userModels.GetUserReviews(review.UserID)
+	if err != nil {
+		return 0, false, err
+	}
+
+	reviewer := NewReviewer(user, len(userReviews), time.Now())
+	review.Weight = ReviewWeight(*review, roast, reviewer)
+
+	if roast.ReviewCount == 0 {
+		return 0, false, nil
+	}
+	return math.Abs(float64(review.OverallRating) - roast.OverallRating), true, nil
+}
+
+// reviewWeight is how much a review counts towards the weighted ratings, reviews from before weighting count fully
+func reviewWeight(review database.Review) float64 {
+	if review.Weight <= 0 {
+		return 1
+	}
+	return review.Weight
+}
+
+// addWeighted adds a review to the roast's weighted ratings, or removes it when sign is -1
+func addWeighted(roast *database.Roast, review database.Review, sign float64) {
+	if roast.Weighted == nil {
+		roast.Weighted = &database.WeightedRatings{}
+	}
+	weighted := roast.Weighted
+	if weighted.Sums == nil {
+		weighted.Sums = map[string]float64{}
+	}
+
+	weight := sign * reviewWeight(review)
+	for criterion, score := range Scores(review) {
+		weighted.Sums[criterion] += weight * float64(score)
+	}
+	weighted.Weight += weight
+
+	weighted.Ratings = map[string]float64{}
+	if weighted.Weight < minDecayedWeight {
+		weighted.Sums, weighted.Weight = map[string]float64{}, 0
+		return
+	}
+	for criterion, sum := range weighted.Sums {
+		weighted.Ratings[criterion] = sum / weighted.Weight
+	}
+}
+
+// updateWeighted keeps the weighted ratings up to date, adding them if weighting is enabled.
+// Once a roast has weighted ratings reviews are removed from them even if weighting has been disabled.
+func updateWeighted(roast *database.Roast, review database.Review, sign float64, settings Settings) {
+	if roast.Weighted == nil && (!settings.Weighting || sign < 0) {
+		return
+	}
+	addWeighted(roast, review, sign)
+}
+
+// rebuildWeighted rebuilds the weighted ratings from all of a roast's reviews using their stored weights
+func rebuildWeighted(roast *database.Roast, roastReviews []database.Review, settings Settings) {
+	if roast.Weighted == nil && !settings.Weighting {
+		return
+	}
+	roast.Weighted = &database.WeightedRatings{Sums: map[string]float64{}, Ratings: map[string]float64{}}
+	for _, review := range roastReviews {
+		addWeighted(roast, review, 1)
+	}
+}
+
+// compareWeighted reports drift in the weighted ratings
+func compareWeighted(stored, rebuilt *database.WeightedRatings) []Discrepancy {
+	if rebuilt == nil {
+		return nil
+	}
+	if stored == nil {
+		stored = &database.WeightedRatings{}
+	}
+
+	var discrepancies []Discrepancy
+	if math.Abs(stored.Weight-rebuilt.Weight) > driftTolerance {
+		discrepancies = append(discrepancies, Discrepancy{Field: "Weighted.Weight", Stored: stored.Weight, Actual: rebuilt.Weight})
+	}
+	for _, criterion := range Criteria {
+		if math.Abs(stored.Sums[criterion]-rebuilt.Sums[criterion]) > driftTolerance {
+			discrepancies = append(discrepancies, Discrepancy{Field: "Weighted.Sums." + criterion, Stored: stored.Sums[criterion], Actual: rebuilt.Sums[criterion]})
+		}
+	}
+	return discrepancies
+}
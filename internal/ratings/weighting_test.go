@@ -0,0 +1,75 @@
+package ratings
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+)
+
+func TestCredibility(t *testing.T) {
+	testCases := []struct {
+		name     string
+		reviewer Reviewer
+		expected float64
+	}{
+		{"BrandNewAccount", Reviewer{AccountAge: time.Hour}, 0.2 + 0.8*(1.0/720)/2},
+		{"EstablishedReviewer", Reviewer{ReviewCount: 12, AccountAge: 365 * 24 * time.Hour}, 1},
+		{"UnknownAccountAge", Reviewer{ReviewCount: 5}, 1},
+		{"DisagreesWithEveryone", Reviewer{ReviewCount: 5, DeviationSum: 9, DeviationCount: 3}, 0.5},
+		{"TooFewReviewsToJudgeConsensus", Reviewer{ReviewCount: 5, DeviationSum: 18, DeviationCount: 2}, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if credibility := tc.reviewer.Credibility(); math.Abs(credibility-tc.expected) > 1e-9 {
+				t.Errorf("Credibility() = %v; want %v", credibility, tc.expected)
+			}
+		})
+	}
+}
+
+func TestOutlierDampening(t *testing.T) {
+	var roast database.Roast
+	for _, score := range []int{7, 8, 8, 8, 9} {
+		review := database.Review{OverallRating: score}
+		addReview(&roast, review)
+	}
+
+	if dampening := outlierDampening(database.Review{OverallRating: 8}, &roast); dampening != 1 {
+		t.Errorf("outlierDampening() of a typical score = %v; want 1", dampening)
+	}
+	if dampening := outlierDampening(database.Review{OverallRating: 1}, &roast); dampening >= 1 {
+		t.Errorf("outlierDampening() of an outlier = %v; want less than 1", dampening)
+	}
+}
+
+func TestWeightedRatings(t *testing.T) {
+	trusted := database.Review{OverallRating: 8, Weight: 1}
+	untrusted := database.Review{OverallRating: 1, Weight: 0.25}
+	legacy := database.Review{OverallRating: 6}
+
+	var roast database.Roast
+	settings := Settings{Weighting: true}
+	for _, review := range []database.Review{trusted, untrusted, legacy} {
+		updateWeighted(&roast, review, 1, settings)
+	}
+
+	// (8*1 + 1*0.25 + 6*1) / 2.25
+	if rating, want := roast.Weighted.Ratings[CriterionOverall], 14.25/2.25; math.Abs(rating-want) > 1e-9 {
+		t.Errorf("weighted overall rating = %v; want %v", rating, want)
+	}
+
+	rebuilt := roast
+	rebuildWeighted(&rebuilt, []database.Review{legacy, trusted, untrusted}, Settings{})
+	if discrepancies := compareWeighted(roast.Weighted, rebuilt.Weighted); len(discrepancies) != 0 {
+		t.Errorf("compareWeighted() = %+v; want none", discrepancies)
+	}
+
+	var unweighted database.Roast
+	updateWeighted(&unweighted, trusted, 1, Settings{})
+	if unweighted.Weighted != nil {
+		t.Errorf("weighted ratings kept with weighting disabled: %+v", unweighted.Weighted)
+	}
+}
@@ -26,15 +26,15 @@ func CreateReviewValidator(next echo.HandlerFunc) echo.HandlerFunc {
 		req.Body = io.NopCloser(bytes.NewBuffer(body))
 
 		var reqData struct {
-			UserID         string `json:"userID"`
-			Comment        string `json:"comment,omitempty"`
-			RoastName      string `json:"roastName"`
-			ImageURL       string `json:"imageURL"`
-			OverallRating  int    `json:"overallRating"`
-			MeatRating     int    `json:"meatRating"`
-			PotatoesRating int    `json:"potatoesRating"`
-			VegRating      int    `json:"vegRating"`
-			GravyRating    int    `json:"gravyRating"`
+			UserID         string            `json:"userID"`
+			Comment        string            `json:"comment,omitempty"`
+			RoastName      string            `json:"roastName"`
+			Images         map[string]string `json:"images,omitempty"`
+			OverallRating  int               `json:"overallRating"`
+			MeatRating     int               `json:"meatRating"`
+			PotatoesRating int               `json:"potatoesRating"`
+			VegRating      int               `json:"vegRating"`
+			GravyRating    int               `json:"gravyRating"`
 		}
 
 		if err := json.Unmarshal(body, &reqData); err != nil {
@@ -2,7 +2,11 @@ package reviews
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
 )
 
 // GenerateID returns the current epoch time as a string to be used as an RoastID for a review
@@ -13,3 +17,14 @@ func GenerateID() string {
 	reviewID := fmt.Sprintf("%d", epochMillis)
 	return reviewID
 }
+
+// AddedAt returns when a review was added, from DateAdded in epoch milliseconds or else the epoch time in its key
+func AddedAt(review database.Review) time.Time {
+	if review.DateAdded > 0 {
+		return time.UnixMilli(int64(review.DateAdded))
+	}
+	if epochMillis, err := strconv.ParseInt(strings.TrimPrefix(review.ReviewKey, "REVIEW#"), 10, 64); err == nil {
+		return time.UnixMilli(epochMillis)
+	}
+	return time.Time{}
+}
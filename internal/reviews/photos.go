@@ -0,0 +1,126 @@
+package reviews
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/images"
+	"github.com/google/uuid"
+)
+
+// Limits on the photos attached to a review
+const (
+	MaxPhotos        = 10
+	MaxCaptionLength = 280
+)
+
+var (
+	ErrTooManyPhotos   = fmt.Errorf("a review can have at most %d photos", MaxPhotos)
+	ErrCaptionTooLong  = fmt.Errorf("captions can be at most %d characters", MaxCaptionLength)
+	ErrPhotoNotFound   = errors.New("photo not attached to review")
+	ErrPhotoAttached   = errors.New("photo already attached to review")
+	ErrPhotoOrder      = errors.New("order must list every photo on the review exactly once")
+	ErrPhotoKeyInvalid = errors.New("photo key doesn't belong to user")
+)
+
+// PhotoKeyPrefix is where a user's uploads are stored in the image bucket
+func PhotoKeyPrefix(userID string) string {
+	return "users/" + userID + "/photos/"
+}
+
+// NewPhotoKey returns a new object key for one of the user's photos, random so concurrent uploads can't collide
+func NewPhotoKey(userID string) string {
+	return PhotoKeyPrefix(userID) + uuid.NewString()
+}
+
+// OwnsPhotoKey reports whether key was handed out to the user by NewPhotoKey
+func OwnsPhotoKey(userID, key string) bool {
+	return userID != "" && images.OwnsKey(PhotoKeyPrefix(userID), key)
+}
+
+// CanAddPhoto checks a photo with key and caption can be attached to a review
+func CanAddPhoto(review *database.Review, key, caption string) error {
+	if len(caption) > MaxCaptionLength {
+		return ErrCaptionTooLong
+	}
+	if len(review.Photos) >= MaxPhotos {
+		return ErrTooManyPhotos
+	}
+	if photoIndex(review.Photos, key) >= 0 {
+		return ErrPhotoAttached
+	}
+	return nil
+}
+
+// AddPhoto attaches a photo to the end of a review's photos
+func AddPhoto(review *database.Review, photo database.Photo) error {
+	if err := CanAddPhoto(review, photo.Key, photo.Caption); err != nil {
+		return err
+	}
+
+	photo.Order = len(review.Photos)
+	review.Photos = append(review.Photos, photo)
+	return nil
+}
+
+// RemovePhoto detaches a photo from a review, returning the removed photo so its object can be deleted
+func RemovePhoto(review *database.Review, key string) (database.Photo, error) {
+	i := photoIndex(review.Photos, key)
+	if i < 0 {
+		return database.Photo{}, ErrPhotoNotFound
+	}
+
+	removed := review.Photos[i]
+	review.Photos = append(review.Photos[:i], review.Photos[i+1:]...)
+	renumber(review.Photos)
+	return removed, nil
+}
+
+// ReorderPhotos puts a review's photos in the order of keys, which must list each photo once
+func ReorderPhotos(review *database.Review, keys []string) error {
+	if len(keys) != len(review.Photos) {
+		return ErrPhotoOrder
+	}
+
+	reordered := make([]database.Photo, 0, len(keys))
+	for _, key := range keys {
+		i := photoIndex(review.Photos, key)
+		if i < 0 || photoIndex(reordered, key) >= 0 {
+			return ErrPhotoOrder
+		}
+		reordered = append(reordered, review.Photos[i])
+	}
+
+	renumber(reordered)
+	review.Photos = reordered
+	return nil
+}
+
+// SetPhotoCaption sets the caption of one of a review's photos
+func SetPhotoCaption(review *database.Review, key, caption string) error {
+	if len(caption) > MaxCaptionLength {
+		return ErrCaptionTooLong
+	}
+	i := photoIndex(review.Photos, key)
+	if i < 0 {
+		return ErrPhotoNotFound
+	}
+	review.Photos[i].Caption = caption
+	return nil
+}
+
+func photoIndex(photos []database.Photo, key string) int {
+	for i, photo := range photos {
+		if photo.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func renumber(photos []database.Photo) {
+	for i := range photos {
+		photos[i].Order = i
+	}
+}
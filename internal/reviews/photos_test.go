@@ -0,0 +1,71 @@
+package reviews
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+)
+
+func TestOwnsPhotoKey(t *testing.T) {
+	key := NewPhotoKey("user1")
+	if key == NewPhotoKey("user1") {
+		t.Fatalf("NewPhotoKey() returned %s twice", key)
+	}
+
+	testCases := []struct {
+		userID   string
+		key      string
+		expected bool
+	}{
+		{"user1", key, true},
+		{"user2", key, false},
+		{"user1", "users/user1/photos/../../user2/photos/x", false},
+		{"user1", "upload/1700000000", false},
+		{"", "users//photos/" + key[len(PhotoKeyPrefix("user1")):], false},
+	}
+
+	for _, tc := range testCases {
+		if owned := OwnsPhotoKey(tc.userID, tc.key); owned != tc.expected {
+			t.Errorf("OwnsPhotoKey(%q, %q) = %v; want %v", tc.userID, tc.key, owned, tc.expected)
+		}
+	}
+}
+
+func TestPhotoLifecycle(t *testing.T) {
+	var review database.Review
+	for _, key := range []string{"a", "b", "c"} {
+		if err := AddPhoto(&review, database.Photo{Key: key}); err != nil {
+			t.Fatalf("AddPhoto(%s) returned error: %v", key, err)
+		}
+	}
+	if err := AddPhoto(&review, database.Photo{Key: "a"}); !errors.Is(err, ErrPhotoAttached) {
+		t.Errorf("AddPhoto() of an attached photo = %v; want %v", err, ErrPhotoAttached)
+	}
+
+	if err := ReorderPhotos(&review, []string{"c", "a"}); !errors.Is(err, ErrPhotoOrder) {
+		t.Errorf("ReorderPhotos() missing a photo = %v; want %v", err, ErrPhotoOrder)
+	}
+	if err := ReorderPhotos(&review, []string{"c", "a", "a"}); !errors.Is(err, ErrPhotoOrder) {
+		t.Errorf("ReorderPhotos() with a duplicate = %v; want %v", err, ErrPhotoOrder)
+	}
+	if err := ReorderPhotos(&review, []string{"c", "a", "b"}); err != nil {
+		t.Fatalf("ReorderPhotos() returned error: %v", err)
+	}
+
+	if err := SetPhotoCaption(&review, "a", "Yorkshire puddings"); err != nil {
+		t.Fatalf("SetPhotoCaption() returned error: %v", err)
+	}
+	if _, err := RemovePhoto(&review, "c"); err != nil {
+		t.Fatalf("RemovePhoto() returned error: %v", err)
+	}
+
+	expected := []database.Photo{
+		{Key: "a", Caption: "Yorkshire puddings", Order: 0},
+		{Key: "b", Order: 1},
+	}
+	if !reflect.DeepEqual(review.Photos, expected) {
+		t.Errorf("Photos = %+v; want %+v", review.Photos, expected)
+	}
+}
@@ -0,0 +1,129 @@
+package roasts
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/ratings"
+)
+
+// CompareValue is the key of the value score's winners in a comparison, alongside the criteria
+const CompareValue = "value"
+
+// winnerTolerance is how close two averages have to be to tie
+const winnerTolerance = 1e-9
+
+// Comparison puts roasts side by side
+type Comparison struct {
+	Roasts []ComparedRoast `json:"roasts"`
+	// Winners are the IDs of the best roasts for each criterion and for value, more than one when they
+	// tie. Roasts without reviews can't win.
+	Winners map[string][]string `json:"winners"`
+	// Reviewers are the people who reviewed more than one of the roasts, most roasts reviewed first
+	Reviewers []HeadToHead `json:"reviewers"`
+}
+
+// ComparedRoast is a roast in a comparison with its average for each criterion
+type ComparedRoast struct {
+	*database.Roast
+	Ratings map[string]float64 `json:"ratings"`
+}
+
+// HeadToHead is a reviewer's scores for each of the compared roasts they reviewed
+type HeadToHead struct {
+	UserID      string `json:"userID"`
+	DisplayName string `json:"displayName,omitempty"`
+	// Scores are keyed by roast ID and then criterion. When someone reviewed a roast more than once their
+	// latest review is used.
+	Scores map[string]map[string]int `json:"scores"`
+}
+
+// Compare compares roasts, given in the order they're shown, using the reviews of each
+func Compare(roasts []*database.Roast, reviews [][]database.Review) Comparison {
+	comparison := Comparison{Roasts: []ComparedRoast{}, Winners: map[string][]string{}, Reviewers: []HeadToHead{}}
+	for _, roast := range roasts {
+		compared := ComparedRoast{Roast: roast, Ratings: map[string]float64{}}
+		for _, criterion := range ratings.Criteria {
+			compared.Ratings[criterion] = ratings.Rating(*roast, criterion)
+		}
+		comparison.Roasts = append(comparison.Roasts, compared)
+	}
+
+	for _, criterion := range ratings.Criteria {
+		comparison.Winners[criterion] = winners(roasts, func(roast *database.Roast) float64 {
+			return ratings.Rating(*roast, criterion)
+		})
+	}
+	comparison.Winners[CompareValue] = winners(roasts, func(roast *database.Roast) float64 { return roast.ValueScore })
+
+	comparison.Reviewers = headToHeads(roasts, reviews)
+	return comparison
+}
+
+// winners returns the IDs of the reviewed roasts with the highest score
+func winners(roasts []*database.Roast, score func(*database.Roast) float64) []string {
+	best := 0.0
+	ids := []string{}
+	for _, roast := range roasts {
+		value := score(roast)
+		if roast.ReviewCount == 0 || value <= 0 {
+			continue
+		}
+		switch {
+		case value > best+winnerTolerance:
+			best = value
+			ids = []string{roast.RoastID}
+		case value >= best-winnerTolerance:
+			ids = append(ids, roast.RoastID)
+		}
+	}
+	return ids
+}
+
+// headToHeads returns the reviewers of more than one of the roasts
+func headToHeads(roasts []*database.Roast, reviews [][]database.Review) []HeadToHead {
+	byUser := map[string]*HeadToHead{}
+	// latest is when each reviewer's counted review of each roast was added
+	latest := map[[2]string]int{}
+	for i, roastReviews := range reviews {
+		roastID := roasts[i].RoastID
+		for _, review := range roastReviews {
+			if review.UserID == "" {
+				continue
+			}
+			key := [2]string{review.UserID, roastID}
+			if added, ok := latest[key]; ok && added > review.DateAdded {
+				continue
+			}
+			latest[key] = review.DateAdded
+
+			reviewer, ok := byUser[review.UserID]
+			if !ok {
+				reviewer = &HeadToHead{UserID: review.UserID, Scores: map[string]map[string]int{}}
+				byUser[review.UserID] = reviewer
+			}
+			if review.DisplayName != "" {
+				reviewer.DisplayName = review.DisplayName
+			}
+			reviewer.Scores[roastID] = ratings.Scores(review)
+		}
+	}
+
+	reviewers := []HeadToHead{}
+	for _, reviewer := range byUser {
+		if len(reviewer.Scores) > 1 {
+			reviewers = append(reviewers, *reviewer)
+		}
+	}
+	sort.Slice(reviewers, func(i, j int) bool {
+		if len(reviewers[i].Scores) != len(reviewers[j].Scores) {
+			return len(reviewers[i].Scores) > len(reviewers[j].Scores)
+		}
+		if a, b := strings.ToLower(reviewers[i].DisplayName), strings.ToLower(reviewers[j].DisplayName); a != b {
+			return a < b
+		}
+		return reviewers[i].UserID < reviewers[j].UserID
+	})
+	return reviewers
+}
@@ -0,0 +1,57 @@
+package roasts
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/ratings"
+)
+
+func TestCompare(t *testing.T) {
+	compared := []*database.Roast{
+		{RoastID: "RedLion", ReviewCount: 2, OverallRating: 8, MeatRating: 7, GravyRating: 9, ValueScore: 80},
+		{RoastID: "Crown", ReviewCount: 3, OverallRating: 8, MeatRating: 9, GravyRating: 6, ValueScore: 100},
+		{RoastID: "NewPub"},
+	}
+	review := func(userID, name string, overall, dateAdded int) database.Review {
+		return database.Review{UserID: userID, DisplayName: name, OverallRating: overall, DateAdded: dateAdded}
+	}
+	reviews := [][]database.Review{
+		{review("u1", "Ann", 8, 1), review("u2", "Bob", 7, 1), review("u3", "Cat", 6, 1)},
+		// u1 reviewed the Crown twice, their latest review is the one compared
+		{review("u1", "Ann", 9, 3), review("u1", "Ann", 5, 2), review("u2", "Bob", 9, 1)},
+		{review("u2", "Bob", 4, 1)},
+	}
+
+	comparison := Compare(compared, reviews)
+
+	if len(comparison.Roasts) != 3 || comparison.Roasts[1].Ratings[ratings.CriterionMeat] != 9 {
+		t.Errorf("Roasts = %+v; want three with the Crown's meat rated 9", comparison.Roasts)
+	}
+	expectedWinners := map[string][]string{
+		ratings.CriterionOverall:  {"RedLion", "Crown"},
+		ratings.CriterionMeat:     {"Crown"},
+		ratings.CriterionPotatoes: {},
+		ratings.CriterionVeg:      {},
+		ratings.CriterionGravy:    {"RedLion"},
+		CompareValue:              {"Crown"},
+	}
+	if !reflect.DeepEqual(comparison.Winners, expectedWinners) {
+		t.Errorf("Winners = %v; want %v", comparison.Winners, expectedWinners)
+	}
+
+	var reviewers []string
+	for _, reviewer := range comparison.Reviewers {
+		reviewers = append(reviewers, reviewer.UserID)
+	}
+	if !reflect.DeepEqual(reviewers, []string{"u2", "u1"}) {
+		t.Fatalf("Reviewers = %v; want [u2 u1]", reviewers)
+	}
+	if score := comparison.Reviewers[1].Scores["Crown"][ratings.CriterionOverall]; score != 9 {
+		t.Errorf("u1's overall score for the Crown = %d; want 9", score)
+	}
+	if score := comparison.Reviewers[0].Scores["NewPub"][ratings.CriterionOverall]; score != 4 {
+		t.Errorf("u2's overall score for NewPub = %d; want 4", score)
+	}
+}
@@ -0,0 +1,109 @@
+package roasts
+
+import (
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/ratings"
+)
+
+var (
+	// ReviewThresholds are the minimum review counts the listing counts roasts for
+	ReviewThresholds = []int{1, 5, 10, 25, 50}
+	// RatingThresholds are the minimum average ratings the listing counts roasts for on each criterion
+	RatingThresholds = []int{5, 6, 7, 8, 9}
+)
+
+// Facets counts how many roasts there would be for each value of a filter, so the filters can show what
+// they'd narrow the listing down to. Each filter's counts are of the roasts matching every other filter,
+// except tags, which are combined so are counted from the roasts matching all of the filters.
+type Facets struct {
+	// Price is keyed by price range
+	Price map[int]int    `json:"price"`
+	Towns map[string]int `json:"towns"`
+	Tags  map[string]int `json:"tags"`
+	// HasPhotos is how many of the roasts have photos
+	HasPhotos int `json:"hasPhotos"`
+	// ServedToday and OpenNow are how many of the roasts are served today and now, only counted when
+	// the filter has a calendar to work them out with
+	ServedToday int `json:"servedToday"`
+	OpenNow     int `json:"openNow"`
+	// MinReviews is keyed by each of ReviewThresholds
+	MinReviews map[int]int `json:"minReviews"`
+	// MinRatings is keyed by criterion and then each of RatingThresholds
+	MinRatings map[string]map[int]int `json:"minRatings"`
+}
+
+// ApplyWithFacets returns the roasts matching the filter and the facet counts of every roast,
+// in a single pass over them
+func (f Filter) ApplyWithFacets(roasts []database.Roast) ([]database.Roast, Facets) {
+	f = f.normalised()
+	facets := Facets{
+		Price:      map[int]int{},
+		Towns:      map[string]int{},
+		Tags:       map[string]int{},
+		MinReviews: map[int]int{},
+		MinRatings: map[string]map[int]int{},
+	}
+	for _, criterion := range ratings.Criteria {
+		facets.MinRatings[criterion] = map[int]int{}
+	}
+
+	filtered := []database.Roast{}
+	for _, roast := range roasts {
+		failed := f.failed(roast)
+		if failed == 0 {
+			filtered = append(filtered, roast)
+		}
+		// A roast that fails more than one filter doesn't count towards any of them
+		if failed&(failed-1) == 0 {
+			f.count(&facets, roast, failed)
+		}
+	}
+	return filtered, facets
+}
+
+// count adds a roast to the counts of the facets whose filter is the only one it fails,
+// or every facet if it doesn't fail any
+func (f Filter) count(facets *Facets, roast database.Roast, failed uint) {
+	counts := func(check uint) bool { return failed == 0 || failed == check }
+
+	if counts(checkPrice) && roast.PriceRange != 0 {
+		facets.Price[roast.PriceRange]++
+	}
+	if name := town(roast); counts(checkTown) && name != "" {
+		facets.Towns[name]++
+	}
+	if failed == 0 {
+		for _, tag := range roast.Tags {
+			facets.Tags[tag]++
+		}
+	}
+	if counts(checkPhotos) && roast.PhotoCount > 0 {
+		facets.HasPhotos++
+	}
+	if f.Calendar != nil {
+		if counts(checkServedToday) && f.Calendar.ServedOn(&roast, f.Now) {
+			facets.ServedToday++
+		}
+		if counts(checkOpenNow) && f.Calendar.Serving(&roast, f.Now) {
+			facets.OpenNow++
+		}
+	}
+	if counts(checkReviews) {
+		for _, threshold := range ReviewThresholds {
+			if roast.ReviewCount >= threshold {
+				facets.MinReviews[threshold]++
+			}
+		}
+	}
+	for i, criterion := range ratings.Criteria {
+		if !counts(checkRating << i) {
+			continue
+		}
+		rating := ratings.Rating(roast, criterion)
+		for _, threshold := range RatingThresholds {
+			if rating >= float64(threshold) {
+				facets.MinRatings[criterion][threshold]++
+			}
+		}
+	}
+}
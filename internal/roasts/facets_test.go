@@ -0,0 +1,141 @@
+package roasts
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/ratings"
+	"github.com/94DanielBrown/roasts-api/internal/venues"
+)
+
+var (
+	sundayLunch   = time.Date(2024, 3, 3, 13, 0, 0, 0, time.UTC)
+	sundayMorning = time.Date(2024, 3, 3, 10, 0, 0, 0, time.UTC)
+)
+
+func testRoasts() []database.Roast {
+	bristol := &database.Address{Town: "Bristol"}
+	return []database.Roast{
+		{RoastID: "a", PriceRange: 1, Address: bristol, ReviewCount: 12, OverallRating: 8.5, GravyRating: 9, PhotoCount: 3,
+			Tags:  []string{"vegetarian", "unlimited-yorkshires"},
+			Venue: &database.Venue{Schedule: []database.ServingWindow{{Day: "sunday", Opens: "12:00", Closes: "16:00"}}}},
+		{RoastID: "b", PriceRange: 2, Address: bristol, ReviewCount: 3, OverallRating: 6, GravyRating: 7, Tags: []string{"vegetarian"},
+			Venue: &database.Venue{Schedule: []database.ServingWindow{{Day: "saturday", Opens: "12:00", Closes: "16:00"}}}},
+		{RoastID: "c", PriceRange: 2, Address: &database.Address{Town: "Bath"}, ReviewCount: 6, OverallRating: 7.5, GravyRating: 5,
+			PhotoCount: 1},
+		{RoastID: "d", PriceRange: 3},
+	}
+}
+
+func ids(roasts []database.Roast) []string {
+	result := []string{}
+	for _, roast := range roasts {
+		result = append(result, roast.RoastID)
+	}
+	return result
+}
+
+func TestFilterApply(t *testing.T) {
+	testCases := []struct {
+		name     string
+		filter   Filter
+		expected []string
+	}{
+		{"None", Filter{}, []string{"a", "b", "c", "d"}},
+		{"Price", Filter{MinPrice: 2, MaxPrice: 2}, []string{"b", "c"}},
+		{"Town", Filter{Town: " bristol"}, []string{"a", "b"}},
+		{"MinReviews", Filter{MinReviews: 5}, []string{"a", "c"}},
+		{"MinRatings", Filter{MinRatings: map[string]float64{ratings.CriterionOverall: 7, ratings.CriterionGravy: 6}}, []string{"a"}},
+		{"Tags", Filter{Tags: []string{"Vegetarian", "unlimited-yorkshires"}}, []string{"a"}},
+		{"HasPhotos", Filter{HasPhotos: true}, []string{"a", "c"}},
+		{"ServedToday", Filter{ServedToday: true, Now: sundayMorning}, []string{"a"}},
+		{"OpenNow", Filter{OpenNow: true, Now: sundayMorning}, []string{}},
+		{"OpenNowAtLunch", Filter{OpenNow: true, Now: sundayLunch}, []string{"a"}},
+		{"Combined", Filter{Town: "bristol", MaxPrice: 1, Tags: []string{"vegetarian"}}, []string{"a"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := ids(tc.filter.Apply(testRoasts()))
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Apply() = %v; want %v", result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestFilterScan(t *testing.T) {
+	testCases := []struct {
+		name     string
+		filter   Filter
+		expected database.RoastScan
+	}{
+		{"None", Filter{Town: "bristol", Tags: []string{"vegetarian"}}, database.RoastScan{}},
+		{"Pushed", Filter{MinPrice: 1, MaxPrice: 2, MinReviews: 5, HasPhotos: true}, database.RoastScan{MinPrice: 1, MaxPrice: 2, MinReviews: 5, HasPhotos: true}},
+		{"Reviewed", Filter{Reviewed: true}, database.RoastScan{MinReviews: 1}},
+		{"ReviewedWithMinimum", Filter{Reviewed: true, MinReviews: 3}, database.RoastScan{MinReviews: 3}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if scan := tc.filter.Scan(); scan != tc.expected {
+				t.Errorf("Scan() = %+v; want %+v", scan, tc.expected)
+			}
+		})
+	}
+}
+
+func TestApplyWithFacets(t *testing.T) {
+	filter := Filter{Town: "Bristol", MinPrice: 2}
+	filtered, facets := filter.ApplyWithFacets(testRoasts())
+
+	if result := ids(filtered); !reflect.DeepEqual(result, []string{"b"}) {
+		t.Errorf("filtered = %v; want [b]", result)
+	}
+	// Each filter is counted with only the others applied, so other values can be chosen
+	if expected := map[int]int{1: 1, 2: 1}; !reflect.DeepEqual(facets.Price, expected) {
+		t.Errorf("Price = %v; want %v", facets.Price, expected)
+	}
+	if expected := map[string]int{"Bristol": 1, "Bath": 1}; !reflect.DeepEqual(facets.Towns, expected) {
+		t.Errorf("Towns = %v; want %v", facets.Towns, expected)
+	}
+	// Tags are only counted from the matching roasts
+	if expected := map[string]int{"vegetarian": 1}; !reflect.DeepEqual(facets.Tags, expected) {
+		t.Errorf("Tags = %v; want %v", facets.Tags, expected)
+	}
+	if facets.HasPhotos != 0 {
+		t.Errorf("HasPhotos = %v; want 0", facets.HasPhotos)
+	}
+	if expected := map[int]int{1: 1}; !reflect.DeepEqual(facets.MinReviews, expected) {
+		t.Errorf("MinReviews = %v; want %v", facets.MinReviews, expected)
+	}
+	if expected := map[int]int{5: 1, 6: 1, 7: 1}; !reflect.DeepEqual(facets.MinRatings[ratings.CriterionGravy], expected) {
+		t.Errorf("MinRatings[gravy] = %v; want %v", facets.MinRatings[ratings.CriterionGravy], expected)
+	}
+
+	// With a rating filter its own counts ignore it but the others respect it
+	filter = Filter{MinRatings: map[string]float64{ratings.CriterionOverall: 7}}
+	_, facets = filter.ApplyWithFacets(testRoasts())
+	if expected := map[int]int{5: 3, 6: 3, 7: 2, 8: 1}; !reflect.DeepEqual(facets.MinRatings[ratings.CriterionOverall], expected) {
+		t.Errorf("MinRatings[overall] = %v; want %v", facets.MinRatings[ratings.CriterionOverall], expected)
+	}
+	if expected := map[int]int{1: 1, 2: 1}; !reflect.DeepEqual(facets.Price, expected) {
+		t.Errorf("Price with rating filter = %v; want %v", facets.Price, expected)
+	}
+
+	// Serving is only counted with a calendar, from the roasts matching the other filters
+	filter = Filter{ServedToday: true, MinPrice: 2, Now: sundayLunch, Calendar: &venues.Calendar{}}
+	filtered, facets = filter.ApplyWithFacets(testRoasts())
+	if result := ids(filtered); !reflect.DeepEqual(result, []string{}) {
+		t.Errorf("filtered served today = %v; want []", result)
+	}
+	if facets.ServedToday != 0 || facets.OpenNow != 0 {
+		t.Errorf("ServedToday, OpenNow = %v, %v; want 0, 0", facets.ServedToday, facets.OpenNow)
+	}
+	filter.MinPrice = 0
+	if _, facets = filter.ApplyWithFacets(testRoasts()); facets.ServedToday != 1 || facets.OpenNow != 1 {
+		t.Errorf("ServedToday, OpenNow = %v, %v; want 1, 1", facets.ServedToday, facets.OpenNow)
+	}
+}
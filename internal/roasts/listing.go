@@ -0,0 +1,197 @@
+package roasts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/ratings"
+	"github.com/94DanielBrown/roasts-api/internal/venues"
+)
+
+// Sort orders accepted by the roast listing
+const (
+	SortRanking = "ranking"
+	SortRating  = "rating"
+	SortReviews = "reviews"
+	SortName    = "name"
+	SortRecent  = "recent"
+	SortCurrent = "current"
+	SortValue   = "value"
+	// SortDistance is nearest first, for proximity searches
+	SortDistance = "distance"
+)
+
+// Sort orders roasts in place, highest first for everything but name and distance
+func Sort(roasts []database.Roast, by string) error {
+	var less func(a, b database.Roast) bool
+	switch by {
+	case SortRanking:
+		less = func(a, b database.Roast) bool { return a.RankingScore > b.RankingScore }
+	case SortRating:
+		less = func(a, b database.Roast) bool { return a.OverallRating > b.OverallRating }
+	case SortRecent:
+		less = func(a, b database.Roast) bool { return a.RecentRating > b.RecentRating }
+	case SortCurrent:
+		less = func(a, b database.Roast) bool { return a.CurrentRating > b.CurrentRating }
+	case SortValue:
+		less = func(a, b database.Roast) bool { return a.ValueScore > b.ValueScore }
+	case SortReviews:
+		less = func(a, b database.Roast) bool { return a.ReviewCount > b.ReviewCount }
+	case SortDistance:
+		less = func(a, b database.Roast) bool { return a.Distance < b.Distance }
+	case SortName:
+		less = func(a, b database.Roast) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) }
+	default:
+		return fmt.Errorf("unknown sort %q", by)
+	}
+
+	sort.SliceStable(roasts, func(i, j int) bool { return less(roasts[i], roasts[j]) })
+	return nil
+}
+
+// Filter narrows down the roast listing, zero values don't filter
+type Filter struct {
+	// Location matches roasts whose location contains it, ignoring case
+	Location string
+	// Town matches roasts whose address is in the town, ignoring case
+	Town     string
+	MinPrice int
+	MaxPrice int
+	// Reviewed only keeps roasts that have at least one review
+	Reviewed bool
+	// MinReviews only keeps roasts with at least this many reviews
+	MinReviews int
+	// MinRatings only keeps roasts rated at least this on average for each criterion it has
+	MinRatings map[string]float64
+	// Tags only keeps roasts that have every one of the tags
+	Tags []string
+	// HasPhotos only keeps roasts whose reviews have photos
+	HasPhotos bool
+	// MinValue only keeps roasts with at least this value score
+	MinValue float64
+	// ServedToday only keeps roasts served at some time on the date of Now in their timezone, and OpenNow
+	// those being served at Now, with the bank holidays and closures of Calendar
+	ServedToday bool
+	OpenNow     bool
+	Now         time.Time
+	Calendar    *venues.Calendar
+}
+
+// Each filter's bit in the set of filters a roast doesn't match. The rating filters take a bit for each
+// criterion starting at checkRating, in the order of ratings.Criteria.
+const (
+	checkLocation uint = 1 << iota
+	checkTown
+	checkPrice
+	checkReviews
+	checkTags
+	checkPhotos
+	checkValue
+	checkServedToday
+	checkOpenNow
+	checkRating
+)
+
+// Apply returns the roasts matching the filter
+func (f Filter) Apply(roasts []database.Roast) []database.Roast {
+	f = f.normalised()
+	filtered := []database.Roast{}
+	for _, roast := range roasts {
+		if f.failed(roast) == 0 {
+			filtered = append(filtered, roast)
+		}
+	}
+	return filtered
+}
+
+// Scan returns the predicates of the filter that can be checked by the scan of roasts, so fewer are returned
+// to be filtered. Apply still has to be used, as the rest can't be. Towns and locations are matched ignoring
+// case, which filter expressions can't do, and ratings, tags, value scores and serving times are either
+// worked out when roasts are returned or can't be compared in one.
+func (f Filter) Scan() database.RoastScan {
+	scan := database.RoastScan{MinPrice: f.MinPrice, MaxPrice: f.MaxPrice, MinReviews: f.MinReviews, HasPhotos: f.HasPhotos}
+	if f.Reviewed {
+		scan.MinReviews = max(scan.MinReviews, 1)
+	}
+	return scan
+}
+
+// normalised lower cases the text filters so they're only lower cased once
+func (f Filter) normalised() Filter {
+	f.Location = strings.ToLower(strings.TrimSpace(f.Location))
+	f.Town = strings.ToLower(strings.TrimSpace(f.Town))
+	tags := make([]string, 0, len(f.Tags))
+	for _, tag := range f.Tags {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	f.Tags = tags
+	return f
+}
+
+// failed returns the set of filters the roast doesn't match. The filter must be normalised.
+func (f Filter) failed(roast database.Roast) uint {
+	var failed uint
+	if f.Location != "" && !strings.Contains(strings.ToLower(roast.Location), f.Location) {
+		failed |= checkLocation
+	}
+	if f.Town != "" && strings.ToLower(town(roast)) != f.Town {
+		failed |= checkTown
+	}
+	if (f.MinPrice != 0 && roast.PriceRange < f.MinPrice) || (f.MaxPrice != 0 && roast.PriceRange > f.MaxPrice) {
+		failed |= checkPrice
+	}
+	if (f.Reviewed && roast.ReviewCount == 0) || roast.ReviewCount < f.MinReviews {
+		failed |= checkReviews
+	}
+	if !hasTags(roast, f.Tags) {
+		failed |= checkTags
+	}
+	if f.HasPhotos && roast.PhotoCount == 0 {
+		failed |= checkPhotos
+	}
+	if f.MinValue != 0 && roast.ValueScore < f.MinValue {
+		failed |= checkValue
+	}
+	if f.ServedToday && !f.Calendar.ServedOn(&roast, f.Now) {
+		failed |= checkServedToday
+	}
+	if f.OpenNow && !f.Calendar.Serving(&roast, f.Now) {
+		failed |= checkOpenNow
+	}
+	for i, criterion := range ratings.Criteria {
+		if minRating, ok := f.MinRatings[criterion]; ok && ratings.Rating(roast, criterion) < minRating {
+			failed |= checkRating << i
+		}
+	}
+	return failed
+}
+
+// town is the town in a roast's address, if it has one
+func town(roast database.Roast) string {
+	if roast.Address == nil {
+		return ""
+	}
+	return strings.TrimSpace(roast.Address.Town)
+}
+
+// hasTags reports whether the roast has every one of the lower cased tags
+func hasTags(roast database.Roast, tags []string) bool {
+	for _, tag := range tags {
+		found := false
+		for _, roastTag := range roast.Tags {
+			if strings.EqualFold(roastTag, tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
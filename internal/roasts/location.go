@@ -0,0 +1,91 @@
+package roasts
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/geo"
+)
+
+const (
+	// DefaultRadiusKm is the radius of a proximity search when none is given
+	DefaultRadiusKm = 10
+	// maxCellQueries is how many geo index cells a proximity search queries at once
+	maxCellQueries = 8
+)
+
+// ErrInvalidRadius is returned for a proximity search radius that's out of range
+var ErrInvalidRadius = fmt.Errorf("radius must be more than 0 and at most %dkm", geo.MaxRadiusKm)
+
+// SetLocation keys the roast in the geo index from its address, removing it from the index if the address
+// has no coordinates. The free-text location is filled in from the address for clients that only show that.
+func SetLocation(roast *database.Roast) error {
+	roast.GeoCell, roast.Geohash = "", ""
+	if roast.Address == nil {
+		return nil
+	}
+	if roast.Location == "" {
+		roast.Location = roast.Address.Town
+	}
+	if !roast.Address.Located() {
+		return nil
+	}
+
+	point := geo.Point{Lat: roast.Address.Lat, Lng: roast.Address.Lng}
+	if err := point.Validate(); err != nil {
+		return err
+	}
+	roast.Geohash = geo.Encode(point, geo.HashPrecision)
+	roast.GeoCell = roast.Geohash[:geo.CellPrecision]
+	return nil
+}
+
+// Near returns the roasts within radiusKm of center, nearest first, with their distance set
+func Near(roastModels database.RoastModels, center geo.Point, radiusKm float64) ([]database.Roast, error) {
+	if radiusKm <= 0 || radiusKm > geo.MaxRadiusKm {
+		return nil, ErrInvalidRadius
+	}
+	cells, err := geo.Cover(center, radiusKm)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each cell is a query, run a few at a time
+	inCells := make([][]database.Roast, len(cells))
+	errs := make([]error, len(cells))
+	sem := make(chan struct{}, maxCellQueries)
+	var wg sync.WaitGroup
+	for i, cell := range cells {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, cell string) {
+			defer wg.Done()
+			inCells[i], errs[i] = roastModels.GetRoastsInCell(cell)
+			<-sem
+		}(i, cell)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	nearby := []database.Roast{}
+	for _, roasts := range inCells {
+		for _, roast := range roasts {
+			if !roast.Address.Located() {
+				continue
+			}
+			// Cells cover a box around the circle, so roasts in its corners are dropped
+			roast.Distance = geo.Distance(center, geo.Point{Lat: roast.Address.Lat, Lng: roast.Address.Lng})
+			if roast.Distance <= radiusKm {
+				nearby = append(nearby, roast)
+			}
+		}
+	}
+
+	sort.SliceStable(nearby, func(i, j int) bool { return nearby[i].Distance < nearby[j].Distance })
+	return nearby, nil
+}
@@ -0,0 +1,243 @@
+package roasts
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/ratings"
+)
+
+var (
+	ErrSameRoast     = errors.New("a roast can't be merged into itself")
+	ErrRoastNotFound = errors.New("roast not found")
+	// ErrReviewsLeft is returned if the source still has reviews once they've been moved, such as one written
+	// during the merge, so it isn't deleted from under them. Running the merge again moves them.
+	ErrReviewsLeft = errors.New("source roast still has reviews")
+)
+
+// MergeResult describes merging one roast into another, or what merging them would do
+type MergeResult struct {
+	SourceID string `json:"sourceID"`
+	TargetID string `json:"targetID"`
+	// Reviews are the keys of the source's reviews that move to the target
+	Reviews []string `json:"reviews"`
+	// Users are the IDs of the users whose saved source roast becomes the target
+	Users []string `json:"users"`
+	// Tags are the source's tags the target gains
+	Tags []string `json:"tags"`
+	// Prices are the source's price history, which moves to the target's except for the records of an
+	// item and date the target already has, which are deleted
+	Prices []database.PriceRecord `json:"prices"`
+	// TagSuggestions are the source's pending tag suggestions, which move to the target unless it already
+	// has the tag or the same suggestion, in which case they're deleted
+	TagSuggestions []database.TagSuggestion `json:"tagSuggestions"`
+	// Target is the target roast with its aggregates as they are once the reviews have moved
+	Target database.Roast `json:"target"`
+	// Merged is false for a dry run
+	Merged bool `json:"merged"`
+}
+
+// Merge merges a duplicate roast into the roast it duplicates. The source's reviews, price history and tag
+// suggestions move to the target, users who saved the source have saved the target instead and the
+// target's aggregates are rebuilt. The
+// source roast is removed, leaving a redirect to the target so its ID still resolves. Nothing is changed
+// unless apply is true.
+//
+// A merge that fails part way through can be run again to finish it, as everything done so far has
+// been made to the target or repoints to it.
+func Merge(roastModels database.RoastModels, reviewModels database.ReviewModels, userModels database.UserModels, tagModels database.TagModels, sourceID, targetID string, settings ratings.Settings, apply bool) (MergeResult, error) {
+	result := MergeResult{SourceID: sourceID, TargetID: targetID, Reviews: []string{}, Users: []string{}, Tags: []string{}}
+	if sourceID == targetID {
+		return result, ErrSameRoast
+	}
+	source, err := getRoast(roastModels, sourceID)
+	if err != nil {
+		return result, err
+	}
+	target, err := getRoast(roastModels, targetID)
+	if err != nil {
+		return result, err
+	}
+
+	sourceReviews, err := reviewModels.GetReviewsByRoast(source.RoastKey)
+	if err != nil {
+		return result, fmt.Errorf("error getting reviews of %s: %w", sourceID, err)
+	}
+	targetReviews, err := reviewModels.GetReviewsByRoast(target.RoastKey)
+	if err != nil {
+		return result, fmt.Errorf("error getting reviews of %s: %w", targetID, err)
+	}
+	users, err := userModels.GetUsersWithSavedRoast(sourceID)
+	if err != nil {
+		return result, fmt.Errorf("error getting users who saved %s: %w", sourceID, err)
+	}
+	if result.Prices, err = roastModels.GetPriceHistory(sourceID); err != nil {
+		return result, fmt.Errorf("error getting price history of %s: %w", sourceID, err)
+	}
+	targetPrices, err := roastModels.GetPriceHistory(targetID)
+	if err != nil {
+		return result, fmt.Errorf("error getting price history of %s: %w", targetID, err)
+	}
+	if result.TagSuggestions, err = tagModels.GetRoastTagSuggestions(sourceID); err != nil {
+		return result, fmt.Errorf("error getting tag suggestions of %s: %w", sourceID, err)
+	}
+	targetSuggestions, err := tagModels.GetRoastTagSuggestions(targetID)
+	if err != nil {
+		return result, fmt.Errorf("error getting tag suggestions of %s: %w", targetID, err)
+	}
+
+	merged := slices.Clone(targetReviews)
+	for _, review := range sourceReviews {
+		result.Reviews = append(result.Reviews, review.ReviewKey)
+		review.RoastKey, review.RoastID = target.RoastKey, target.RoastID
+		merged = append(merged, review)
+	}
+	for _, user := range users {
+		result.Users = append(result.Users, userID(user))
+	}
+	for _, tag := range source.Tags {
+		if !slices.Contains(target.Tags, tag) {
+			result.Tags = append(result.Tags, tag)
+		}
+	}
+	target.Tags = append(target.Tags, result.Tags...)
+	result.Target = ratings.Rebuilt(*target, merged, settings)
+	ratings.SetRankingScore(&result.Target, settings)
+	if !apply {
+		return result, nil
+	}
+
+	for _, review := range sourceReviews {
+		if err := reviewModels.MoveReview(review, target); err != nil {
+			return result, err
+		}
+	}
+	for _, user := range users {
+		user.SavedRoasts = ReplaceSavedRoast(user.SavedRoasts, sourceID, targetID)
+		if err := userModels.UpdateUser(user); err != nil {
+			return result, fmt.Errorf("error updating saved roasts of %s: %w", userID(user), err)
+		}
+	}
+	if len(result.Tags) > 0 {
+		if err := roastModels.SetRoastTags(target, target.Tags); err != nil {
+			return result, fmt.Errorf("error saving tags of %s: %w", targetID, err)
+		}
+	}
+	if _, err := ratings.Recompute(roastModels, reviewModels, targetID, settings, true); err != nil {
+		return result, fmt.Errorf("error recomputing %s: %w", targetID, err)
+	}
+	if err := mergePrices(roastModels, result.Prices, targetPrices, targetID); err != nil {
+		return result, err
+	}
+	if err := mergeTagSuggestions(tagModels, result.TagSuggestions, targetSuggestions, target); err != nil {
+		return result, err
+	}
+
+	left, err := reviewModels.GetReviewsByRoast(source.RoastKey)
+	if err != nil {
+		return result, fmt.Errorf("error checking reviews of %s: %w", sourceID, err)
+	}
+	if len(left) > 0 {
+		return result, fmt.Errorf("%w: %s has %d", ErrReviewsLeft, sourceID, len(left))
+	}
+
+	// With everything else moved only the source's profile and trends are left, the trends now count towards the target's
+	trends, err := roastModels.GetTrends(source.RoastKey, "")
+	if err != nil {
+		return result, fmt.Errorf("error getting trends of %s: %w", sourceID, err)
+	}
+	for _, trend := range trends {
+		if err := roastModels.DeleteTrend(source.RoastKey, trend.Month); err != nil {
+			return result, fmt.Errorf("error removing trends of %s: %w", sourceID, err)
+		}
+	}
+	// The redirect goes in first so the source's ID never stops resolving
+	if err := roastModels.PutRedirect(sourceID, targetID); err != nil {
+		return result, fmt.Errorf("error saving redirect: %w", err)
+	}
+	if err := roastModels.DeleteRoast(sourceID); err != nil {
+		return result, fmt.Errorf("error removing %s: %w", sourceID, err)
+	}
+
+	result.Merged = true
+	return result, nil
+}
+
+// mergePrices moves the source's price records into the target's price history, deleting those of an item
+// and date the target already has a price for
+func mergePrices(roastModels database.RoastModels, prices, targetPrices []database.PriceRecord, targetID string) error {
+	for _, record := range prices {
+		var err error
+		if hasPriceRecord(targetPrices, record) {
+			err = roastModels.DeletePriceRecord(record)
+		} else {
+			err = roastModels.MovePriceRecord(record, targetID)
+		}
+		if err != nil {
+			return fmt.Errorf("error merging price of %s: %w", record.Item, err)
+		}
+	}
+	return nil
+}
+
+// mergeTagSuggestions moves the source's tag suggestions to the target, deleting those the target
+// already has the tag or the same suggestion for
+func mergeTagSuggestions(tagModels database.TagModels, suggestions, targetSuggestions []database.TagSuggestion, target *database.Roast) error {
+	for _, suggestion := range suggestions {
+		var err error
+		if slices.Contains(target.Tags, suggestion.Tag) || hasTagSuggestion(targetSuggestions, suggestion) {
+			err = tagModels.DeleteTagSuggestion(suggestion)
+		} else {
+			err = tagModels.MoveTagSuggestion(suggestion, target.RoastID)
+		}
+		if err != nil {
+			return fmt.Errorf("error merging suggestion of %s: %w", suggestion.Tag, err)
+		}
+	}
+	return nil
+}
+
+func hasPriceRecord(records []database.PriceRecord, record database.PriceRecord) bool {
+	return slices.ContainsFunc(records, func(r database.PriceRecord) bool {
+		return r.Item == record.Item && r.EffectiveFrom == record.EffectiveFrom
+	})
+}
+
+func hasTagSuggestion(suggestions []database.TagSuggestion, suggestion database.TagSuggestion) bool {
+	return slices.ContainsFunc(suggestions, func(s database.TagSuggestion) bool {
+		return s.Tag == suggestion.Tag && s.UserID == suggestion.UserID
+	})
+}
+
+// ReplaceSavedRoast replaces oldID in a user's saved roasts with newID, unless they've saved both
+func ReplaceSavedRoast(saved []string, oldID, newID string) []string {
+	replaced := make([]string, 0, len(saved))
+	for _, id := range saved {
+		if id == oldID {
+			id = newID
+		}
+		if !slices.Contains(replaced, id) {
+			replaced = append(replaced, id)
+		}
+	}
+	return replaced
+}
+
+func getRoast(roastModels database.RoastModels, roastID string) (*database.Roast, error) {
+	roast, err := roastModels.GetRoastByPrefix("ROAST#" + roastID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting %s: %w", roastID, err)
+	}
+	if roast == nil {
+		return nil, fmt.Errorf("%w: %s", ErrRoastNotFound, roastID)
+	}
+	return roast, nil
+}
+
+// userID is the ID in a user's key, USER#<id>
+func userID(user database.User) string {
+	return strings.TrimPrefix(user.UserKey, "USER#")
+}
@@ -0,0 +1,26 @@
+package roasts
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReplaceSavedRoast(t *testing.T) {
+	testCases := []struct {
+		name     string
+		saved    []string
+		expected []string
+	}{
+		{"Replaced", []string{"Crown", "RedLion", "Plough"}, []string{"Crown", "TheRedLion", "Plough"}},
+		{"SavedBoth", []string{"TheRedLion", "Crown", "RedLion"}, []string{"TheRedLion", "Crown"}},
+		{"NotSaved", []string{"Crown"}, []string{"Crown"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := ReplaceSavedRoast(tc.saved, "RedLion", "TheRedLion"); !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("ReplaceSavedRoast(%v) = %v; want %v", tc.saved, result, tc.expected)
+			}
+		})
+	}
+}
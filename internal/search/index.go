@@ -0,0 +1,386 @@
+// Package search is an in-process full-text index over roast names, locations and review comments. It's small
+// enough to rebuild from the table at startup and is kept up to date by the handlers that write roasts and reviews.
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+	"github.com/94DanielBrown/roasts-api/internal/utils"
+)
+
+// How much an occurrence of a term counts in each field
+const (
+	nameWeight     = 3
+	locationWeight = 2
+	commentWeight  = 1
+)
+
+const (
+	// prefixWeight and typoWeight discount terms matched by autocomplete or typo tolerance against exact ones
+	prefixWeight = 0.8
+	typoWeight   = 0.6
+	// reviewWeight is how much a matching review adds to its roast's score
+	reviewWeight = 0.5
+	// maxExpansions caps the terms a prefix can match so a single letter doesn't match the whole vocabulary
+	maxExpansions = 50
+	// MaxSnippets is the most review snippets returned for each roast, from its best matching reviews
+	MaxSnippets = 3
+	// snippetContext is roughly how many bytes of a comment are shown either side of the first match
+	snippetContext = 60
+)
+
+// Result is a roast matching a search and the reviews of it that matched
+type Result struct {
+	RoastID  string    `json:"roastID"`
+	Score    float64   `json:"score"`
+	Snippets []Snippet `json:"snippets,omitempty"`
+}
+
+// Snippet is an excerpt of a matching review's comment around the first match
+type Snippet struct {
+	ReviewKey string `json:"reviewKey"`
+	Text      string `json:"text"`
+}
+
+// Index is safe for concurrent use
+type Index struct {
+	mu   sync.RWMutex
+	docs map[string]*document
+	// postings maps each term to the documents it's in and its weighted frequency in each
+	postings map[string]map[string]float64
+	// terms is the vocabulary in order, for prefix matching
+	terms []string
+	// reviews are the IDs of each roast's review documents, so they're removed with the roast
+	reviews map[string]map[string]bool
+}
+
+// document is a roast or a review's comment
+type document struct {
+	roastID string
+	// reviewKey and text are only set for reviews
+	reviewKey string
+	text      string
+	terms     map[string]float64
+}
+
+// New returns an empty index
+func New() *Index {
+	return &Index{
+		docs:     map[string]*document{},
+		postings: map[string]map[string]float64{},
+		reviews:  map[string]map[string]bool{},
+	}
+}
+
+// Load replaces the contents of the index with every roast and review in the table
+func (ix *Index) Load(roastModels database.RoastModels, reviewModels database.ReviewModels) error {
+	roasts, err := roastModels.GetAllRoasts()
+	if err != nil {
+		return err
+	}
+	reviews, err := reviewModels.GetAllReviews()
+	if err != nil {
+		return err
+	}
+
+	// The new index is built aside so searches carry on against the old one until it's swapped in
+	rebuilt := New()
+	for _, roast := range roasts {
+		rebuilt.PutRoast(roast)
+	}
+	for _, review := range reviews {
+		rebuilt.PutReview(review)
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.docs, ix.postings, ix.terms, ix.reviews = rebuilt.docs, rebuilt.postings, rebuilt.terms, rebuilt.reviews
+	return nil
+}
+
+// PutRoast indexes a roast's name and location, replacing what was indexed for it before
+func (ix *Index) PutRoast(roast database.Roast) {
+	terms := map[string]float64{}
+	addTerms(terms, roast.Name, nameWeight)
+	addTerms(terms, roast.Location, locationWeight)
+	if roast.Address != nil {
+		// The location is usually filled in from the town, which shouldn't count twice
+		if !strings.Contains(strings.ToLower(roast.Location), strings.ToLower(roast.Address.Town)) {
+			addTerms(terms, roast.Address.Town, locationWeight)
+		}
+		addTerms(terms, roast.Address.Postcode, locationWeight)
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.put(roast.RoastID, &document{roastID: roast.RoastID, terms: terms})
+}
+
+// RemoveRoast removes a roast and its reviews from the index
+func (ix *Index) RemoveRoast(roastID string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(roastID)
+	for id := range ix.reviews[roastID] {
+		ix.remove(id)
+	}
+	delete(ix.reviews, roastID)
+}
+
+// PutReview indexes a review's comment, replacing what was indexed for it before
+func (ix *Index) PutReview(review database.Review) {
+	id := reviewID(review.RoastID, review.ReviewKey)
+	terms := map[string]float64{}
+	addTerms(terms, review.Comment, commentWeight)
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if len(terms) == 0 {
+		ix.removeReview(review.RoastID, id)
+		return
+	}
+	ix.put(id, &document{roastID: review.RoastID, reviewKey: review.ReviewKey, text: review.Comment, terms: terms})
+	if ix.reviews[review.RoastID] == nil {
+		ix.reviews[review.RoastID] = map[string]bool{}
+	}
+	ix.reviews[review.RoastID][id] = true
+}
+
+// RemoveReview removes a review from the index
+func (ix *Index) RemoveReview(roastID, reviewKey string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.removeReview(roastID, reviewID(roastID, reviewKey))
+}
+
+// Search returns the roasts matching query, best first, with snippets of their matching reviews. The last
+// word of the query also matches terms it's the start of unless the query ends in a space, so it can be
+// used for autocomplete. Words that don't match anything match terms a typo or two away.
+func (ix *Index) Search(query string, limit int) []Result {
+	tokens := tokenize(query)
+	if len(tokens) == 0 {
+		return []Result{}
+	}
+	last := len(tokens) - 1
+	partial := tokens[last].end == len(query)
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	// Each document's score for each query token, and the terms it matched for highlighting
+	scores := map[string][]float64{}
+	matched := map[string]map[string]bool{}
+	for i, tok := range tokens {
+		for term, weight := range ix.expand(tok, partial && i == last) {
+			docs := ix.postings[term]
+			idf := math.Log(1 + float64(len(ix.docs))/float64(len(docs)))
+			for id, frequency := range docs {
+				if scores[id] == nil {
+					scores[id] = make([]float64, len(tokens))
+					matched[id] = map[string]bool{}
+				}
+				scores[id][i] = max(scores[id][i], weight*frequency*idf)
+				matched[id][term] = true
+			}
+		}
+	}
+
+	type roastMatch struct {
+		score   float64
+		tokens  []bool
+		reviews []string
+		// reviewScores are the scores of the review documents in reviews
+		reviewScores map[string]float64
+	}
+	matches := map[string]*roastMatch{}
+	for id, tokenScores := range scores {
+		doc := ix.docs[id]
+		m := matches[doc.roastID]
+		if m == nil {
+			m = &roastMatch{tokens: make([]bool, len(tokens)), reviewScores: map[string]float64{}}
+			matches[doc.roastID] = m
+		}
+		var score float64
+		for i, s := range tokenScores {
+			score += s
+			m.tokens[i] = m.tokens[i] || s > 0
+		}
+		if doc.reviewKey == "" {
+			m.score += score
+		} else {
+			m.reviews = append(m.reviews, id)
+			m.reviewScores[id] = score
+		}
+	}
+
+	results := make([]Result, 0, len(matches))
+	for roastID, m := range matches {
+		// Only the best reviews count so a roast isn't ranked on how many reviews it has
+		sort.Slice(m.reviews, func(i, j int) bool {
+			a, b := m.reviews[i], m.reviews[j]
+			if m.reviewScores[a] != m.reviewScores[b] {
+				return m.reviewScores[a] > m.reviewScores[b]
+			}
+			return a < b
+		})
+		result := Result{RoastID: roastID, Score: m.score}
+		for _, id := range m.reviews[:min(len(m.reviews), MaxSnippets)] {
+			doc := ix.docs[id]
+			result.Score += reviewWeight * m.reviewScores[id]
+			result.Snippets = append(result.Snippets, Snippet{ReviewKey: doc.reviewKey, Text: snippet(doc.text, matched[id])})
+		}
+
+		// Roasts matching every word of the query rank above those matching some of them
+		covered := 0
+		for _, ok := range m.tokens {
+			if ok {
+				covered++
+			}
+		}
+		coverage := float64(covered) / float64(len(tokens))
+		result.Score *= coverage * coverage
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].RoastID < results[j].RoastID
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// expand returns the indexed terms a query token matches and how much each counts
+func (ix *Index) expand(tok token, partial bool) map[string]float64 {
+	terms := map[string]float64{}
+	if _, ok := ix.postings[tok.term]; ok {
+		terms[tok.term] = 1
+	}
+	if partial {
+		// The word may not have been finished so isn't stemmed yet
+		for _, prefix := range []string{tok.word, tok.term} {
+			expansions := 0
+			for i := sort.SearchStrings(ix.terms, prefix); i < len(ix.terms) && expansions < maxExpansions; i++ {
+				if !strings.HasPrefix(ix.terms[i], prefix) {
+					break
+				}
+				if _, ok := terms[ix.terms[i]]; !ok {
+					terms[ix.terms[i]] = prefixWeight
+				}
+				expansions++
+			}
+		}
+	}
+	if len(terms) > 0 {
+		return terms
+	}
+
+	if limit := maxTypos(tok.term); limit > 0 {
+		for _, term := range ix.terms {
+			if utils.EditDistance(tok.term, term, limit+1) <= limit {
+				terms[term] = typoWeight
+			}
+		}
+	}
+	return terms
+}
+
+// put indexes a document under id, replacing any document already there. The lock must be held.
+func (ix *Index) put(id string, doc *document) {
+	ix.remove(id)
+	ix.docs[id] = doc
+	for term, frequency := range doc.terms {
+		if ix.postings[term] == nil {
+			ix.postings[term] = map[string]float64{}
+			i := sort.SearchStrings(ix.terms, term)
+			ix.terms = append(ix.terms, "")
+			copy(ix.terms[i+1:], ix.terms[i:])
+			ix.terms[i] = term
+		}
+		ix.postings[term][id] = frequency
+	}
+}
+
+// remove removes the document with id if it's indexed. The lock must be held.
+func (ix *Index) remove(id string) {
+	doc, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	delete(ix.docs, id)
+	for term := range doc.terms {
+		delete(ix.postings[term], id)
+		if len(ix.postings[term]) == 0 {
+			delete(ix.postings, term)
+			i := sort.SearchStrings(ix.terms, term)
+			ix.terms = append(ix.terms[:i], ix.terms[i+1:]...)
+		}
+	}
+}
+
+// removeReview removes a review document and forgets it belongs to its roast. The lock must be held.
+func (ix *Index) removeReview(roastID, id string) {
+	ix.remove(id)
+	delete(ix.reviews[roastID], id)
+	if len(ix.reviews[roastID]) == 0 {
+		delete(ix.reviews, roastID)
+	}
+}
+
+func reviewID(roastID, reviewKey string) string {
+	return roastID + "|" + reviewKey
+}
+
+// addTerms adds the weighted frequency of each term in text
+func addTerms(terms map[string]float64, text string, weight float64) {
+	for _, tok := range tokenize(text) {
+		terms[tok.term] += weight
+	}
+}
+
+// snippet cuts text down to the words around the first of terms in it, or its start if none are
+func snippet(text string, terms map[string]bool) string {
+	from, to, around := 0, 0, 2*snippetContext
+	for _, tok := range tokenize(text) {
+		if terms[tok.term] {
+			from, to, around = tok.start, tok.end, snippetContext
+			break
+		}
+	}
+
+	// The cut is moved to the nearest space inside it so words aren't split
+	start := from - around
+	if start <= 0 {
+		start = 0
+	} else if i := strings.IndexFunc(text[start:from], unicode.IsSpace); i >= 0 {
+		start += i + 1
+	} else {
+		start = from
+	}
+	end := to + around
+	if end >= len(text) {
+		end = len(text)
+	} else if i := strings.LastIndexFunc(text[to:end], unicode.IsSpace); i >= 0 {
+		end = to + i
+	} else {
+		end = to
+	}
+
+	excerpt := strings.TrimSpace(text[start:end])
+	if start > 0 {
+		excerpt = "…" + excerpt
+	}
+	if end < len(text) {
+		excerpt += "…"
+	}
+	return excerpt
+}
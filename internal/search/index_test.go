@@ -0,0 +1,113 @@
+package search
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+)
+
+func testIndex() *Index {
+	ix := New()
+	ix.PutRoast(database.Roast{RoastID: "kings", Name: "The Kings Arms", Location: "Bristol"})
+	ix.PutRoast(database.Roast{RoastID: "crown", Name: "The Crown", Location: "Bath",
+		Address: &database.Address{Town: "Bath", Postcode: "BA1 1AA"}})
+	ix.PutRoast(database.Roast{RoastID: "anchor", Name: "The Anchor", Location: "Bristol harbourside"})
+	ix.PutReview(database.Review{RoastID: "crown", ReviewKey: "REVIEW#u1", Comment: "Lovely crispy potatoes " +
+		"but the gravy was far too thin and the beef had been sitting under a heat lamp for a long while"})
+	ix.PutReview(database.Review{RoastID: "crown", ReviewKey: "REVIEW#u2", Comment: "Huge Yorkshire puddings"})
+	ix.PutReview(database.Review{RoastID: "anchor", ReviewKey: "REVIEW#u1", Comment: "Best gravy in Bristol"})
+	return ix
+}
+
+func roastIDs(results []Result) []string {
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.RoastID
+	}
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	ix := testIndex()
+
+	testCases := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{"Name", "kings arms", []string{"kings"}},
+		{"Location", "bristol", []string{"anchor", "kings"}},
+		{"Postcode", "ba1 ", []string{"crown"}},
+		{"Comment", "yorkshire pudding", []string{"crown"}},
+		{"Stemmed", "roasted potato ", []string{"crown"}},
+		{"Prefix", "anc", []string{"anchor"}},
+		{"Finished word isn't a prefix", "anc ", []string{}},
+		{"Typo", "bristal ", []string{"anchor", "kings"}},
+		{"Full match first", "bristol gravy", []string{"anchor", "kings", "crown"}},
+		{"Stopwords only", "the", []string{}},
+		{"No match", "sushi", []string{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := roastIDs(ix.Search(tc.query, 10))
+			if strings.Join(result, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("Search(%q) = %v; want %v", tc.query, result, tc.expected)
+			}
+		})
+	}
+
+	if result := ix.Search("bristol", 1); len(result) != 1 {
+		t.Errorf("Search(bristol, 1) returned %d results", len(result))
+	}
+}
+
+func TestSearchSnippets(t *testing.T) {
+	ix := testIndex()
+
+	results := ix.Search("gravy", 10)
+	if len(results) != 2 || results[0].RoastID != "anchor" {
+		t.Fatalf("Search(gravy) = %v", roastIDs(results))
+	}
+	if snippets := results[0].Snippets; len(snippets) != 1 || snippets[0].Text != "Best gravy in Bristol" {
+		t.Errorf("anchor snippets = %v", snippets)
+	}
+
+	snippets := results[1].Snippets
+	if len(snippets) != 1 || snippets[0].ReviewKey != "REVIEW#u1" {
+		t.Fatalf("crown snippets = %v", snippets)
+	}
+	text := snippets[0].Text
+	if !strings.Contains(text, "gravy") || !strings.HasSuffix(text, "…") || len(text) > 2*snippetContext+20 {
+		t.Errorf("crown snippet = %q", text)
+	}
+}
+
+func TestIndexUpdates(t *testing.T) {
+	ix := testIndex()
+
+	// Replacing a review drops the words it no longer has
+	ix.PutReview(database.Review{RoastID: "crown", ReviewKey: "REVIEW#u2", Comment: "Tiny puddings"})
+	if result := ix.Search("yorkshire ", 10); len(result) != 0 {
+		t.Errorf("Search(yorkshire) after edit = %v", roastIDs(result))
+	}
+
+	ix.RemoveReview("anchor", "REVIEW#u1")
+	if result := roastIDs(ix.Search("gravy", 10)); strings.Join(result, ",") != "crown" {
+		t.Errorf("Search(gravy) after removing review = %v", result)
+	}
+
+	ix.RemoveRoast("crown")
+	if result := ix.Search("puddings", 10); len(result) != 0 {
+		t.Errorf("Search(puddings) after removing roast = %v", roastIDs(result))
+	}
+	if len(ix.reviews) != 0 {
+		t.Errorf("reviews left after removing roasts = %v", ix.reviews)
+	}
+	for _, term := range ix.terms {
+		if len(ix.postings[term]) == 0 {
+			t.Errorf("term %s left in vocabulary without postings", term)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopwords are too common to be worth indexing
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "but": true, "by": true,
+	"for": true, "from": true, "had": true, "has": true, "have": true, "i": true, "in": true, "is": true, "it": true,
+	"its": true, "just": true, "my": true, "of": true, "on": true, "or": true, "our": true, "so": true, "that": true,
+	"the": true, "their": true, "they": true, "this": true, "to": true, "was": true, "we": true, "were": true,
+	"very": true, "with": true, "you": true,
+}
+
+// token is a word in a piece of text, stemmed, and where it was found
+type token struct {
+	term string
+	// word is the lower cased word before it was stemmed, used for prefix matching as it's typed
+	word       string
+	start, end int
+}
+
+// tokenize splits text into lower cased, stemmed words, dropping stopwords. Apostrophes are removed
+// rather than splitting words so "Sam's" is indexed as "sam".
+func tokenize(text string) []token {
+	var tokens []token
+	var word strings.Builder
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		w := word.String()
+		if !stopwords[w] {
+			tokens = append(tokens, token{term: stem(w), word: w, start: start, end: end})
+		}
+		word.Reset()
+		start = -1
+	}
+
+	for i, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if start < 0 {
+				start = i
+			}
+			word.WriteRune(unicode.ToLower(r))
+		case (r == '\'' || r == '’') && start >= 0:
+		default:
+			flush(i)
+		}
+	}
+	flush(len(text))
+
+	// The possessive s is left behind by dropping apostrophes
+	for i := range tokens {
+		if w := tokens[i].word; strings.HasSuffix(w, "s") && strings.ContainsAny(text[tokens[i].start:tokens[i].end], "'’") {
+			tokens[i].word = strings.TrimSuffix(w, "s")
+			tokens[i].term = stem(tokens[i].word)
+		}
+	}
+	return tokens
+}
+
+// stem strips common English suffixes so "roasted", "roasting" and "roasts" all index as "roast". It's
+// much lighter than a full Porter stemmer, stems don't have to be words, only the same for related ones.
+func stem(word string) string {
+	if len(word) <= 3 {
+		return word
+	}
+
+	switch {
+	case strings.HasSuffix(word, "sses"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		word = word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "oes"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us") &&
+		!strings.HasSuffix(word, "is"):
+		word = word[:len(word)-1]
+	}
+
+	for _, suffix := range []string{"ingly", "edly", "ing", "ed", "ly"} {
+		if base := strings.TrimSuffix(word, suffix); base != word && len(base) >= 3 && hasVowel(base) {
+			word = base
+			break
+		}
+	}
+
+	// Double consonants are undoubled so "stuffed" and "stuff" both become "stuf"
+	if n := len(word); word[n-1] == word[n-2] && !strings.ContainsRune("aeiouls", rune(word[n-1])) {
+		word = word[:n-1]
+	}
+
+	// A trailing e is dropped so "baked" and "bake" match
+	if len(word) >= 4 && strings.HasSuffix(word, "e") {
+		word = word[:len(word)-1]
+	}
+	return word
+}
+
+func hasVowel(s string) bool {
+	return strings.ContainsAny(s, "aeiouy")
+}
+
+// maxTypos is how many edits an indexed term can be from a query term and still match it
+func maxTypos(term string) int {
+	switch n := len(term); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
@@ -0,0 +1,56 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStem(t *testing.T) {
+	testCases := []struct {
+		words    []string
+		expected string
+	}{
+		{[]string{"roast", "roasts", "roasted", "roasting"}, "roast"},
+		{[]string{"potato", "potatoes"}, "potato"},
+		{[]string{"stuff", "stuffed", "stuffing"}, "stuf"},
+		{[]string{"bake", "baked", "bakes"}, "bak"},
+		{[]string{"pie", "pies"}, "pie"},
+		{[]string{"crispy", "crispies"}, "crispy"},
+		{[]string{"gravy"}, "gravy"},
+		{[]string{"glass", "glasses"}, "glass"},
+	}
+
+	for _, tc := range testCases {
+		for _, word := range tc.words {
+			if result := stem(word); result != tc.expected {
+				t.Errorf("stem(%s) = %s; want %s", word, result, tc.expected)
+			}
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	testCases := []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{"Stopwords", "The best roast in the city", []string{"best", "roast", "city"}},
+		{"Punctuation", "Yorkshire-puddings, gravy!", []string{"yorkshir", "pud", "gravy"}},
+		{"Possessive", "Sam's Kitchen", []string{"sam", "kitchen"}},
+		{"Curly apostrophe", "Sam’s", []string{"sam"}},
+		{"Empty", "  ", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var terms []string
+			for _, tok := range tokenize(tc.text) {
+				terms = append(terms, tok.term)
+			}
+			if !reflect.DeepEqual(terms, tc.expected) {
+				t.Errorf("tokenize(%q) = %v; want %v", tc.text, terms, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Package tags checks roast and review tags against the vocabulary of tag categories admins have defined
+package tags
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+)
+
+var (
+	ErrUnknownTag   = errors.New("unknown tag")
+	ErrInvalidTag   = errors.New("tags and categories need a lower case id of letters, numbers and dashes and a name")
+	ErrDuplicateTag = errors.New("tag is already in a category")
+)
+
+// MaxTags is the most tags a roast or review can have
+const MaxTags = 20
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Vocabulary is every tag that can be given to roasts and reviews
+type Vocabulary struct {
+	Categories []database.TagCategory
+	// category is the ID of each tag's category
+	category map[string]string
+}
+
+// NewVocabulary indexes the tags of categories
+func NewVocabulary(categories []database.TagCategory) *Vocabulary {
+	v := &Vocabulary{Categories: categories, category: map[string]string{}}
+	for _, category := range categories {
+		for _, tag := range category.Tags {
+			v.category[tag.ID] = category.ID
+		}
+	}
+	return v
+}
+
+// Load returns the vocabulary stored in the table
+func Load(tagModels database.TagModels) (*Vocabulary, error) {
+	categories, err := tagModels.GetTagCategories()
+	if err != nil {
+		return nil, err
+	}
+	return NewVocabulary(categories), nil
+}
+
+// Normalise lower cases and de-duplicates tags, returning an error if any aren't in the vocabulary
+func (v *Vocabulary) Normalise(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	if len(tags) > MaxTags {
+		return nil, fmt.Errorf("at most %d tags can be given", MaxTags)
+	}
+
+	normalised := make([]string, 0, len(tags))
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = NormaliseTag(tag)
+		if _, ok := v.category[tag]; !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownTag, tag)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalised = append(normalised, tag)
+		}
+	}
+	return normalised, nil
+}
+
+// NormaliseTag lower cases a tag and trims its spaces, without checking it's in the vocabulary
+func NormaliseTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// CheckCategory validates a category that's being created or replaced, tag IDs have to be unique across categories
+func (v *Vocabulary) CheckCategory(category database.TagCategory) error {
+	if !slugPattern.MatchString(category.ID) || strings.TrimSpace(category.Name) == "" {
+		return ErrInvalidTag
+	}
+
+	seen := map[string]bool{}
+	for _, tag := range category.Tags {
+		if !slugPattern.MatchString(tag.ID) || strings.TrimSpace(tag.Name) == "" {
+			return fmt.Errorf("%w: %q", ErrInvalidTag, tag.ID)
+		}
+		if seen[tag.ID] {
+			return fmt.Errorf("%w: %q is in %s twice", ErrDuplicateTag, tag.ID, category.ID)
+		}
+		seen[tag.ID] = true
+		if other, ok := v.category[tag.ID]; ok && other != category.ID {
+			return fmt.Errorf("%w: %q is in %s", ErrDuplicateTag, tag.ID, other)
+		}
+	}
+	return nil
+}
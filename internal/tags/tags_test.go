@@ -0,0 +1,64 @@
+package tags
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+)
+
+func testVocabulary() *Vocabulary {
+	return NewVocabulary([]database.TagCategory{
+		{ID: "dietary", Name: "Dietary", Tags: []database.Tag{{ID: "vegetarian", Name: "Vegetarian"}, {ID: "gluten-free-gravy", Name: "Gluten-free gravy"}}},
+		{ID: "portions", Name: "Portions", Tags: []database.Tag{{ID: "huge-portions", Name: "Huge portions"}}},
+	})
+}
+
+func TestNormalise(t *testing.T) {
+	testCases := []struct {
+		name     string
+		tags     []string
+		expected []string
+		err      error
+	}{
+		{"Empty", nil, nil, nil},
+		{"Known", []string{" Vegetarian", "huge-portions", "vegetarian"}, []string{"vegetarian", "huge-portions"}, nil},
+		{"Unknown", []string{"vegetarian", "vegan"}, nil, ErrUnknownTag},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := testVocabulary().Normalise(tc.tags)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("Normalise(%v) error = %v; want %v", tc.tags, err, tc.err)
+			}
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Normalise(%v) = %v; want %v", tc.tags, result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestCheckCategory(t *testing.T) {
+	testCases := []struct {
+		name     string
+		category database.TagCategory
+		expected error
+	}{
+		{"New", database.TagCategory{ID: "kids", Name: "Kids", Tags: []database.Tag{{ID: "kids-portion", Name: "Kids' portion"}}}, nil},
+		{"Replacing", database.TagCategory{ID: "dietary", Name: "Dietary", Tags: []database.Tag{{ID: "vegetarian", Name: "Veggie"}}}, nil},
+		{"InvalidID", database.TagCategory{ID: "Kids Menu", Name: "Kids"}, ErrInvalidTag},
+		{"MissingTagName", database.TagCategory{ID: "kids", Name: "Kids", Tags: []database.Tag{{ID: "kids-portion"}}}, ErrInvalidTag},
+		{"InOtherCategory", database.TagCategory{ID: "kids", Name: "Kids", Tags: []database.Tag{{ID: "huge-portions", Name: "Huge"}}}, ErrDuplicateTag},
+		{"Twice", database.TagCategory{ID: "kids", Name: "Kids", Tags: []database.Tag{{ID: "a", Name: "A"}, {ID: "a", Name: "A"}}}, ErrDuplicateTag},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := testVocabulary().CheckCategory(tc.category); !errors.Is(err, tc.expected) {
+				t.Errorf("CheckCategory() = %v; want %v", err, tc.expected)
+			}
+		})
+	}
+}
@@ -39,6 +39,36 @@ func CalculateAverageRating(ratings []float64) float64 {
 	return sum / float64(len(ratings))
 }
 
+// EditDistance is the Levenshtein distance between a and b, giving up once it reaches limit
+func EditDistance(a, b string, limit int) int {
+	if diff := len(a) - len(b); diff >= limit || -diff >= limit {
+		return limit
+	}
+
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		rowMin := current[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			rowMin = min(rowMin, current[j])
+		}
+		if rowMin >= limit {
+			return limit
+		}
+		previous, current = current, previous
+	}
+	return min(previous[len(b)], limit)
+}
+
 // Install "firebase.google.com/go"
 //    "firebase.google.com/go/auth"
 //    "google.golang.org/api/option"
@@ -27,3 +27,23 @@ func TestToPascalCase(t *testing.T) {
 		})
 	}
 }
+
+func TestEditDistance(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		limit    int
+		expected int
+	}{
+		{"bristol", "bristol", 3, 0},
+		{"bristol", "brsitol", 3, 2},
+		{"gravy", "gravey", 3, 1},
+		{"yorkshire", "york", 3, 3},
+		{"", "ab", 3, 2},
+	}
+
+	for _, tc := range testCases {
+		if result := EditDistance(tc.a, tc.b, tc.limit); result != tc.expected {
+			t.Errorf("EditDistance(%q, %q, %d) = %d; want %d", tc.a, tc.b, tc.limit, result, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package venues
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	// The API image doesn't have the timezone database installed
+	_ "time/tzdata"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+)
+
+//go:embed holidays.json
+var bundledCalendar string
+
+// DefaultTimezone is the timezone of venues that haven't given one
+const DefaultTimezone = "Europe/London"
+
+// BankHoliday is the day of serving windows that replace a venue's usual ones on bank holidays. Venues
+// without any keep their usual times.
+const BankHoliday = "bank-holiday"
+
+// maxLookahead is how many days ahead the next serving window is looked for, so a roast closed for
+// the season still has one
+const maxLookahead = 366
+
+// Calendar is the bank holidays and seasonal closures that change when roasts are served
+type Calendar struct {
+	BankHolidays []Holiday `json:"bankHolidays"`
+	Closures     []Closure `json:"closures"`
+	bankHolidays map[string]bool
+}
+
+// Holiday is a bank holiday on a YYYY-MM-DD date
+type Holiday struct {
+	Date string `json:"date"`
+	Name string `json:"name"`
+}
+
+// Closure is a period roasts aren't served, such as over Christmas. From and To are inclusive YYYY-MM-DD
+// dates, and a closure without roasts applies to all of them.
+type Closure struct {
+	From   string   `json:"from"`
+	To     string   `json:"to"`
+	Name   string   `json:"name"`
+	Roasts []string `json:"roasts,omitempty"`
+}
+
+// LoadCalendar returns the calendar in path, or the bundled England and Wales bank holidays if path is empty
+func LoadCalendar(path string) (*Calendar, error) {
+	if path == "" {
+		return ParseCalendar(strings.NewReader(bundledCalendar))
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening holidays file: %w", err)
+	}
+	defer file.Close()
+	return ParseCalendar(file)
+}
+
+// ParseCalendar reads a calendar as JSON
+func ParseCalendar(r io.Reader) (*Calendar, error) {
+	var cal Calendar
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cal); err != nil {
+		return nil, fmt.Errorf("error reading holidays: %w", err)
+	}
+
+	cal.bankHolidays = map[string]bool{}
+	for _, holiday := range cal.BankHolidays {
+		if _, err := time.Parse(DateLayout, holiday.Date); err != nil {
+			return nil, fmt.Errorf("bank holiday %q has an invalid date %q", holiday.Name, holiday.Date)
+		}
+		cal.bankHolidays[holiday.Date] = true
+	}
+	for _, closure := range cal.Closures {
+		from, fromErr := time.Parse(DateLayout, closure.From)
+		to, toErr := time.Parse(DateLayout, closure.To)
+		if fromErr != nil || toErr != nil || to.Before(from) {
+			return nil, fmt.Errorf("closure %q needs from and to dates with to on or after from", closure.Name)
+		}
+	}
+	return &cal, nil
+}
+
+// Windows returns when a roast is served on the date of day in the roast's timezone
+func (cal *Calendar) Windows(roast *database.Roast, day time.Time) []database.ServingTime {
+	if roast.Venue == nil || len(roast.Venue.Schedule) == 0 {
+		return nil
+	}
+	loc := location(roast.Venue.Timezone)
+	year, month, date := day.In(loc).Date()
+	local := time.Date(year, month, date, 0, 0, 0, 0, loc)
+	key := local.Format(DateLayout)
+	if cal.closed(roast.RoastID, key) {
+		return nil
+	}
+
+	served := Days[(int(local.Weekday())+6)%7]
+	if cal.isBankHoliday(key) && hasDay(roast.Venue.Schedule, BankHoliday) {
+		served = BankHoliday
+	}
+
+	var windows []database.ServingTime
+	for _, window := range roast.Venue.Schedule {
+		if window.Day != served {
+			continue
+		}
+		opens, openErr := ParseClock(window.Opens)
+		closes, closeErr := ParseClock(window.Closes)
+		if openErr != nil || closeErr != nil {
+			continue
+		}
+		windows = append(windows, database.ServingTime{
+			Opens:  time.Date(year, month, date, 0, opens, 0, 0, loc),
+			Closes: time.Date(year, month, date, 0, closes, 0, 0, loc),
+		})
+	}
+	return windows
+}
+
+// ServedOn reports whether a roast is served at any time on the date of now in the roast's timezone
+func (cal *Calendar) ServedOn(roast *database.Roast, now time.Time) bool {
+	return len(cal.Windows(roast, now)) > 0
+}
+
+// Serving reports whether a roast is being served at now
+func (cal *Calendar) Serving(roast *database.Roast, now time.Time) bool {
+	for _, window := range cal.Windows(roast, now) {
+		if !now.Before(window.Opens) && now.Before(window.Closes) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextServing returns the window a roast is being served in at now or the next one after it,
+// nil if it isn't served in the next year
+func (cal *Calendar) NextServing(roast *database.Roast, now time.Time) *database.ServingTime {
+	if roast.Venue == nil || len(roast.Venue.Schedule) == 0 {
+		return nil
+	}
+	loc := location(roast.Venue.Timezone)
+	year, month, date := now.In(loc).Date()
+	for i := 0; i <= maxLookahead; i++ {
+		// Midday is never skipped by a clock change, so always falls on the intended date
+		day := time.Date(year, month, date+i, 12, 0, 0, 0, loc)
+		for _, window := range cal.Windows(roast, day) {
+			if window.Closes.After(now) {
+				return &window
+			}
+		}
+	}
+	return nil
+}
+
+// SetNextServing sets the next serving window of each roast
+func (cal *Calendar) SetNextServing(roasts []database.Roast, now time.Time) {
+	for i := range roasts {
+		roasts[i].NextServing = cal.NextServing(&roasts[i], now)
+	}
+}
+
+func (cal *Calendar) isBankHoliday(date string) bool {
+	return cal != nil && cal.bankHolidays[date]
+}
+
+// closed reports whether a closure covers the roast on date, dates compare as strings
+func (cal *Calendar) closed(roastID, date string) bool {
+	if cal == nil {
+		return false
+	}
+	for _, closure := range cal.Closures {
+		if date < closure.From || date > closure.To {
+			continue
+		}
+		if len(closure.Roasts) == 0 {
+			return true
+		}
+		for _, id := range closure.Roasts {
+			if id == roastID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasDay(schedule []database.ServingWindow, day string) bool {
+	for _, window := range schedule {
+		if window.Day == day {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	locationsMu sync.RWMutex
+	locations   = map[string]*time.Location{}
+)
+
+// location loads a venue's timezone, which has been validated when it was saved. Locations are cached as
+// loading one reads the timezone database.
+func location(timezone string) *time.Location {
+	if timezone == "" {
+		timezone = DefaultTimezone
+	}
+	locationsMu.RLock()
+	loc, ok := locations[timezone]
+	locationsMu.RUnlock()
+	if ok {
+		return loc
+	}
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	locationsMu.Lock()
+	locations[timezone] = loc
+	locationsMu.Unlock()
+	return loc
+}
@@ -0,0 +1,126 @@
+package venues
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+)
+
+func testCalendar(t *testing.T) *Calendar {
+	t.Helper()
+	cal, err := ParseCalendar(strings.NewReader(`{
+		"bankHolidays": [{"date": "2024-05-27", "name": "Spring bank holiday"}],
+		"closures": [
+			{"from": "2024-12-24", "to": "2025-01-02", "name": "Christmas", "roasts": ["TheRedLion"]},
+			{"from": "2024-12-25", "to": "2024-12-25", "name": "Christmas Day"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseCalendar() error = %v", err)
+	}
+	return cal
+}
+
+func testRoast(roastID, timezone string) *database.Roast {
+	return &database.Roast{RoastID: roastID, Venue: &database.Venue{
+		Timezone: timezone,
+		Schedule: []database.ServingWindow{
+			{Day: "sunday", Opens: "12:00", Closes: "16:00"},
+			{Day: "monday", Opens: "18:00", Closes: "21:00"},
+			{Day: BankHoliday, Opens: "12:00", Closes: "15:00"},
+		},
+	}}
+}
+
+func TestServing(t *testing.T) {
+	london, _ := time.LoadLocation("Europe/London")
+	testCases := []struct {
+		name     string
+		roast    *database.Roast
+		now      time.Time
+		servedOn bool
+		serving  bool
+	}{
+		{"SundayLunch", testRoast("TheCrown", ""), time.Date(2024, 3, 3, 13, 0, 0, 0, london), true, true},
+		{"SundayMorning", testRoast("TheCrown", ""), time.Date(2024, 3, 3, 10, 0, 0, 0, london), true, false},
+		{"Tuesday", testRoast("TheCrown", ""), time.Date(2024, 3, 5, 13, 0, 0, 0, london), false, false},
+		// 11:30 UTC is 12:30 in London once the clocks have gone forward
+		{"BritishSummerTime", testRoast("TheCrown", ""), time.Date(2024, 6, 2, 11, 30, 0, 0, time.UTC), true, true},
+		// Late on Saturday in London is already Sunday lunchtime in Auckland
+		{"VenueTimezone", testRoast("TheCrown", "Pacific/Auckland"), time.Date(2024, 3, 2, 23, 30, 0, 0, time.UTC), true, true},
+		{"BankHolidayTimes", testRoast("TheCrown", ""), time.Date(2024, 5, 27, 13, 0, 0, 0, london), true, true},
+		{"NotUsualMondayOnBankHoliday", testRoast("TheCrown", ""), time.Date(2024, 5, 27, 19, 0, 0, 0, london), true, false},
+		{"ClosedForSeason", testRoast("TheRedLion", ""), time.Date(2024, 12, 29, 13, 0, 0, 0, london), false, false},
+		{"OpenWhileOthersClosed", testRoast("TheCrown", ""), time.Date(2024, 12, 29, 13, 0, 0, 0, london), true, true},
+		{"ClosedForEveryone", testRoast("TheCrown", ""), time.Date(2024, 12, 25, 13, 0, 0, 0, london), false, false},
+		{"NoVenue", &database.Roast{RoastID: "TheCrown"}, time.Date(2024, 3, 3, 13, 0, 0, 0, london), false, false},
+	}
+
+	cal := testCalendar(t)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := cal.ServedOn(tc.roast, tc.now); result != tc.servedOn {
+				t.Errorf("ServedOn() = %v; want %v", result, tc.servedOn)
+			}
+			if result := cal.Serving(tc.roast, tc.now); result != tc.serving {
+				t.Errorf("Serving() = %v; want %v", result, tc.serving)
+			}
+		})
+	}
+}
+
+func TestNextServing(t *testing.T) {
+	london, _ := time.LoadLocation("Europe/London")
+	testCases := []struct {
+		name     string
+		roast    *database.Roast
+		now      time.Time
+		expected time.Time
+	}{
+		{"Now", testRoast("TheCrown", ""), time.Date(2024, 3, 3, 13, 0, 0, 0, london), time.Date(2024, 3, 3, 12, 0, 0, 0, london)},
+		{"LaterToday", testRoast("TheCrown", ""), time.Date(2024, 3, 3, 9, 0, 0, 0, london), time.Date(2024, 3, 3, 12, 0, 0, 0, london)},
+		{"Tomorrow", testRoast("TheCrown", ""), time.Date(2024, 3, 3, 17, 0, 0, 0, london), time.Date(2024, 3, 4, 18, 0, 0, 0, london)},
+		{"AfterClosure", testRoast("TheRedLion", ""), time.Date(2024, 12, 23, 22, 0, 0, 0, london), time.Date(2025, 1, 5, 12, 0, 0, 0, london)},
+	}
+
+	cal := testCalendar(t)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := cal.NextServing(tc.roast, tc.now)
+			if result == nil || !result.Opens.Equal(tc.expected) {
+				t.Errorf("NextServing() = %v; want opening at %v", result, tc.expected)
+			}
+		})
+	}
+
+	if result := cal.NextServing(&database.Roast{RoastID: "TheCrown"}, time.Now()); result != nil {
+		t.Errorf("NextServing() without a venue = %v; want nil", result)
+	}
+}
+
+func TestParseCalendar(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		valid bool
+	}{
+		{"Empty", `{}`, true},
+		{"BadDate", `{"bankHolidays": [{"date": "27/05/2024"}]}`, false},
+		{"Backwards", `{"closures": [{"from": "2025-01-02", "to": "2024-12-24"}]}`, false},
+		{"UnknownField", `{"holidays": []}`, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseCalendar(strings.NewReader(tc.input)); (err == nil) != tc.valid {
+				t.Errorf("ParseCalendar() error = %v; want valid %v", err, tc.valid)
+			}
+		})
+	}
+
+	if _, err := LoadCalendar(""); err != nil {
+		t.Errorf("LoadCalendar() of bundled calendar error = %v", err)
+	}
+}
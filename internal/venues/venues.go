@@ -0,0 +1,244 @@
+// Package venues validates the venue details of a roast and builds its price history
+package venues
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+)
+
+// Booking options of a venue
+const (
+	BookingRequired    = "required"
+	BookingRecommended = "recommended"
+	BookingWalkIn      = "walk-in"
+)
+
+// DateLayout is the layout of a price's effective date
+const DateLayout = "2006-01-02"
+
+// ErrInvalidVenue is wrapped by every validation error
+var ErrInvalidVenue = errors.New("invalid venue")
+
+// Days are the days of the week serving windows are given for, in the order they're listed
+var Days = []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"}
+
+var (
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+	phonePattern    = regexp.MustCompile(`^\+?[0-9 ()-]{6,20}$`)
+)
+
+// Normalise validates venue details that are being saved, tidying them into the form they're stored in.
+// Serving windows are put in day order, and prices without an effective date take effect today.
+func Normalise(venue *database.Venue, today time.Time) error {
+	if err := normaliseSchedule(venue.Schedule); err != nil {
+		return err
+	}
+	venue.Timezone = strings.TrimSpace(venue.Timezone)
+	if venue.Timezone != "" {
+		if _, err := time.LoadLocation(venue.Timezone); err != nil || venue.Timezone == "Local" {
+			return fmt.Errorf("%w: unknown timezone %q", ErrInvalidVenue, venue.Timezone)
+		}
+	}
+
+	venue.Booking = strings.ToLower(strings.TrimSpace(venue.Booking))
+	switch venue.Booking {
+	case "", BookingRequired, BookingRecommended, BookingWalkIn:
+	default:
+		return fmt.Errorf("%w: booking must be %s, %s or %s", ErrInvalidVenue, BookingRequired, BookingRecommended, BookingWalkIn)
+	}
+
+	if err := normalisePrices(venue.Prices, today); err != nil {
+		return err
+	}
+	return normaliseContact(venue)
+}
+
+func normaliseSchedule(schedule []database.ServingWindow) error {
+	for i := range schedule {
+		window := &schedule[i]
+		window.Day = strings.ToLower(strings.TrimSpace(window.Day))
+		if DayIndex(window.Day) < 0 {
+			return fmt.Errorf("%w: unknown day %q", ErrInvalidVenue, window.Day)
+		}
+		opens, err := ParseClock(window.Opens)
+		if err != nil {
+			return err
+		}
+		closes, err := ParseClock(window.Closes)
+		if err != nil {
+			return err
+		}
+		if closes <= opens {
+			return fmt.Errorf("%w: %s closes at %s before it opens at %s", ErrInvalidVenue, window.Day, window.Closes, window.Opens)
+		}
+		window.Opens, window.Closes = FormatClock(opens), FormatClock(closes)
+	}
+
+	// Times are zero padded so compare as strings
+	sort.SliceStable(schedule, func(i, j int) bool {
+		if schedule[i].Day != schedule[j].Day {
+			return DayIndex(schedule[i].Day) < DayIndex(schedule[j].Day)
+		}
+		return schedule[i].Opens < schedule[j].Opens
+	})
+	for i := 1; i < len(schedule); i++ {
+		if schedule[i].Day == schedule[i-1].Day && schedule[i].Opens < schedule[i-1].Closes {
+			return fmt.Errorf("%w: serving times on %s overlap", ErrInvalidVenue, schedule[i].Day)
+		}
+	}
+	return nil
+}
+
+func normalisePrices(prices []database.MenuPrice, today time.Time) error {
+	seen := map[string]bool{}
+	for i := range prices {
+		price := &prices[i]
+		price.Item = strings.TrimSpace(price.Item)
+		if price.Item == "" {
+			return fmt.Errorf("%w: prices need an item", ErrInvalidVenue)
+		}
+		if price.Amount <= 0 {
+			return fmt.Errorf("%w: the price of %s must be more than 0", ErrInvalidVenue, price.Item)
+		}
+		price.Currency = strings.ToUpper(strings.TrimSpace(price.Currency))
+		if !currencyPattern.MatchString(price.Currency) {
+			return fmt.Errorf("%w: currency of %s must be a 3 letter code such as GBP", ErrInvalidVenue, price.Item)
+		}
+		if price.EffectiveFrom == "" {
+			price.EffectiveFrom = today.Format(DateLayout)
+		}
+		if _, err := time.Parse(DateLayout, price.EffectiveFrom); err != nil {
+			return fmt.Errorf("%w: effective date of %s must be YYYY-MM-DD", ErrInvalidVenue, price.Item)
+		}
+
+		key := strings.ToLower(price.Item)
+		if seen[key] {
+			return fmt.Errorf("%w: %s is priced twice", ErrInvalidVenue, price.Item)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+func normaliseContact(venue *database.Venue) error {
+	contact := venue.Contact
+	if contact == nil {
+		return nil
+	}
+	contact.Phone = strings.TrimSpace(contact.Phone)
+	contact.Email = strings.TrimSpace(contact.Email)
+	contact.Website = strings.TrimSpace(contact.Website)
+	if *contact == (database.Contact{}) {
+		venue.Contact = nil
+		return nil
+	}
+
+	if contact.Phone != "" && !phonePattern.MatchString(contact.Phone) {
+		return fmt.Errorf("%w: invalid phone number", ErrInvalidVenue)
+	}
+	if contact.Email != "" {
+		if address, err := mail.ParseAddress(contact.Email); err != nil || address.Address != contact.Email {
+			return fmt.Errorf("%w: invalid email address", ErrInvalidVenue)
+		}
+	}
+	if contact.Website != "" {
+		website, err := url.Parse(contact.Website)
+		if err != nil || (website.Scheme != "http" && website.Scheme != "https") || website.Host == "" {
+			return fmt.Errorf("%w: website must be an http or https URL", ErrInvalidVenue)
+		}
+	}
+	return nil
+}
+
+// DayIndex is the position of day in Days, BankHoliday comes after them and anything else is -1
+func DayIndex(day string) int {
+	for i, d := range Days {
+		if d == day {
+			return i
+		}
+	}
+	if day == BankHoliday {
+		return len(Days)
+	}
+	return -1
+}
+
+// ParseClock parses a 24 hour HH:MM time into minutes after midnight
+func ParseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q isn't a 24 hour HH:MM time", ErrInvalidVenue, clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// FormatClock formats minutes after midnight as HH:MM
+func FormatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// PriceChanges returns the prices in updated that weren't in previous, which need adding to the price history
+func PriceChanges(previous, updated *database.Venue) []database.MenuPrice {
+	if updated == nil {
+		return nil
+	}
+	existing := map[database.MenuPrice]bool{}
+	if previous != nil {
+		for _, price := range previous.Prices {
+			existing[price] = true
+		}
+	}
+
+	var changes []database.MenuPrice
+	for _, price := range updated.Prices {
+		if !existing[price] {
+			changes = append(changes, price)
+		}
+	}
+	return changes
+}
+
+// ItemPrices is the price history of one item on the menu
+type ItemPrices struct {
+	Item string `json:"item"`
+	// Prices are oldest first, a record repeating the price before it is left out
+	Prices []database.PriceRecord `json:"prices"`
+}
+
+// Timeline groups a roast's price records by item, items are in the order they were first priced
+func Timeline(records []database.PriceRecord) []ItemPrices {
+	sorted := slices.Clone(records)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].EffectiveFrom != sorted[j].EffectiveFrom {
+			return sorted[i].EffectiveFrom < sorted[j].EffectiveFrom
+		}
+		return sorted[i].RecordedAt < sorted[j].RecordedAt
+	})
+
+	timeline := []ItemPrices{}
+	index := map[string]int{}
+	for _, record := range sorted {
+		key := strings.ToLower(record.Item)
+		i, ok := index[key]
+		if !ok {
+			i = len(timeline)
+			index[key] = i
+			timeline = append(timeline, ItemPrices{Item: record.Item})
+		}
+		prices := timeline[i].Prices
+		if n := len(prices); n > 0 && prices[n-1].Amount == record.Amount && prices[n-1].Currency == record.Currency {
+			continue
+		}
+		timeline[i].Prices = append(prices, record)
+	}
+	return timeline
+}
@@ -0,0 +1,110 @@
+package venues
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/internal/database"
+)
+
+func TestNormalise(t *testing.T) {
+	today := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name     string
+		venue    database.Venue
+		expected *database.Venue
+		err      error
+	}{
+		{
+			"Tidied",
+			database.Venue{
+				Schedule: []database.ServingWindow{{Day: "Sunday", Opens: "12:00", Closes: "16:30"}, {Day: "saturday", Opens: "9:00", Closes: "11:00"}},
+				Booking:  "Walk-In",
+				Prices:   []database.MenuPrice{{Item: " Beef ", Amount: 1850, Currency: "gbp"}},
+				Contact:  &database.Contact{Phone: " "},
+			},
+			&database.Venue{
+				Schedule: []database.ServingWindow{{Day: "saturday", Opens: "09:00", Closes: "11:00"}, {Day: "sunday", Opens: "12:00", Closes: "16:30"}},
+				Booking:  BookingWalkIn,
+				Prices:   []database.MenuPrice{{Item: "Beef", Amount: 1850, Currency: "GBP", EffectiveFrom: "2024-03-10"}},
+			},
+			nil,
+		},
+		{"UnknownDay", database.Venue{Schedule: []database.ServingWindow{{Day: "sun", Opens: "12:00", Closes: "16:00"}}}, nil, ErrInvalidVenue},
+		{"BadTime", database.Venue{Schedule: []database.ServingWindow{{Day: "sunday", Opens: "noon", Closes: "16:00"}}}, nil, ErrInvalidVenue},
+		{"ClosesBeforeOpening", database.Venue{Schedule: []database.ServingWindow{{Day: "sunday", Opens: "16:00", Closes: "12:00"}}}, nil, ErrInvalidVenue},
+		{"Overlapping", database.Venue{Schedule: []database.ServingWindow{{Day: "sunday", Opens: "12:00", Closes: "16:00"}, {Day: "sunday", Opens: "15:00", Closes: "18:00"}}}, nil, ErrInvalidVenue},
+		{"BankHolidayLast", database.Venue{Schedule: []database.ServingWindow{{Day: BankHoliday, Opens: "12:00", Closes: "15:00"}, {Day: "sunday", Opens: "12:00", Closes: "16:00"}}, Timezone: "Europe/Dublin"}, &database.Venue{Schedule: []database.ServingWindow{{Day: "sunday", Opens: "12:00", Closes: "16:00"}, {Day: BankHoliday, Opens: "12:00", Closes: "15:00"}}, Timezone: "Europe/Dublin"}, nil},
+		{"UnknownTimezone", database.Venue{Timezone: "Europe/Bristol"}, nil, ErrInvalidVenue},
+		{"UnknownBooking", database.Venue{Booking: "sometimes"}, nil, ErrInvalidVenue},
+		{"FreeRoast", database.Venue{Prices: []database.MenuPrice{{Item: "Beef", Currency: "GBP"}}}, nil, ErrInvalidVenue},
+		{"BadCurrency", database.Venue{Prices: []database.MenuPrice{{Item: "Beef", Amount: 1850, Currency: "£"}}}, nil, ErrInvalidVenue},
+		{"BadDate", database.Venue{Prices: []database.MenuPrice{{Item: "Beef", Amount: 1850, Currency: "GBP", EffectiveFrom: "10/03/2024"}}}, nil, ErrInvalidVenue},
+		{"PricedTwice", database.Venue{Prices: []database.MenuPrice{{Item: "Beef", Amount: 1850, Currency: "GBP"}, {Item: "beef", Amount: 1950, Currency: "GBP"}}}, nil, ErrInvalidVenue},
+		{"BadEmail", database.Venue{Contact: &database.Contact{Email: "bookings"}}, nil, ErrInvalidVenue},
+		{"BadWebsite", database.Venue{Contact: &database.Contact{Website: "ftp://example.com"}}, nil, ErrInvalidVenue},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			venue := tc.venue
+			err := Normalise(&venue, today)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("Normalise() error = %v; want %v", err, tc.err)
+			}
+			if tc.expected != nil && !reflect.DeepEqual(&venue, tc.expected) {
+				t.Errorf("Normalise() = %+v; want %+v", venue, *tc.expected)
+			}
+		})
+	}
+}
+
+func TestPriceChanges(t *testing.T) {
+	beef := database.MenuPrice{Item: "Beef", Amount: 1850, Currency: "GBP", EffectiveFrom: "2024-01-01"}
+	lamb := database.MenuPrice{Item: "Lamb", Amount: 1950, Currency: "GBP", EffectiveFrom: "2024-01-01"}
+	dearerBeef := database.MenuPrice{Item: "Beef", Amount: 1950, Currency: "GBP", EffectiveFrom: "2024-03-01"}
+
+	testCases := []struct {
+		name     string
+		previous *database.Venue
+		updated  *database.Venue
+		expected []database.MenuPrice
+	}{
+		{"FirstPrices", nil, &database.Venue{Prices: []database.MenuPrice{beef, lamb}}, []database.MenuPrice{beef, lamb}},
+		{"Unchanged", &database.Venue{Prices: []database.MenuPrice{beef, lamb}}, &database.Venue{Prices: []database.MenuPrice{lamb, beef}}, nil},
+		{"PriceRise", &database.Venue{Prices: []database.MenuPrice{beef, lamb}}, &database.Venue{Prices: []database.MenuPrice{dearerBeef, lamb}}, []database.MenuPrice{dearerBeef}},
+		{"Removed", &database.Venue{Prices: []database.MenuPrice{beef, lamb}}, &database.Venue{Prices: []database.MenuPrice{beef}}, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := PriceChanges(tc.previous, tc.updated); !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("PriceChanges() = %v; want %v", result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	record := func(item string, amount int, date string, recordedAt int64) database.PriceRecord {
+		return database.PriceRecord{RoastID: "TheRoast", MenuPrice: database.MenuPrice{Item: item, Amount: amount, Currency: "GBP", EffectiveFrom: date}, RecordedAt: recordedAt}
+	}
+	records := []database.PriceRecord{
+		record("Lamb", 1950, "2024-01-01", 1),
+		record("Beef", 1850, "2024-01-01", 1),
+		record("Beef", 1950, "2024-06-01", 3),
+		// Re-entered without a change
+		record("Lamb", 1950, "2024-03-01", 2),
+		record("Beef", 1750, "2023-06-01", 0),
+	}
+
+	expected := []ItemPrices{
+		{Item: "Beef", Prices: []database.PriceRecord{records[4], records[1], records[2]}},
+		{Item: "Lamb", Prices: []database.PriceRecord{records[0]}},
+	}
+	if result := Timeline(records); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Timeline() = %+v; want %+v", result, expected)
+	}
+}
@@ -1,14 +1,37 @@
 package apikey
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
 	"os"
 
 	"github.com/labstack/echo/v4"
 )
 
-// APIKeyMiddleware checks for a valid API key in the request header
-func Validate() echo.MiddlewareFunc {
+// Store looks up API keys by their hash, keys managed through roastctl are stored in dynamo
+type Store interface {
+	ValidAPIKey(keyHash string) (bool, error)
+}
+
+// Generate returns a new random API key
+func Generate() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Hash returns the hash an API key is stored under
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyMiddleware checks for a valid API key in the request header, either the API_KEY env variable or a key in the store
+func Validate(store Store) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			apiKey := c.Request().Header.Get("X-API-Key")
@@ -16,7 +39,15 @@ func Validate() echo.MiddlewareFunc {
 				return c.JSON(http.StatusUnauthorized, map[string]string{"message": "API key is missing"})
 			}
 
-			if apiKey != os.Getenv("API_KEY") {
+			if envKey := os.Getenv("API_KEY"); envKey != "" && apiKey == envKey {
+				return next(c)
+			}
+
+			valid, err := store.ValidAPIKey(Hash(apiKey))
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Unable to validate API key"})
+			}
+			if !valid {
 				return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid API key"})
 			}
 
@@ -2,7 +2,10 @@ package dynamo
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/94DanielBrown/roasts-api/pkg/awsconfig"
@@ -12,44 +15,23 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
-// Connect to dynamodb
+// Connect to dynamodb, DYNAMO_ENDPOINT can be set to use DynamoDB Local instead of AWS
 func Connect() (*dynamodb.Client, error) {
 	config, err := awsconfig.NewConfig()
 	if err != nil {
 		log.Fatal(err)
 	}
-	client := dynamodb.NewFromConfig(config)
+	client := dynamodb.NewFromConfig(config, func(o *dynamodb.Options) {
+		if endpoint := os.Getenv("DYNAMO_ENDPOINT"); endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
 	return client, nil
 }
 
-// Create a dynamodb table
+// Create a dynamodb table using the single-table design in TableSchema
 func Create(ctx context.Context, client *dynamodb.Client, tableName string) error {
-
-	_, err := client.CreateTable(ctx, &dynamodb.CreateTableInput{
-		AttributeDefinitions: []types.AttributeDefinition{
-			{
-				AttributeName: aws.String("id"),
-				AttributeType: types.ScalarAttributeTypeS,
-			},
-			{
-				AttributeName: aws.String("timestamp"),
-				AttributeType: types.ScalarAttributeTypeS,
-			},
-		},
-		KeySchema: []types.KeySchemaElement{
-			{
-				AttributeName: aws.String("id"),
-				KeyType:       types.KeyTypeHash,
-			},
-			{
-				AttributeName: aws.String("timestamp"),
-				KeyType:       types.KeyTypeRange,
-			},
-		},
-		TableName:   aws.String(tableName),
-		BillingMode: types.BillingModePayPerRequest,
-	})
-
+	_, err := client.CreateTable(ctx, TableSchema.CreateTableInput(tableName))
 	if err != nil {
 		return err
 	}
@@ -79,7 +61,7 @@ func Exists(ctx context.Context, client *dynamodb.Client, tableName string) (boo
 }
 
 // Wait for dynamodb table to be created
-func Wait(ctx context.Context, client *dynamodb.Client, tableName string) {
+func Wait(ctx context.Context, client *dynamodb.Client, tableName string) error {
 
 	waiter := dynamodb.NewTableExistsWaiter(client, func(t *dynamodb.TableExistsWaiterOptions) {
 		t.MinDelay = 5 * time.Second
@@ -93,6 +75,175 @@ func Wait(ctx context.Context, client *dynamodb.Client, tableName string) {
 	}
 	err := waiter.Wait(ctx, &ti, maxWait)
 	if err != nil {
-		log.Panicf("time out waiting for table %s to be created: %v", tableName, err)
+		return fmt.Errorf("timed out waiting for table %s to be created: %w", tableName, err)
+	}
+	return nil
+}
+
+// Describe returns the description of an existing dynamodb table
+func Describe(ctx context.Context, client *dynamodb.Client, tableName string) (*types.TableDescription, error) {
+	out, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error describing table %s: %w", tableName, err)
+	}
+	return out.Table, nil
+}
+
+// DescribeTTL returns the time to live settings of an existing dynamodb table
+func DescribeTTL(ctx context.Context, client *dynamodb.Client, tableName string) (*types.TimeToLiveDescription, error) {
+	out, err := client.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{
+		TableName: aws.String(tableName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error describing time to live of table %s: %w", tableName, err)
+	}
+	return out.TimeToLiveDescription, nil
+}
+
+// Validate checks an existing table has the keys, indexes and time to live in TableSchema.
+// It's used at startup so the app refuses to run against a table it can't query or that never expires items.
+func Validate(ctx context.Context, client *dynamodb.Client, tableName string) error {
+	table, err := Describe(ctx, client, tableName)
+	if err != nil {
+		return err
+	}
+
+	missing, err := TableSchema.Check(table)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: index %s is missing, run roastctl table ensure", ErrSchemaMismatch, missing[0].Name)
+	}
+
+	ttl, err := DescribeTTL(ctx, client, tableName)
+	if err != nil {
+		return err
+	}
+	needsTTL, err := TableSchema.CheckTTL(ttl)
+	if err != nil {
+		return err
+	}
+	if needsTTL {
+		return fmt.Errorf("%w: time to live on %s isn't enabled, run roastctl table ensure", ErrSchemaMismatch, TableSchema.TTLAttribute)
+	}
+	return nil
+}
+
+// EnsureTable creates the table if it doesn't exist, otherwise adds any indexes from TableSchema it's missing.
+// Either way it enables the time to live in TableSchema if it isn't already.
+// It's safe to run repeatedly and returns a description of what was done.
+func EnsureTable(ctx context.Context, client *dynamodb.Client, tableName string) (string, error) {
+	exists, err := Exists(ctx, client, tableName)
+	if err != nil {
+		return "", fmt.Errorf("error checking if table exists: %w", err)
+	}
+
+	if !exists {
+		if err := Create(ctx, client, tableName); err != nil {
+			return "", fmt.Errorf("error creating table: %w", err)
+		}
+		if err := Wait(ctx, client, tableName); err != nil {
+			return "", err
+		}
+		if _, err := ensureTTL(ctx, client, tableName); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("table %s created", tableName), nil
+	}
+
+	table, err := Describe(ctx, client, tableName)
+	if err != nil {
+		return "", err
+	}
+
+	missing, err := TableSchema.Check(table)
+	if err != nil {
+		return "", err
+	}
+	enabledTTL, err := ensureTTL(ctx, client, tableName)
+	if err != nil {
+		return "", err
+	}
+	if len(missing) == 0 {
+		if enabledTTL {
+			return fmt.Sprintf("table %s updated, enabled time to live on %s", tableName, TableSchema.TTLAttribute), nil
+		}
+		return fmt.Sprintf("table %s is up to date", tableName), nil
+	}
+
+	// DynamoDB only allows one index to be created per UpdateTable call
+	var created []string
+	for _, index := range missing {
+		_, err := client.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+			TableName:            aws.String(tableName),
+			AttributeDefinitions: TableSchema.attributeDefinitions(),
+			GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{
+				{Create: index.create()},
+			},
+		})
+		if err != nil {
+			return "", fmt.Errorf("error creating index %s: %w", index.Name, err)
+		}
+		if err := waitForIndex(ctx, client, tableName, index.Name); err != nil {
+			return "", err
+		}
+		created = append(created, index.Name)
+	}
+
+	result := fmt.Sprintf("table %s updated, created indexes: %s", tableName, strings.Join(created, ", "))
+	if enabledTTL {
+		result += fmt.Sprintf(", enabled time to live on %s", TableSchema.TTLAttribute)
+	}
+	return result, nil
+}
+
+// ensureTTL enables the time to live in TableSchema if it isn't already, reporting whether it did
+func ensureTTL(ctx context.Context, client *dynamodb.Client, tableName string) (bool, error) {
+	ttl, err := DescribeTTL(ctx, client, tableName)
+	if err != nil {
+		return false, err
+	}
+	needsTTL, err := TableSchema.CheckTTL(ttl)
+	if err != nil || !needsTTL {
+		return false, err
+	}
+
+	_, err = client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(tableName),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(TableSchema.TTLAttribute),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("error enabling time to live on %s: %w", TableSchema.TTLAttribute, err)
+	}
+	return true, nil
+}
+
+// waitForIndex polls the table until the named index has finished building
+func waitForIndex(ctx context.Context, client *dynamodb.Client, tableName, indexName string) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+	defer cancel()
+
+	for {
+		table, err := Describe(ctx, client, tableName)
+		if err != nil {
+			return err
+		}
+		for _, index := range table.GlobalSecondaryIndexes {
+			if aws.ToString(index.IndexName) == indexName && index.IndexStatus == types.IndexStatusActive {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for index %s to become active: %w", indexName, ctx.Err())
+		case <-time.After(10 * time.Second):
+		}
 	}
 }
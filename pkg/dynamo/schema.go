@@ -0,0 +1,184 @@
+package dynamo
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Attribute names used by the single-table design, see the DynamoDB Design section of the README
+const (
+	PartitionKey = "PK"
+	SortKey      = "SK"
+)
+
+// UserIndex lets reviews be looked up by the user that wrote them without scanning the table
+const UserIndex = "UserIndex"
+
+// GeoIndex partitions roasts by a coarse geohash cell, sorted by their full geohash, for proximity searches
+const GeoIndex = "GeoIndex"
+
+// ErrSchemaMismatch is returned when an existing table doesn't have the keys the app expects
+var ErrSchemaMismatch = errors.New("table schema doesn't match the single-table design")
+
+// Index describes a global secondary index on the table
+type Index struct {
+	Name         string
+	PartitionKey string
+	SortKey      string
+}
+
+// Schema describes the keys and indexes the table needs
+type Schema struct {
+	PartitionKey string
+	SortKey      string
+	Indexes      []Index
+	// TTLAttribute holds when an item expires in epoch seconds, DynamoDB deletes items once it's passed
+	TTLAttribute string
+}
+
+// TableSchema is the single-table design used by internal/database
+var TableSchema = Schema{
+	PartitionKey: PartitionKey,
+	SortKey:      SortKey,
+	Indexes: []Index{
+		{Name: UserIndex, PartitionKey: "UserID", SortKey: SortKey},
+		{Name: GeoIndex, PartitionKey: "GeoCell", SortKey: "Geohash"},
+	},
+	TTLAttribute: "ExpiresAt",
+}
+
+// attributeDefinitions returns every key attribute used by the table and its indexes
+func (s Schema) attributeDefinitions() []types.AttributeDefinition {
+	seen := map[string]bool{}
+	var defs []types.AttributeDefinition
+	add := func(name string) {
+		if name == "" || seen[name] {
+			return
+		}
+		seen[name] = true
+		defs = append(defs, types.AttributeDefinition{
+			AttributeName: aws.String(name),
+			AttributeType: types.ScalarAttributeTypeS,
+		})
+	}
+
+	add(s.PartitionKey)
+	add(s.SortKey)
+	for _, index := range s.Indexes {
+		add(index.PartitionKey)
+		add(index.SortKey)
+	}
+	return defs
+}
+
+func keySchema(partitionKey, sortKey string) []types.KeySchemaElement {
+	elements := []types.KeySchemaElement{
+		{AttributeName: aws.String(partitionKey), KeyType: types.KeyTypeHash},
+	}
+	if sortKey != "" {
+		elements = append(elements, types.KeySchemaElement{AttributeName: aws.String(sortKey), KeyType: types.KeyTypeRange})
+	}
+	return elements
+}
+
+func (i Index) create() *types.CreateGlobalSecondaryIndexAction {
+	return &types.CreateGlobalSecondaryIndexAction{
+		IndexName:  aws.String(i.Name),
+		KeySchema:  keySchema(i.PartitionKey, i.SortKey),
+		Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+	}
+}
+
+// CreateTableInput builds the CreateTable request for the schema
+func (s Schema) CreateTableInput(tableName string) *dynamodb.CreateTableInput {
+	var indexes []types.GlobalSecondaryIndex
+	for _, index := range s.Indexes {
+		action := index.create()
+		indexes = append(indexes, types.GlobalSecondaryIndex{
+			IndexName:  action.IndexName,
+			KeySchema:  action.KeySchema,
+			Projection: action.Projection,
+		})
+	}
+
+	return &dynamodb.CreateTableInput{
+		TableName:              aws.String(tableName),
+		AttributeDefinitions:   s.attributeDefinitions(),
+		KeySchema:              keySchema(s.PartitionKey, s.SortKey),
+		GlobalSecondaryIndexes: indexes,
+		BillingMode:            types.BillingModePayPerRequest,
+	}
+}
+
+// Check compares a described table against the schema, returning the indexes that still need creating.
+// An error wrapping ErrSchemaMismatch is returned if the table keys or an existing index's keys differ.
+func (s Schema) Check(table *types.TableDescription) ([]Index, error) {
+	if !keysMatch(table.KeySchema, s.PartitionKey, s.SortKey) {
+		return nil, fmt.Errorf("%w: expected %s/%s keys, found %s", ErrSchemaMismatch, s.PartitionKey, s.SortKey, describeKeys(table.KeySchema))
+	}
+
+	existing := map[string][]types.KeySchemaElement{}
+	for _, index := range table.GlobalSecondaryIndexes {
+		existing[aws.ToString(index.IndexName)] = index.KeySchema
+	}
+
+	var missing []Index
+	for _, index := range s.Indexes {
+		keys, ok := existing[index.Name]
+		if !ok {
+			missing = append(missing, index)
+			continue
+		}
+		if !keysMatch(keys, index.PartitionKey, index.SortKey) {
+			return nil, fmt.Errorf("%w: index %s expected %s/%s keys, found %s", ErrSchemaMismatch, index.Name, index.PartitionKey, index.SortKey, describeKeys(keys))
+		}
+	}
+	return missing, nil
+}
+
+// CheckTTL compares a table's time to live against the schema, reporting whether it still needs enabling.
+// An error wrapping ErrSchemaMismatch is returned if it's enabled on another attribute or being disabled.
+func (s Schema) CheckTTL(ttl *types.TimeToLiveDescription) (bool, error) {
+	if s.TTLAttribute == "" {
+		return false, nil
+	}
+	if ttl == nil || ttl.TimeToLiveStatus == types.TimeToLiveStatusDisabled {
+		return true, nil
+	}
+	if name := aws.ToString(ttl.AttributeName); name != s.TTLAttribute {
+		return false, fmt.Errorf("%w: expected time to live on %s, found %s", ErrSchemaMismatch, s.TTLAttribute, name)
+	}
+	if ttl.TimeToLiveStatus == types.TimeToLiveStatusDisabling {
+		return false, fmt.Errorf("%w: time to live on %s is being disabled", ErrSchemaMismatch, s.TTLAttribute)
+	}
+	return false, nil
+}
+
+func keysMatch(elements []types.KeySchemaElement, partitionKey, sortKey string) bool {
+	hash, rng := splitKeys(elements)
+	return hash == partitionKey && rng == sortKey
+}
+
+func describeKeys(elements []types.KeySchemaElement) string {
+	hash, rng := splitKeys(elements)
+	if rng == "" {
+		return hash
+	}
+	return hash + "/" + rng
+}
+
+func splitKeys(elements []types.KeySchemaElement) (hash string, rng string) {
+	for _, element := range elements {
+		switch element.KeyType {
+		case types.KeyTypeHash:
+			hash = aws.ToString(element.AttributeName)
+		case types.KeyTypeRange:
+			rng = aws.ToString(element.AttributeName)
+		}
+	}
+	return hash, rng
+}
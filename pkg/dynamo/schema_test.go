@@ -0,0 +1,101 @@
+package dynamo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestSchemaCheck(t *testing.T) {
+	testCases := []struct {
+		name        string
+		table       types.TableDescription
+		wantMissing int
+		wantErr     bool
+	}{
+		{
+			name: "UpToDate",
+			table: types.TableDescription{
+				KeySchema: keySchema("PK", "SK"),
+				GlobalSecondaryIndexes: []types.GlobalSecondaryIndexDescription{
+					{IndexName: aws.String(UserIndex), KeySchema: keySchema("UserID", "SK")},
+					{IndexName: aws.String(GeoIndex), KeySchema: keySchema("GeoCell", "Geohash")},
+				},
+			},
+		},
+		{
+			name:        "MissingIndex",
+			table:       types.TableDescription{KeySchema: keySchema("PK", "SK")},
+			wantMissing: 2,
+		},
+		{
+			name:    "OldIdTimestampTable",
+			table:   types.TableDescription{KeySchema: keySchema("id", "timestamp")},
+			wantErr: true,
+		},
+		{
+			name: "IndexWithWrongKeys",
+			table: types.TableDescription{
+				KeySchema: keySchema("PK", "SK"),
+				GlobalSecondaryIndexes: []types.GlobalSecondaryIndexDescription{
+					{IndexName: aws.String(UserIndex), KeySchema: keySchema("UserID", "")},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			missing, err := TableSchema.Check(&tc.table)
+			if tc.wantErr {
+				if !errors.Is(err, ErrSchemaMismatch) {
+					t.Errorf("Check() error = %v; want ErrSchemaMismatch", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Check() unexpected error: %v", err)
+			}
+			if len(missing) != tc.wantMissing {
+				t.Errorf("Check() returned %d missing indexes; want %d", len(missing), tc.wantMissing)
+			}
+		})
+	}
+}
+
+func TestSchemaCheckTTL(t *testing.T) {
+	testCases := []struct {
+		name      string
+		ttl       *types.TimeToLiveDescription
+		wantNeeds bool
+		wantErr   bool
+	}{
+		{"Enabled", &types.TimeToLiveDescription{AttributeName: aws.String("ExpiresAt"), TimeToLiveStatus: types.TimeToLiveStatusEnabled}, false, false},
+		{"Enabling", &types.TimeToLiveDescription{AttributeName: aws.String("ExpiresAt"), TimeToLiveStatus: types.TimeToLiveStatusEnabling}, false, false},
+		{"Disabled", &types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusDisabled}, true, false},
+		{"NotDescribed", nil, true, false},
+		{"OtherAttribute", &types.TimeToLiveDescription{AttributeName: aws.String("TTL"), TimeToLiveStatus: types.TimeToLiveStatusEnabled}, false, true},
+		{"Disabling", &types.TimeToLiveDescription{AttributeName: aws.String("ExpiresAt"), TimeToLiveStatus: types.TimeToLiveStatusDisabling}, false, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			needs, err := TableSchema.CheckTTL(tc.ttl)
+			if tc.wantErr {
+				if !errors.Is(err, ErrSchemaMismatch) {
+					t.Errorf("CheckTTL() error = %v; want ErrSchemaMismatch", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CheckTTL() unexpected error: %v", err)
+			}
+			if needs != tc.wantNeeds {
+				t.Errorf("CheckTTL() = %v; want %v", needs, tc.wantNeeds)
+			}
+		})
+	}
+}
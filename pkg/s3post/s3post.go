@@ -0,0 +1,120 @@
+// Package s3post presigns browser-based S3 POST uploads. Unlike a presigned PUT, a POST policy can limit
+// the size of the upload and pin its content type, which S3 enforces before accepting the object.
+package s3post
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+const algorithm = "AWS4-HMAC-SHA256"
+
+// Policy describes the single object a presigned POST allows to be uploaded
+type Policy struct {
+	Bucket      string
+	Key         string
+	ContentType string
+	MinSize     int64
+	MaxSize     int64
+	Expiry      time.Duration
+}
+
+// Post is a presigned upload, the client sends Fields as multipart form fields followed by the file to URL
+type Post struct {
+	URL       string            `json:"url"`
+	Fields    map[string]string `json:"fields"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+// Presign signs a POST policy with the client's credentials
+func Presign(ctx context.Context, client *s3.Client, policy Policy) (*Post, error) {
+	options := client.Options()
+	if options.Credentials == nil {
+		return nil, fmt.Errorf("s3 client has no credentials")
+	}
+	creds, err := options.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving credentials: %w", err)
+	}
+
+	post, err := sign(policy, creds, options.Region, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	post.URL = bucketURL(options, policy.Bucket)
+	return post, nil
+}
+
+// sign builds the policy document and its signature
+func sign(policy Policy, creds aws.Credentials, region string, now time.Time) (*Post, error) {
+	date := now.Format("20060102")
+	amzDate := now.Format("20060102T150405Z")
+	credential := strings.Join([]string{creds.AccessKeyID, date, region, "s3", "aws4_request"}, "/")
+	expiresAt := now.Add(policy.Expiry)
+
+	fields := map[string]string{
+		"key":              policy.Key,
+		"Content-Type":     policy.ContentType,
+		"x-amz-algorithm":  algorithm,
+		"x-amz-credential": credential,
+		"x-amz-date":       amzDate,
+	}
+	if creds.SessionToken != "" {
+		fields["x-amz-security-token"] = creds.SessionToken
+	}
+
+	conditions := []any{
+		map[string]string{"bucket": policy.Bucket},
+		[]any{"content-length-range", policy.MinSize, policy.MaxSize},
+	}
+	for name, value := range fields {
+		conditions = append(conditions, []any{"eq", "$" + name, value})
+	}
+
+	document, err := json.Marshal(map[string]any{
+		"expiration": expiresAt.Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling policy: %w", err)
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(document)
+	key := signingKey(creds.SecretAccessKey, date, region, "s3")
+	fields["policy"] = encoded
+	fields["x-amz-signature"] = hex.EncodeToString(hmacSHA256(key, encoded))
+
+	return &Post{Fields: fields, ExpiresAt: expiresAt}, nil
+}
+
+// bucketURL is where the form is posted, path style for custom endpoints such as a local S3 stand-in
+func bucketURL(options s3.Options, bucket string) string {
+	if options.BaseEndpoint != nil {
+		return strings.TrimSuffix(*options.BaseEndpoint, "/") + "/" + bucket
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/", bucket, options.Region)
+}
+
+// signingKey derives the SigV4 signing key for a day, region and service
+func signingKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
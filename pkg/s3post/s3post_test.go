@@ -0,0 +1,75 @@
+package s3post
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestSigningKey(t *testing.T) {
+	// Example from the AWS Signature Version 4 documentation
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	expected := "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"
+	if got := hex.EncodeToString(key); got != expected {
+		t.Errorf("signingKey() = %s; want %s", got, expected)
+	}
+}
+
+func TestSign(t *testing.T) {
+	policy := Policy{Bucket: "roasts", Key: "users/u1/photos/p1", ContentType: "image/png", MinSize: 1, MaxSize: 1024, Expiry: time.Hour}
+	creds := aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token"}
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	post, err := sign(policy, creds, "eu-west-2", now)
+	if err != nil {
+		t.Fatalf("sign() returned error: %v", err)
+	}
+
+	if post.Fields["x-amz-credential"] != "AKID/20240310/eu-west-2/s3/aws4_request" {
+		t.Errorf("x-amz-credential = %s", post.Fields["x-amz-credential"])
+	}
+	if post.Fields["x-amz-security-token"] != "token" {
+		t.Errorf("x-amz-security-token = %s; want token", post.Fields["x-amz-security-token"])
+	}
+
+	document, err := base64.StdEncoding.DecodeString(post.Fields["policy"])
+	if err != nil {
+		t.Fatalf("policy isn't base64: %v", err)
+	}
+	var decoded struct {
+		Expiration string `json:"expiration"`
+		Conditions []any  `json:"conditions"`
+	}
+	if err := json.Unmarshal(document, &decoded); err != nil {
+		t.Fatalf("policy isn't JSON: %v", err)
+	}
+	if decoded.Expiration != "2024-03-10T13:00:00.000Z" {
+		t.Errorf("expiration = %s", decoded.Expiration)
+	}
+
+	var sizeLimited, typePinned bool
+	for _, condition := range decoded.Conditions {
+		c, ok := condition.([]any)
+		if !ok {
+			continue
+		}
+		if c[0] == "content-length-range" && c[1] == 1.0 && c[2] == 1024.0 {
+			sizeLimited = true
+		}
+		if c[0] == "eq" && c[1] == "$Content-Type" && c[2] == "image/png" {
+			typePinned = true
+		}
+	}
+	if !sizeLimited || !typePinned {
+		t.Errorf("policy conditions %v don't limit the size and content type", decoded.Conditions)
+	}
+
+	expected := hex.EncodeToString(hmacSHA256(signingKey("secret", "20240310", "eu-west-2", "s3"), post.Fields["policy"]))
+	if post.Fields["x-amz-signature"] != expected {
+		t.Errorf("x-amz-signature = %s; want %s", post.Fields["x-amz-signature"], expected)
+	}
+}
@@ -0,0 +1,26 @@
+// Package s3store connects to the image bucket, set S3_ENDPOINT to use a local S3 stand-in such as MinIO
+package s3store
+
+import (
+	"os"
+
+	"github.com/94DanielBrown/roasts-api/pkg/awsconfig"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Connect returns an S3 client, using path style addressing when S3_ENDPOINT is set
+// as local stand-ins don't serve buckets from subdomains
+func Connect() (*s3.Client, error) {
+	config, err := awsconfig.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	client := s3.NewFromConfig(config, func(o *s3.Options) {
+		if endpoint := os.Getenv("S3_ENDPOINT"); endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	return client, nil
+}
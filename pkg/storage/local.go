@@ -0,0 +1,278 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/pkg/s3post"
+)
+
+const (
+	// LocalUploadPath and LocalObjectsPath are the API routes serving a local store
+	LocalUploadPath  = "/localStorage/upload"
+	LocalObjectsPath = "/localStorage/objects/"
+)
+
+var (
+	// ErrInvalidToken is returned when an upload's policy or signature has been tampered with
+	ErrInvalidToken = errors.New("upload token is invalid")
+	// ErrTokenExpired is returned when an upload is made after its token has expired
+	ErrTokenExpired = errors.New("upload token has expired")
+	// ErrPolicyViolation is returned when an upload doesn't match the policy it was signed for
+	ErrPolicyViolation = errors.New("upload doesn't match its policy")
+)
+
+// Local keeps objects in a directory, mimicking a bucket for offline development and tests.
+// Keys are escaped into flat file names, as an upload's key is also the prefix of its variants.
+type Local struct {
+	dir     string
+	baseURL string
+	secret  []byte
+	now     func() time.Time
+}
+
+// uploadToken is the policy a local upload is signed for, the equivalent of an S3 POST policy
+type uploadToken struct {
+	Key         string `json:"key"`
+	ContentType string `json:"contentType"`
+	MinSize     int64  `json:"minSize"`
+	MaxSize     int64  `json:"maxSize"`
+	ExpiresAt   int64  `json:"expiresAt"`
+}
+
+// metadata is stored alongside each object
+type metadata struct {
+	ContentType  string `json:"contentType"`
+	CacheControl string `json:"cacheControl,omitempty"`
+}
+
+// NewLocal returns a store in dir whose objects are served by the API at baseURL
+func NewLocal(dir, baseURL string, secret []byte) (*Local, error) {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("error generating upload secret: %w", err)
+		}
+	}
+	for _, sub := range []string{"objects", "meta"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("error creating storage directory: %w", err)
+		}
+	}
+	return &Local{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/"), secret: secret, now: time.Now}, nil
+}
+
+// PresignUpload signs a token the client posts with the file to the API's upload endpoint, in the same form as an S3 POST
+func (l *Local) PresignUpload(_ context.Context, policy s3post.Policy) (*s3post.Post, error) {
+	expiresAt := l.now().Add(policy.Expiry)
+	document, err := json.Marshal(uploadToken{
+		Key:         policy.Key,
+		ContentType: policy.ContentType,
+		MinSize:     policy.MinSize,
+		MaxSize:     policy.MaxSize,
+		ExpiresAt:   expiresAt.Unix(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling upload token: %w", err)
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(document)
+	return &s3post.Post{
+		URL: l.baseURL + LocalUploadPath,
+		Fields: map[string]string{
+			"key":          policy.Key,
+			"Content-Type": policy.ContentType,
+			"policy":       encoded,
+			"signature":    l.sign(encoded),
+		},
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// Receive stores an upload made with the fields of a presigned upload, checking it against the signed policy
+func (l *Local) Receive(ctx context.Context, fields map[string]string, file io.Reader) error {
+	signature, err := hex.DecodeString(fields["signature"])
+	if err != nil || !hmac.Equal(signature, l.mac(fields["policy"])) {
+		return ErrInvalidToken
+	}
+	document, err := base64.StdEncoding.DecodeString(fields["policy"])
+	if err != nil {
+		return ErrInvalidToken
+	}
+	var token uploadToken
+	if err := json.Unmarshal(document, &token); err != nil {
+		return ErrInvalidToken
+	}
+
+	if l.now().Unix() > token.ExpiresAt {
+		return ErrTokenExpired
+	}
+	if fields["key"] != token.Key || fields["Content-Type"] != token.ContentType {
+		return fmt.Errorf("%w: key or content type differs", ErrPolicyViolation)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(file, token.MaxSize+1))
+	if err != nil {
+		return fmt.Errorf("error reading upload: %w", err)
+	}
+	if size := int64(len(body)); size < token.MinSize || size > token.MaxSize {
+		return fmt.Errorf("%w: size must be between %d and %d bytes", ErrPolicyViolation, token.MinSize, token.MaxSize)
+	}
+	return l.Put(ctx, token.Key, body, token.ContentType, "")
+}
+
+func (l *Local) Get(_ context.Context, key string, length int64) (*Reader, error) {
+	objectPath, metaPath, err := l.paths(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var meta metadata
+	data, err := os.ReadFile(metaPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading metadata of %s: %w", key, err)
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("error unmarshalling metadata of %s: %w", key, err)
+	}
+
+	file, err := os.Open(objectPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", key, err)
+	}
+
+	reader := &Reader{ReadCloser: file, ContentType: meta.ContentType, CacheControl: meta.CacheControl}
+	if length > 0 {
+		reader.ReadCloser = struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(file, length), file}
+	}
+	return reader, nil
+}
+
+// Put writes the object and its metadata, each through a temporary file so readers never see a partial write
+func (l *Local) Put(_ context.Context, key string, body []byte, contentType, cacheControl string) error {
+	objectPath, metaPath, err := l.paths(key)
+	if err != nil {
+		return err
+	}
+	meta, err := json.Marshal(metadata{ContentType: contentType, CacheControl: cacheControl})
+	if err != nil {
+		return fmt.Errorf("error marshalling metadata of %s: %w", key, err)
+	}
+
+	if err := l.writeFile(objectPath, body); err != nil {
+		return fmt.Errorf("error writing %s: %w", key, err)
+	}
+	if err := l.writeFile(metaPath, meta); err != nil {
+		return fmt.Errorf("error writing metadata of %s: %w", key, err)
+	}
+	return nil
+}
+
+func (l *Local) Delete(_ context.Context, key string) error {
+	objectPath, metaPath, err := l.paths(key)
+	if err != nil {
+		return err
+	}
+	// Deleting a missing object succeeds, as it does in S3
+	for _, path := range []string{objectPath, metaPath} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("error deleting %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func (l *Local) List(_ context.Context, prefix string, fn func(Object) error) error {
+	entries, err := os.ReadDir(filepath.Join(l.dir, "objects"))
+	if err != nil {
+		return fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	for _, entry := range entries {
+		key, err := url.PathUnescape(entry.Name())
+		if err != nil || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		info, err := entry.Info()
+		// The object was deleted since the directory was read
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list objects: %w", err)
+		}
+		if err := fn(Object{Key: key, Size: info.Size(), LastModified: info.ModTime()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// URL is where the API serves the object
+func (l *Local) URL(key string) string {
+	return l.baseURL + LocalObjectsPath + key
+}
+
+func (l *Local) PathPrefix() string {
+	return strings.Trim(LocalObjectsPath, "/")
+}
+
+// paths returns where an object and its metadata are kept
+func (l *Local) paths(key string) (string, string, error) {
+	name := url.PathEscape(key)
+	// Escaping removes separators, leaving only these names able to point outside the directory
+	if name == "" || name == "." || name == ".." {
+		return "", "", fmt.Errorf("%w: invalid key %q", ErrNotFound, key)
+	}
+	return filepath.Join(l.dir, "objects", name), filepath.Join(l.dir, "meta", name+".json"), nil
+}
+
+func (l *Local) writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(l.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l *Local) sign(policy string) string {
+	return hex.EncodeToString(l.mac(policy))
+}
+
+func (l *Local) mac(policy string) []byte {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(policy))
+	return mac.Sum(nil)
+}
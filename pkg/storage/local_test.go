@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/pkg/s3post"
+)
+
+func TestLocalReceive(t *testing.T) {
+	ctx := context.Background()
+	local, err := NewLocal(t.TempDir(), "http://localhost:8000", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	local.now = func() time.Time { return now }
+
+	post, err := local.PresignUpload(ctx, s3post.Policy{
+		Key:         "users/u1/uploads/a",
+		ContentType: "image/png",
+		MinSize:     1,
+		MaxSize:     10,
+		Expiry:      time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.URL != "http://localhost:8000"+LocalUploadPath {
+		t.Errorf("URL = %s", post.URL)
+	}
+
+	with := func(name, value string) map[string]string {
+		fields := map[string]string{}
+		for k, v := range post.Fields {
+			fields[k] = v
+		}
+		fields[name] = value
+		return fields
+	}
+	testCases := []struct {
+		name     string
+		fields   map[string]string
+		body     string
+		after    time.Duration
+		expected error
+	}{
+		{"tampered signature", with("signature", strings.Repeat("0", 64)), "png", 0, ErrInvalidToken},
+		{"other key", with("key", "users/u2/uploads/a"), "png", 0, ErrPolicyViolation},
+		{"other content type", with("Content-Type", "image/jpeg"), "png", 0, ErrPolicyViolation},
+		{"too large", post.Fields, "more than ten bytes", 0, ErrPolicyViolation},
+		{"empty", post.Fields, "", 0, ErrPolicyViolation},
+		{"expired", post.Fields, "png", 2 * time.Minute, ErrTokenExpired},
+		{"valid", post.Fields, "png", 0, nil},
+	}
+
+	for _, tc := range testCases {
+		local.now = func() time.Time { return now.Add(tc.after) }
+		if err := local.Receive(ctx, tc.fields, strings.NewReader(tc.body)); !errors.Is(err, tc.expected) {
+			t.Errorf("%s: Receive() = %v; want %v", tc.name, err, tc.expected)
+		}
+	}
+
+	object, err := local.Get(ctx, "users/u1/uploads/a", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer object.Close()
+	header, _ := io.ReadAll(object)
+	if string(header) != "pn" || object.ContentType != "image/png" {
+		t.Errorf("Get() = %q, %s", header, object.ContentType)
+	}
+}
+
+func TestLocalObjects(t *testing.T) {
+	ctx := context.Background()
+	local, err := NewLocal(t.TempDir(), "http://localhost:8000", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An upload's key is also the prefix of its variants, so both have to be able to exist at once
+	keys := []string{"users/u1/photos/a", "users/u1/photos/a/thumb.jpg", "users/u2/photos/b"}
+	for _, key := range keys {
+		if err := local.Put(ctx, key, []byte(key), "image/jpeg", "public"); err != nil {
+			t.Fatalf("Put(%s) = %v", key, err)
+		}
+	}
+	if err := local.Delete(ctx, "users/u1/photos/a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := local.Delete(ctx, "missing"); err != nil {
+		t.Errorf("Delete(missing) = %v", err)
+	}
+	if _, err := local.Get(ctx, "users/u1/photos/a", 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(deleted) = %v; want %v", err, ErrNotFound)
+	}
+	if _, err := local.Get(ctx, "..", 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(..) = %v; want %v", err, ErrNotFound)
+	}
+
+	var listed []string
+	err = local.List(ctx, "users/u1/", func(object Object) error {
+		listed = append(listed, object.Key)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0] != "users/u1/photos/a/thumb.jpg" {
+		t.Errorf("List() = %v", listed)
+	}
+	if url := local.URL(listed[0]); url != "http://localhost:8000/localStorage/objects/users/u1/photos/a/thumb.jpg" {
+		t.Errorf("URL() = %s", url)
+	}
+}
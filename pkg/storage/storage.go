@@ -0,0 +1,73 @@
+// Package storage stores uploaded images, either in an S3 bucket or, for running offline, in a local directory
+// served by the API itself with signed upload tokens standing in for presigned POSTs.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/94DanielBrown/roasts-api/pkg/s3post"
+	"github.com/94DanielBrown/roasts-api/pkg/s3store"
+)
+
+// ErrNotFound is returned when getting an object that doesn't exist
+var ErrNotFound = errors.New("object not found")
+
+// Store is where uploaded images and their variants are kept
+type Store interface {
+	// PresignUpload returns a form the client can upload a single object matching policy with.
+	// The policy's bucket is ignored, objects are always uploaded to the store's own.
+	PresignUpload(ctx context.Context, policy s3post.Policy) (*s3post.Post, error)
+	// Get returns an object's content, only the first length bytes if length is greater than 0
+	Get(ctx context.Context, key string, length int64) (*Reader, error)
+	Put(ctx context.Context, key string, body []byte, contentType, cacheControl string) error
+	Delete(ctx context.Context, key string) error
+	// List calls fn for every object under prefix
+	List(ctx context.Context, prefix string, fn func(Object) error) error
+	// URL is where the object can be downloaded from
+	URL(key string) string
+	// PathPrefix is what precedes keys in the path of the store's URLs, if anything
+	PathPrefix() string
+}
+
+// Reader is the content of an object
+type Reader struct {
+	io.ReadCloser
+	ContentType  string
+	CacheControl string
+}
+
+// Object is an object in a store
+type Object struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// Config selects the store, images are kept on local disk if Dir is set and otherwise in Bucket
+type Config struct {
+	Bucket string
+	Dir    string
+	// Secret signs local upload tokens, if empty a random one is used that only lasts as long as the process
+	Secret string
+	// BaseURL is the API's own URL, which serves objects kept on local disk
+	BaseURL string
+}
+
+// New returns the store selected by config
+func New(config Config) (Store, error) {
+	if config.Dir != "" {
+		return NewLocal(config.Dir, config.BaseURL, []byte(config.Secret))
+	}
+	if config.Bucket == "" {
+		return nil, errors.New("an image bucket or local storage directory is required")
+	}
+
+	client, err := s3store.Connect()
+	if err != nil {
+		return nil, err
+	}
+	return NewS3(client, config.Bucket), nil
+}
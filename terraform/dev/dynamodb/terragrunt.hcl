@@ -19,6 +19,36 @@ inputs = {
     {
       name = "SK"
       type = "S"
+    },
+    {
+      name = "UserID"
+      type = "S"
+    },
+    {
+      name = "GeoCell"
+      type = "S"
+    },
+    {
+      name = "Geohash"
+      type = "S"
+    }
+  ]
+  // Must match TableSchema in pkg/dynamo, the app refuses to start otherwise
+  global_secondary_indexes = [
+    {
+      name            = "UserIndex"
+      hash_key        = "UserID"
+      range_key       = "SK"
+      projection_type = "ALL"
+    },
+    {
+      name            = "GeoIndex"
+      hash_key        = "GeoCell"
+      range_key       = "Geohash"
+      projection_type = "ALL"
     }
   ]
+  // Removes expired items such as daily upload quotas
+  ttl_enabled        = true
+  ttl_attribute_name = "ExpiresAt"
 }
\ No newline at end of file